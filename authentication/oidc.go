@@ -0,0 +1,406 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OidcProvider: an OpenID Connect identity provider that is used with the authorization code flow (with PKCE)
+type OidcProvider struct {
+	Issuer                string
+	ClientId              string
+	ClientSecret          string
+	RedirectUrl           string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JwksUri               string
+	authMethods           []string
+	client                *http.Client
+	keysLock              sync.RWMutex
+	keys                  map[string]crypto.PublicKey
+}
+
+// OidcClaims: the claims of a verified id token that Journey cares about
+type OidcClaims struct {
+	Subject           string
+	Email             string
+	Name              string
+	PreferredUsername string
+}
+
+type oidcDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+type oidcTokenResponse struct {
+	IdToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type idTokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idTokenClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	Expiry            int64           `json:"exp"`
+	Nonce             string          `json:"nonce"`
+	Email             string          `json:"email"`
+	EmailVerified     *bool           `json:"email_verified"`
+	Name              string          `json:"name"`
+	PreferredUsername string          `json:"preferred_username"`
+}
+
+// Allowed difference between the clocks of Journey and the identity provider
+const oidcClockSkew = 2 * time.Minute
+
+// Function to create a provider from the discovery document of the given issuer (issuer/.well-known/openid-configuration)
+func NewOidcProvider(issuer string, clientId string, clientSecret string, redirectUrl string) (*OidcProvider, error) {
+	provider := &OidcProvider{Issuer: strings.TrimSuffix(issuer, "/"), ClientId: clientId, ClientSecret: clientSecret, RedirectUrl: redirectUrl, client: &http.Client{Timeout: 10 * time.Second}}
+	var discovery oidcDiscovery
+	err := provider.getJson(provider.Issuer+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, err
+	}
+	// The issuer in the discovery document must match the configured one exactly
+	if strings.TrimSuffix(discovery.Issuer, "/") != provider.Issuer {
+		return nil, errors.New("oidc: issuer in discovery document (" + discovery.Issuer + ") doesn't match " + provider.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksUri == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	provider.AuthorizationEndpoint = discovery.AuthorizationEndpoint
+	provider.TokenEndpoint = discovery.TokenEndpoint
+	provider.JwksUri = discovery.JwksUri
+	provider.authMethods = discovery.TokenEndpointAuthMethodsSupported
+	return provider, nil
+}
+
+// Function to generate a random string that can be used as state, nonce, or PKCE code verifier
+func GenerateOidcSecret() (string, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// Function to get the url the user has to be redirected to in order to log in at the identity provider
+func (p *OidcProvider) AuthCodeUrl(state string, nonce string, codeVerifier string) string {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientId)
+	values.Set("redirect_uri", p.RedirectUrl)
+	values.Set("scope", "openid email profile")
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	values.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// Function to exchange the authorization code for an id token. Returns the claims of the id token after verifying it.
+func (p *OidcProvider) Exchange(code string, codeVerifier string, nonce string) (*OidcClaims, error) {
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.RedirectUrl)
+	values.Set("code_verifier", codeVerifier)
+	values.Set("client_id", p.ClientId)
+	// Use client_secret_basic unless the provider only supports client_secret_post
+	useBasicAuth := true
+	if len(p.authMethods) != 0 && !containsString(p.authMethods, "client_secret_basic") && containsString(p.authMethods, "client_secret_post") {
+		useBasicAuth = false
+		values.Set("client_secret", p.ClientSecret)
+	}
+	request, err := http.NewRequest("POST", p.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		request.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret))
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var tokenResponse oidcTokenResponse
+	err = json.Unmarshal(body, &tokenResponse)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: couldn't decode token response (status %d): %v", response.StatusCode, err)
+	}
+	if tokenResponse.Error != "" {
+		return nil, errors.New("oidc: token endpoint returned error: " + tokenResponse.Error + " " + tokenResponse.ErrorDescription)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint returned status %d", response.StatusCode)
+	}
+	if tokenResponse.IdToken == "" {
+		return nil, errors.New("oidc: token response contains no id token")
+	}
+	return p.VerifyIdToken(tokenResponse.IdToken, nonce)
+}
+
+// Function to verify the signature and the claims of an id token
+func (p *OidcProvider) VerifyIdToken(idToken string, nonce string) (*OidcClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed id token")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token header")
+	}
+	var header idTokenHeader
+	err = json.Unmarshal(headerBytes, &header)
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token signature")
+	}
+	key, err := p.getKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token claims")
+	}
+	var claims idTokenClaims
+	err = json.Unmarshal(claimsBytes, &claims)
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token claims")
+	}
+	if strings.TrimSuffix(claims.Issuer, "/") != p.Issuer {
+		return nil, errors.New("oidc: id token was issued by " + claims.Issuer)
+	}
+	if !audienceContains(claims.Audience, p.ClientId) {
+		return nil, errors.New("oidc: id token was not issued for this client")
+	}
+	if time.Unix(claims.Expiry, 0).Add(oidcClockSkew).Before(time.Now()) {
+		return nil, errors.New("oidc: id token is expired")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("oidc: nonce of id token doesn't match")
+	}
+	if claims.Email == "" {
+		return nil, errors.New("oidc: id token contains no email address")
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, errors.New("oidc: email address " + claims.Email + " is not verified")
+	}
+	return &OidcClaims{Subject: claims.Subject, Email: claims.Email, Name: claims.Name, PreferredUsername: claims.PreferredUsername}, nil
+}
+
+func (p *OidcProvider) getKey(kid string) (crypto.PublicKey, error) {
+	p.keysLock.RLock()
+	key, ok := p.keys[kid]
+	p.keysLock.RUnlock()
+	if ok {
+		return key, nil
+	}
+	// Unknown key id. The provider might have rotated its keys, so fetch them again.
+	err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keysLock.RLock()
+	defer p.keysLock.RUnlock()
+	if key, ok = p.keys[kid]; ok {
+		return key, nil
+	}
+	// Providers with only one key don't have to set a key id
+	if kid == "" && len(p.keys) == 1 {
+		for _, key = range p.keys {
+			return key, nil
+		}
+	}
+	return nil, errors.New("oidc: couldn't find key " + kid + " to verify id token")
+}
+
+func (p *OidcProvider) fetchKeys() error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err := p.getJson(p.JwksUri, &jwks)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we don't understand
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keysLock.Lock()
+	defer p.keysLock.Unlock()
+	p.keys = keys
+	return nil
+}
+
+func (p *OidcProvider) getJson(address string, target interface{}) error {
+	response, err := p.client.Get(address)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s returned status %d", address, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func (jwk *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, errors.New("oidc: unsupported curve " + jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, errors.New("oidc: unsupported key type " + jwk.Kty)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return errors.New("oidc: unsupported signing algorithm " + alg)
+	}
+	hasher := hash.New()
+	_, _ = hasher.Write(signed)
+	hashed := hasher.Sum(nil)
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return errors.New("oidc: algorithm " + alg + " doesn't match key type")
+		}
+		if rsa.VerifyPKCS1v15(publicKey, hash, hashed, signature) != nil {
+			return errors.New("oidc: invalid id token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") || len(signature)%2 != 0 {
+			return errors.New("oidc: algorithm " + alg + " doesn't match key type")
+		}
+		r := new(big.Int).SetBytes(signature[:len(signature)/2])
+		s := new(big.Int).SetBytes(signature[len(signature)/2:])
+		if !ecdsa.Verify(publicKey, hashed, r, s) {
+			return errors.New("oidc: invalid id token signature")
+		}
+		return nil
+	}
+	return errors.New("oidc: unsupported key type")
+}
+
+// The aud claim can either be a single string or an array of strings
+func audienceContains(audience json.RawMessage, clientId string) bool {
+	var single string
+	if json.Unmarshal(audience, &single) == nil {
+		return single == clientId
+	}
+	var multiple []string
+	if json.Unmarshal(audience, &multiple) == nil {
+		return containsString(multiple, clientId)
+	}
+	return false
+}
+
+func containsString(slice []string, value string) bool {
+	for _, element := range slice {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
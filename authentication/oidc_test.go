@@ -0,0 +1,161 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockProvider is a minimal OpenID Connect provider that issues id tokens for a single authorization code
+type mockProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	code          string
+	codeChallenge string
+	nonce         string
+	claims        map[string]interface{}
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockProvider{key: key, code: "test-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "journey" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		verifierHash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != mock.code || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != mock.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": mock.sign(t, mock.claims)})
+	})
+	mock.server = httptest.NewServer(mux)
+	mock.claims = map[string]interface{}{
+		"iss":            mock.server.URL,
+		"sub":            "1234",
+		"aud":            "journey",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email":          "jane@example.com",
+		"email_verified": true,
+	}
+	return mock
+}
+
+func (m *mockProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	claims["nonce"] = m.nonce
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Function to do what the browser would do: follow the authorization url and remember what the provider saw
+func (m *mockProvider) authorize(t *testing.T, provider *OidcProvider, state string, nonce string, verifier string) {
+	authUrl, err := url.Parse(provider.AuthCodeUrl(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authUrl.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("state") != state || query.Get("client_id") != "journey" {
+		t.Fatalf("Unexpected authorization url %s", authUrl)
+	}
+	m.codeChallenge = query.Get("code_challenge")
+	m.nonce = query.Get("nonce")
+}
+
+func TestOidcLogin(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.server.Close()
+	provider, err := NewOidcProvider(mock.server.URL, "journey", "secret", "http://127.0.0.1:8084/admin/oidc/callback/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.authorize(t, provider, "state", "nonce", "verifier")
+	claims, err := provider.Exchange("test-code", "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "jane@example.com" || claims.Subject != "1234" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+}
+
+func TestOidcLoginRejected(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.server.Close()
+	provider, err := NewOidcProvider(mock.server.URL, "journey", "secret", "http://127.0.0.1:8084/admin/oidc/callback/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		claims   map[string]interface{}
+		err      string
+	}{
+		{name: "wrong code verifier", verifier: "other", nonce: "nonce", err: "invalid_grant"},
+		{name: "wrong nonce", verifier: "verifier", nonce: "other", err: "nonce"},
+		{name: "wrong audience", verifier: "verifier", nonce: "nonce", claims: map[string]interface{}{"aud": "someone-else"}, err: "client"},
+		{name: "expired", verifier: "verifier", nonce: "nonce", claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, err: "expired"},
+		{name: "unverified email", verifier: "verifier", nonce: "nonce", claims: map[string]interface{}{"email_verified": false}, err: "not verified"},
+	}
+	for _, test := range tests {
+		mock.authorize(t, provider, "state", "nonce", "verifier")
+		original := mock.claims
+		mock.claims = make(map[string]interface{})
+		for key, value := range original {
+			mock.claims[key] = value
+		}
+		for key, value := range test.claims {
+			mock.claims[key] = value
+		}
+		_, err := provider.Exchange("test-code", test.verifier, test.nonce)
+		mock.claims = original
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing '%s', received %v", test.name, test.err, err)
+		}
+	}
+}
@@ -21,6 +21,17 @@ func SetSession(userName string, response http.ResponseWriter) {
 		}
 		http.SetCookie(response, cookie)
 	}
+	// The session cookie is only sent to the admin area. The blog cookie lets the blog know that an admin is
+	// looking at it (e.g. to bypass the page cache). It doesn't give access to anything.
+	if encoded, err := cookieHandler.Encode("blog", value); err == nil {
+		cookie := &http.Cookie{
+			Name:     "blog",
+			Value:    encoded,
+			Path:     "/",
+			HttpOnly: true,
+		}
+		http.SetCookie(response, cookie)
+	}
 }
 
 func GetUserName(request *http.Request) (userName string) {
@@ -33,6 +44,18 @@ func GetUserName(request *http.Request) (userName string) {
 	return userName
 }
 
+// Function to get the name of the logged in user on the pages of the blog. Only use it to decide how pages are served,
+// requests to the admin area have to be authenticated with GetUserName.
+func GetBlogUserName(request *http.Request) (userName string) {
+	if cookie, err := request.Cookie("blog"); err == nil {
+		cookieValue := make(map[string]string)
+		if err = cookieHandler.Decode("blog", cookie.Value, &cookieValue); err == nil {
+			userName = cookieValue["name"]
+		}
+	}
+	return userName
+}
+
 func ClearSession(response http.ResponseWriter) {
 	cookie := &http.Cookie{
 		Name:   "session",
@@ -41,4 +64,55 @@ func ClearSession(response http.ResponseWriter) {
 		MaxAge: -1,
 	}
 	http.SetCookie(response, cookie)
+	cookie = &http.Cookie{
+		Name:   "blog",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	}
+	http.SetCookie(response, cookie)
+}
+
+// Function to remember the state, nonce and PKCE code verifier of a single sign-on login until the identity provider redirects back to Journey
+func SetOidcState(state string, nonce string, codeVerifier string, response http.ResponseWriter) error {
+	value := map[string]string{
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": codeVerifier,
+	}
+	encoded, err := cookieHandler.Encode("oidc", value)
+	if err != nil {
+		return err
+	}
+	cookie := &http.Cookie{
+		Name:     "oidc",
+		Value:    encoded,
+		Path:     "/admin/oidc/",
+		MaxAge:   600,
+		HttpOnly: true,
+	}
+	http.SetCookie(response, cookie)
+	return nil
+}
+
+func GetOidcState(request *http.Request) (state string, nonce string, codeVerifier string) {
+	if cookie, err := request.Cookie("oidc"); err == nil {
+		cookieValue := make(map[string]string)
+		if err = cookieHandler.Decode("oidc", cookie.Value, &cookieValue); err == nil {
+			state = cookieValue["state"]
+			nonce = cookieValue["nonce"]
+			codeVerifier = cookieValue["verifier"]
+		}
+	}
+	return state, nonce, codeVerifier
+}
+
+func ClearOidcState(response http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:   "oidc",
+		Value:  "",
+		Path:   "/admin/oidc/",
+		MaxAge: -1,
+	}
+	http.SetCookie(response, cookie)
 }
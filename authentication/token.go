@@ -0,0 +1,102 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"journey/database"
+	"journey/date"
+)
+
+// Scopes that can be granted to a personal access token
+const (
+	ScopeRead     = "read"
+	ScopePosts    = "posts"
+	ScopeUpload   = "upload"
+	ScopeSettings = "settings"
+)
+
+var allScopes = []string{ScopeRead, ScopePosts, ScopeUpload, ScopeSettings}
+
+// Function to create a new random token. Only the hash of the token is ever saved in the database.
+func GenerateToken() (token string, tokenHash string, err error) {
+	randomBytes := make([]byte, 32)
+	_, err = rand.Read(randomBytes)
+	if err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(randomBytes)
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func IsValidScope(scope string) bool {
+	for _, validScope := range allScopes {
+		if scope == validScope {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if a user with the given role may use the given scope. Only administrators and owners may change the blog settings.
+func ScopeAllowedForRole(scope string, role int) bool {
+	if scope == ScopeSettings {
+		return role == 1 || role == 4 // 1 = Administrator, 4 = Owner
+	}
+	return true
+}
+
+// Function to get the name of the user that owns the bearer token in the Authorization header.
+// Returns an empty string if there is no valid token or if the token (or its owner) is lacking the requested scope.
+func GetTokenUserName(request *http.Request, scope string) (userName string) {
+	authorization := request.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	tokenString := strings.TrimSpace(authorization[len("Bearer "):])
+	if tokenString == "" {
+		return ""
+	}
+	token, err := database.RetrieveTokenByHash(HashToken(tokenString))
+	if err != nil {
+		return ""
+	}
+	if !tokenHasScope(token.Scopes, scope) {
+		return ""
+	}
+	user, err := database.RetrieveUser(token.UserId)
+	if err != nil {
+		return ""
+	}
+	// The token can never grant more than the role of its owner allows
+	if !ScopeAllowedForRole(scope, user.Role) {
+		return ""
+	}
+	err = database.UpdateTokenLastUsed(token.Id, date.GetCurrentTime())
+	if err != nil {
+		log.Println("Couldn't update last used date of a token:", err)
+	}
+	return string(user.Name)
+}
+
+func tokenHasScope(scopes []string, scope string) bool {
+	for _, tokenScope := range scopes {
+		if tokenScope == scope {
+			return true
+		}
+		// Every token that is allowed to write is allowed to read as well
+		if scope == ScopeRead && IsValidScope(tokenScope) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package authentication
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"journey/database"
+	"journey/filenames"
+)
+
+// Function to use a new database in a temporary folder. The returned function restores the previous paths.
+func useTestDatabase(t *testing.T) func() {
+	t.Helper()
+	databasePath, err := ioutil.TempDir("", "journey-database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseFilepath, databaseFilename := filenames.DatabaseFilepath, filenames.DatabaseFilename
+	filenames.DatabaseFilepath, filenames.DatabaseFilename = databasePath, filepath.Join(databasePath, "journey.db")
+	restore := func() {
+		filenames.DatabaseFilepath, filenames.DatabaseFilename = databaseFilepath, databaseFilename
+		os.RemoveAll(databasePath)
+	}
+	if err := database.Initialize(); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return restore
+}
+
+func TestGenerateToken(t *testing.T) {
+	token, tokenHash, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 64 || tokenHash != HashToken(token) || tokenHash == token {
+		t.Errorf("Unexpected token %q with hash %q", token, tokenHash)
+	}
+	if otherToken, _, _ := GenerateToken(); otherToken == token {
+		t.Error("Expected a new token every time")
+	}
+}
+
+func TestScopeAllowedForRole(t *testing.T) {
+	tests := []struct {
+		scope   string
+		role    int
+		allowed bool
+	}{
+		{ScopeSettings, 1, true},
+		{ScopeSettings, 4, true},
+		{ScopeSettings, 2, false},
+		{ScopeSettings, 3, false},
+		{ScopePosts, 3, true},
+		{ScopeUpload, 2, true},
+		{ScopeRead, 3, true},
+	}
+	for _, test := range tests {
+		if allowed := ScopeAllowedForRole(test.scope, test.role); allowed != test.allowed {
+			t.Errorf("Expected %v for scope %s and role %d, received %v", test.allowed, test.scope, test.role, allowed)
+		}
+	}
+}
+
+func TestGetTokenUserName(t *testing.T) {
+	defer useTestDatabase(t)()
+	now := time.Now()
+	insertUser := func(name string, role int) int64 {
+		userId, err := database.InsertUser([]byte(name), name, "", []byte(name+"@example.com"), []byte{}, []byte{}, now, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = database.InsertRoleUser(role, userId); err != nil {
+			t.Fatal(err)
+		}
+		return userId
+	}
+	insertToken := func(userId int64, scopes string) (string, int64) {
+		token, tokenHash, err := GenerateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenId, err := database.InsertToken([]byte(scopes), tokenHash, scopes, userId, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token, tokenId
+	}
+	adminId := insertUser("admin", 1)
+	authorId := insertUser("author", 3)
+	postsToken, postsTokenId := insertToken(adminId, ScopePosts)
+	settingsToken, _ := insertToken(adminId, ScopeSettings)
+	// The role of the author has been lowered after the token with the settings scope was created
+	lowered, _ := insertToken(authorId, ScopeSettings+","+ScopePosts)
+	tests := []struct {
+		authorization string
+		scope         string
+		expected      string
+	}{
+		{"Bearer " + postsToken, ScopePosts, "admin"},
+		{"bearer " + postsToken, ScopePosts, "admin"},
+		{"BEARER   " + postsToken + " ", ScopePosts, "admin"},
+		{"Bearer\t" + postsToken, ScopePosts, ""},
+		{"Bearer ", ScopePosts, ""},
+		{"Bearer", ScopePosts, ""},
+		{"", ScopePosts, ""},
+		{"Basic " + postsToken, ScopePosts, ""},
+		{postsToken, ScopePosts, ""},
+		{"Bearer " + postsToken + "0", ScopePosts, ""},
+		{"Bearer " + HashToken(postsToken), ScopePosts, ""},
+		// Scopes
+		{"Bearer " + postsToken, ScopeRead, "admin"},
+		{"Bearer " + postsToken, ScopeUpload, ""},
+		{"Bearer " + postsToken, ScopeSettings, ""},
+		{"Bearer " + settingsToken, ScopeSettings, "admin"},
+		{"Bearer " + lowered, ScopeSettings, ""},
+		{"Bearer " + lowered, ScopePosts, "author"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/admin/api/posts/1", nil)
+		if test.authorization != "" {
+			request.Header.Set("Authorization", test.authorization)
+		}
+		if userName := GetTokenUserName(request, test.scope); userName != test.expected {
+			t.Errorf("Expected %q for %q with scope %s, received %q", test.expected, test.authorization, test.scope, userName)
+		}
+	}
+	// Only the tokens that have been used successfully have a last used date
+	tokens, err := database.RetrieveTokensByUser(adminId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Id == postsTokenId && (token.LastUsedAt == nil || token.LastUsedAt.Before(now.Add(-time.Second))) {
+			t.Errorf("Expected the last used date of the token to be updated, received %v", token.LastUsedAt)
+		}
+	}
+	tokens, err = database.RetrieveTokensByUser(authorId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("Expected the token of the author to be used for posts, received %+v", tokens)
+	}
+	// Revoking another user's token fails and leaves the token usable
+	if err := database.DeleteTokenByIdAndUser(postsTokenId, authorId); err == nil {
+		t.Error("Expected the token of another user to not be revoked")
+	}
+	request := httptest.NewRequest("GET", "/admin/api/posts/1", nil)
+	request.Header.Set("Authorization", "Bearer "+postsToken)
+	if userName := GetTokenUserName(request, ScopePosts); userName != "admin" {
+		t.Errorf("Expected the token to still be valid, received %q", userName)
+	}
+	if err := database.DeleteTokenByIdAndUser(postsTokenId, adminId); err != nil {
+		t.Fatal(err)
+	}
+	if userName := GetTokenUserName(request, ScopePosts); userName != "" {
+		t.Errorf("Expected the revoked token to be rejected, received %q", userName)
+	}
+}
@@ -33,6 +33,7 @@ adminApp.factory('sharingService', function(){
       post: {},
       blog: {},
       user: {},
+      customTemplates: [],
       infiniteScrollFactory: null,
       selected: ''
     }
@@ -59,8 +60,8 @@ adminApp.directive('evaluateUrl', function() {
     link: function(scope, elem, attrs, ctrl) {
       elem.on('blur', function() {
         var value = elem.val();
-        //if the url of this item doesn't start with http/https, add the blog url to it.
-        if (!(value.substring(0, 'http://'.length) === 'http://') && !(value.substring(0, 'https://'.length) === 'https://') && !(value.substring(0, scope.shared.blog.Url.length) === scope.shared.blog.Url)) {
+        //if the url of this item is a path on the blog, add the blog url to it. Anchors and links with a scheme (http, mailto, ...) stay as they are.
+        if (!/^[a-z][a-z0-9+.-]*:/i.test(value) && (value.substring(0, 1) != '#')) {
           if ((value.substring(0, 1) != '/') && (scope.shared.blog.Url.slice(-1) != '/')) {
             value = '/' + value;
           }
@@ -125,27 +126,24 @@ adminApp.controller('SettingsCtrl', function ($scope, $http, $timeout, $sce, $lo
   //variable to hold the field prefix
   $scope.prefix = '';
   $scope.loadData = function() {
+    $http.get('/admin/api/themesettings').success(function(data) {
+      $scope.themeSettings = data;
+    });
     $http.get('/admin/api/blog').success(function(data) {
       $scope.shared.blog = data;
       //select active theme
       var themeIndex = $scope.shared.blog.Themes.indexOf($scope.shared.blog.ActiveTheme);
       $scope.shared.blog.ActiveTheme = $scope.shared.blog.Themes[themeIndex];
-      //make sure NavigationItems is not null
+      //make sure the navigation lists are not null
       if ($scope.shared.blog.NavigationItems == null) {
         $scope.shared.blog.NavigationItems = []
       }
-      //append the blog url to the navigation items if necessary
-      for (var i = 0; i < $scope.shared.blog.NavigationItems.length; i++) {
-        var value = $scope.shared.blog.NavigationItems[i].url;
-        //if the url of this item doesn't start with http/https, add the blog url to it.
-        if (!(value.substring(0, 'http://'.length) === 'http://') && !(value.substring(0, 'https://'.length) === 'https://') && !(value.substring(0, $scope.shared.blog.Url.length) === $scope.shared.blog.Url)) {
-          if ((value.substring(0, 1) != '/') && ($scope.shared.blog.Url.slice(-1) != '/')) {
-            value = '/' + value;
-          }
-          value = $scope.shared.blog.Url + value;
-          $scope.shared.blog.NavigationItems[i].url = value;
-        }
+      if ($scope.shared.blog.SecondaryNavigationItems == null) {
+        $scope.shared.blog.SecondaryNavigationItems = []
       }
+      //append the blog url to the navigation items if necessary
+      $scope.prefixNavItems($scope.shared.blog.NavigationItems);
+      $scope.prefixNavItems($scope.shared.blog.SecondaryNavigationItems);
     });
     $http.get('/admin/api/userid').success(function(data) {
       $scope.authenticatedUser = data;
@@ -155,20 +153,92 @@ adminApp.controller('SettingsCtrl', function ($scope, $http, $timeout, $sce, $lo
     });
   };
   $scope.loadData();
-  $scope.deleteNavItem = function(index) {
-    $scope.shared.blog.NavigationItems.splice(index, 1);
+  $scope.prefixNavItems = function(items) {
+    for (var i = 0; i < items.length; i++) {
+      var value = items[i].url;
+      //if the url of this item is a path on the blog, add the blog url to it. Anchors and links with a scheme (http, mailto, ...) stay as they are.
+      if (!/^[a-z][a-z0-9+.-]*:/i.test(value) && (value.substring(0, 1) != '#')) {
+        if ((value.substring(0, 1) != '/') && ($scope.shared.blog.Url.slice(-1) != '/')) {
+          value = '/' + value;
+        }
+        items[i].url = $scope.shared.blog.Url + value;
+      }
+      if (items[i].children == null) {
+        items[i].children = [];
+      }
+      $scope.prefixNavItems(items[i].children);
+    }
+  };
+  $scope.deleteNavItem = function(items, index) {
+    items.splice(index, 1);
   };
-  $scope.addNavItem = function() {
+  $scope.addNavItem = function(items) {
     var url = $scope.shared.blog.Url
     if (url.slice(-1) != '/') {
       url = url + '/';
     }
-    $scope.shared.blog['NavigationItems'].push({label: 'Home', url: url});
+    items.push({label: 'Home', url: url, children: []});
+  };
+  $scope.uploadTheme = function(files, overwrite) {
+    if (files.length == 0) {
+      return;
+    }
+    var formData = new FormData();
+    formData.append('theme', files[0]);
+    if (overwrite) {
+      formData.append('overwrite', 'true');
+    }
+    $scope.themeMessage = 'Uploading...';
+    $scope.themeProblems = [];
+    $http.post('/admin/api/theme', formData, {transformRequest: angular.identity, headers: {'Content-Type': undefined}}).success(function(data) {
+      $scope.themeMessage = 'Theme ' + data.Theme + ' installed.';
+      $scope.themeProblems = data.Problems;
+      var activeTheme = $scope.shared.blog.ActiveTheme;
+      $http.get('/admin/api/blog').success(function(blog) {
+        $scope.shared.blog.Themes = blog.Themes;
+        $scope.shared.blog.ActiveTheme = activeTheme;
+      });
+    }).error(function(data) {
+      //installed themes are only replaced after confirmation
+      if ((data.Code == 'theme_exists' || data.Code == 'theme_active') && confirm(data.error + ' Replace it?')) {
+        $scope.uploadTheme(files, true);
+        return;
+      }
+      $scope.themeMessage = data.error;
+      $scope.themeProblems = data.Report ? data.Report.Problems : [];
+    });
+  };
+  $scope.deleteTheme = function(theme) {
+    if (!confirm('Delete the theme ' + theme + '?')) {
+      return;
+    }
+    $http.delete('/admin/api/theme/' + theme).success(function(data) {
+      $scope.loadData();
+    }).error(function(data) {
+      alert(data.error);
+    });
   };
   $scope.save = function() {
-    $http.patch('/admin/api/blog', $scope.shared.blog);
-    $http.patch('/admin/api/user', $scope.shared.user).success(function(data) {
-      $location.url('/');
+    var saveBlog = function() {
+      //a theme that doesn't compile isn't activated
+      $http.patch('/admin/api/blog', $scope.shared.blog).error(function(data) {
+        alert(data);
+      });
+      $http.patch('/admin/api/user', $scope.shared.user).success(function(data) {
+        $location.url('/');
+      });
+    };
+    if (!$scope.themeSettings || $scope.themeSettings.Settings.length == 0) {
+      saveBlog();
+      return;
+    }
+    //the theme settings belong to the loaded theme, so they are saved before the theme can be changed
+    var values = {};
+    for (var i = 0; i < $scope.themeSettings.Settings.length; i++) {
+      values[$scope.themeSettings.Settings[i].Name] = $scope.themeSettings.Settings[i].Value;
+    }
+    $http.patch('/admin/api/themesettings', values).success(saveBlog).error(function(data) {
+      alert(data);
     });
   };
 });
@@ -179,7 +249,11 @@ adminApp.controller('CreateCtrl', function ($scope, $http, $sce, $location, shar
   //change the navbar according to controller
   $scope.navbarHtml = $sce.trustAsHtml('<ul class="nav navbar-nav"><li><a href="#/">Content</a></li><li class="active"><a href="#/create/">New Post<span class="sr-only">(current)</span></a></li><li><a href="#/settings/">Settings</a></li><li><a href="logout/" class="logout">( Log Out )</a></li></ul>');
   $scope.shared = sharingService.shared;
-  $scope.shared.post = {Title: 'New Post', Slug: '', Markdown: 'Write something!', IsPublished: false, Image: '', Tags: ''}
+  $scope.shared.post = {Title: 'New Post', Slug: '', Markdown: 'Write something!', IsPublished: false, Image: '', Tags: '', CustomTemplate: ''}
+  //load the custom templates of the active theme for the post options
+  $http.get('/admin/api/blog').success(function(data) {
+    $scope.shared.customTemplates = data.CustomTemplates;
+  });
   $scope.change = function() {
     document.getElementById('html-div').innerHTML = '<h1>' + $scope.shared.post.Title + '</h1><br>' + converter.makeHtml($scope.shared.post.Markdown);
     //resize the markdown textarea
@@ -201,6 +275,10 @@ adminApp.controller('EditCtrl', function ($scope, $routeParams, $http, $sce, $lo
   $scope.navbarHtml = $sce.trustAsHtml('<ul class="nav navbar-nav"><li><a href="#/">Content</a></li><li><a href="#/create/">New Post</a></li><li><a href="#/settings/">Settings</a></li><li><a href="logout/" class="logout">( Log Out )</a></li></ul>');
   $scope.shared = sharingService.shared;
   $scope.shared.post = {}
+  //load the custom templates of the active theme for the post options
+  $http.get('/admin/api/blog').success(function(data) {
+    $scope.shared.customTemplates = data.CustomTemplates;
+  });
   $scope.change = function() {
     document.getElementById('html-div').innerHTML = '<h1>' + $scope.shared.post.Title + '</h1><br>' + converter.makeHtml($scope.shared.post.Markdown);
     //resize the markdown textarea
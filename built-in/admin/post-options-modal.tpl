@@ -16,6 +16,26 @@
                     <input spellcheck="true" type="text" class="form-control" id="post-meta-description" ng-model="shared.post.MetaDescription" value="{{shared.post.MetaDescription}}">
                 </div>
             </div>
+            <div class="form-group">
+                <label for="post-custom-template" class="col-sm-2 control-label">Template</label>
+                <div class="col-sm-4">
+                    <select class="form-control" id="post-custom-template" ng-model="shared.post.CustomTemplate" ng-options="template for template in shared.customTemplates">
+                        <option value="">Default</option>
+                    </select>
+                </div>
+            </div>
+            <div class="form-group">
+                <label for="post-code-injection-head" class="col-sm-2 control-label">Header code</label>
+                <div class="col-sm-10">
+                    <textarea class="form-control" rows="3" id="post-code-injection-head" ng-model="shared.post.CodeInjectionHead"></textarea>
+                </div>
+            </div>
+            <div class="form-group">
+                <label for="post-code-injection-foot" class="col-sm-2 control-label">Footer code</label>
+                <div class="col-sm-10">
+                    <textarea class="form-control" rows="3" id="post-code-injection-foot" ng-model="shared.post.CodeInjectionFoot"></textarea>
+                </div>
+            </div>
             <div class="form-group">
                 <label for="post-cover" class="col-sm-2 control-label">Cover</label>
                 <div class="col-sm-10">
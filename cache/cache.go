@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"journey/configuration"
+)
+
+// Global cache of the rendered pages of the blog - thread safe and accessible by all requests.
+// Disabled until Initialize is called.
+var Pages = New(-1)
+
+// Function to create the page cache with the size limit from the config
+func Initialize() {
+	Pages = New(configuration.Config.PageCache.MaxSize)
+}
+
+// Key: path of a page and the number of the page for paginated pages
+type Key struct {
+	Path string
+	Page int
+}
+
+// Stats: counters of a cache, used by the admin api
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Size    int64 // Size of all cached pages in bytes
+	MaxSize int64
+}
+
+type entry struct {
+	key   Key
+	body  []byte
+	nonce string // Content-Security-Policy nonce of the request the page was rendered for
+}
+
+// Cache: rendered pages, the least recently used pages are removed once the size limit is reached
+type Cache struct {
+	// Counters are updated atomically and come first to be 64-bit aligned
+	hits   uint64
+	misses uint64
+	sync.Mutex
+	maxSize    int64
+	size       int64
+	entries    map[Key]*list.Element
+	order      *list.List // Most recently used page first
+	generation uint64
+}
+
+// Function to create a cache that holds up to maxSize bytes of pages. A negative size disables the cache.
+func New(maxSize int64) *Cache {
+	return &Cache{maxSize: maxSize, entries: make(map[Key]*list.Element), order: list.New()}
+}
+
+// Function to check if pages are cached at all
+func (c *Cache) Enabled() bool {
+	return c.maxSize >= 0
+}
+
+// Function to get a cached page. The nonce the page was rendered with is replaced with the nonce of the current request.
+func (c *Cache) Get(key Key, nonce string) ([]byte, bool) {
+	if c.maxSize < 0 {
+		return nil, false
+	}
+	c.Lock()
+	element, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+	c.Unlock()
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	cached := element.Value.(*entry)
+	if cached.nonce == "" || cached.nonce == nonce {
+		return cached.body, true
+	}
+	return bytes.Replace(cached.body, []byte(cached.nonce), []byte(nonce), -1), true
+}
+
+// Function to get the current generation of the cache. It changes whenever the cache is purged.
+func (c *Cache) Generation() uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.generation
+}
+
+// Function to add a rendered page. Pages that were rendered before the cache was purged (generation is outdated)
+// could contain old data and are not added.
+func (c *Cache) Add(key Key, body []byte, nonce string, generation uint64) {
+	size := int64(len(body))
+	if c.maxSize < 0 || size > c.maxSize {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, body: body, nonce: nonce})
+	c.size += size
+	for c.size > c.maxSize {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	removed := c.order.Remove(element).(*entry)
+	delete(c.entries, removed.key)
+	c.size -= int64(len(removed.body))
+}
+
+// Function to remove all pages, e.g. because a post or the theme has been changed
+func (c *Cache) Purge() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[Key]*list.Element)
+	c.order.Init()
+	c.size = 0
+	c.generation++
+}
+
+func (c *Cache) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return Stats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses), Entries: len(c.entries), Size: c.size, MaxSize: c.maxSize}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import "testing"
+
+func TestCache(t *testing.T) {
+	c := New(10)
+	first := Key{Path: "/", Page: 1}
+	second := Key{Path: "/page/2/", Page: 2}
+	if _, ok := c.Get(first, ""); ok {
+		t.Error("Expected a miss for an empty cache")
+	}
+	c.Add(first, []byte("<a nonce>"), "nonce", c.Generation())
+	body, ok := c.Get(first, "other")
+	if !ok || string(body) != "<a other>" {
+		t.Errorf("Expected '<a other>', received '%s'", body)
+	}
+	// Adding the second page exceeds the size limit and removes the least recently used page
+	c.Add(second, []byte("second"), "", c.Generation())
+	if _, ok := c.Get(first, ""); ok {
+		t.Error("Expected the first page to be removed")
+	}
+	if body, ok := c.Get(second, ""); !ok || string(body) != "second" {
+		t.Errorf("Expected 'second', received '%s'", body)
+	}
+	// Pages rendered before a purge are not added
+	generation := c.Generation()
+	c.Purge()
+	c.Add(first, []byte("old"), "", generation)
+	if _, ok := c.Get(first, ""); ok {
+		t.Error("Expected a page of an old generation to be ignored")
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 3 || stats.Entries != 0 || stats.Size != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDisabledCache(t *testing.T) {
+	c := New(-1)
+	key := Key{Path: "/", Page: 1}
+	c.Add(key, []byte("page"), "", c.Generation())
+	if _, ok := c.Get(key, ""); ok {
+		t.Error("Expected a disabled cache to never return pages")
+	}
+}
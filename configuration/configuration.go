@@ -18,6 +18,68 @@ type Configuration struct {
 	Url              string
 	HttpsUrl         string
 	UseLetsEncrypt   bool
+	Oidc             OidcConfiguration
+	SecurityHeaders  SecurityHeadersConfiguration
+	Uploads          UploadsConfiguration
+	PageCache        PageCacheConfiguration
+}
+
+// OidcConfiguration: settings for the optional OpenID Connect single sign-on to the admin area
+type OidcConfiguration struct {
+	Enabled       bool
+	Issuer        string
+	ClientId      string
+	ClientSecret  string
+	AutoProvision bool // Create a Journey user on first login if no user with the email address exists
+	DefaultRole   int  // Role of auto provisioned users: 1 = Administrator, 2 = Editor, 3 = Author
+}
+
+// UploadsConfiguration: limits for files uploaded in the admin area (in bytes)
+type UploadsConfiguration struct {
+	MaxFileSize    int64
+	MaxRequestSize int64
+	MaxThemeSize   int64 // Limit of theme archives, applies to the archive and the unpacked files
+}
+
+// PageCacheConfiguration: size limit of the in-memory cache of rendered blog pages (in bytes). A negative size disables the cache.
+type PageCacheConfiguration struct {
+	MaxSize int64
+}
+
+// SecurityHeadersConfiguration: headers that are sent with every response. The admin area and the blog have separate policies.
+type SecurityHeadersConfiguration struct {
+	Blog  SecurityPolicy
+	Admin SecurityPolicy
+}
+
+// SecurityPolicy: an empty field means that the header is not sent
+type SecurityPolicy struct {
+	Hsts                  string // Only sent over https
+	ContentTypeOptions    string
+	FrameOptions          string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	ContentSecurityPolicy string // {nonce} is replaced with a random value for every request. Themes can use it with {{csp_nonce}}.
+}
+
+// Defaults for config files that don't have a SecurityHeaders section yet. The blog policy doesn't restrict scripts since most themes use inline scripts.
+var defaultSecurityHeaders = SecurityHeadersConfiguration{
+	Blog: SecurityPolicy{
+		Hsts:                  "max-age=31536000",
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		ContentSecurityPolicy: "object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+	},
+	Admin: SecurityPolicy{
+		Hsts:                  "max-age=31536000",
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "same-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		ContentSecurityPolicy: "object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+	},
 }
 
 func NewConfiguration() *Configuration {
@@ -39,8 +101,13 @@ func NewConfiguration() *Configuration {
 	return &config
 }
 
-// Global config - thread safe and accessible from all packages
-var Config = NewConfiguration()
+// Global config - thread safe and accessible from all packages. Loaded by Initialize.
+var Config *Configuration
+
+// Function to read the config.json. Has to be called after the paths have been initialized.
+func Initialize() {
+	Config = NewConfiguration()
+}
 
 func (c *Configuration) save() error {
 	data, err := json.Marshal(c)
@@ -56,6 +123,7 @@ func (c *Configuration) load() error {
 	if err != nil {
 		return err
 	}
+	c.SecurityHeaders = defaultSecurityHeaders
 	err = json.Unmarshal(data, c)
 	if err != nil {
 		return err
@@ -88,6 +156,28 @@ func (c *Configuration) load() error {
 		c.HttpsUrl = c.HttpsUrl[0 : len(c.HttpsUrl)-1]
 		configWasChanged = true
 	}
+	// Make sure single sign-on is configured properly before enabling it
+	if c.Oidc.Enabled && (c.Oidc.Issuer == "" || c.Oidc.ClientId == "") {
+		log.Println("Error: " + filenames.ConfigFilename + " enables OpenID Connect but is missing the issuer or client id. Disabling single sign-on.")
+		c.Oidc.Enabled = false
+	}
+	if c.Oidc.DefaultRole < 1 || c.Oidc.DefaultRole > 3 {
+		c.Oidc.DefaultRole = 3
+	}
+	if c.Uploads.MaxFileSize <= 0 {
+		c.Uploads.MaxFileSize = 10 * 1024 * 1024
+	}
+	if c.Uploads.MaxRequestSize <= 0 {
+		c.Uploads.MaxRequestSize = 5 * c.Uploads.MaxFileSize
+	} else if c.Uploads.MaxRequestSize < c.Uploads.MaxFileSize {
+		c.Uploads.MaxRequestSize = c.Uploads.MaxFileSize
+	}
+	if c.Uploads.MaxThemeSize <= 0 {
+		c.Uploads.MaxThemeSize = 50 * 1024 * 1024
+	}
+	if c.PageCache.MaxSize == 0 {
+		c.PageCache.MaxSize = 32 * 1024 * 1024
+	}
 	// Check if all fields are filled out
 	cReflected := reflect.ValueOf(*c)
 	for i := 0; i < cReflected.NumField(); i++ {
@@ -108,7 +198,7 @@ func (c *Configuration) load() error {
 
 func (c *Configuration) create() error {
 	// TODO: Change default port
-	*c = Configuration{HttpHostAndPort: ":8084", HttpsHostAndPort: ":8085", HttpsUsage: "None", Url: "127.0.0.1:8084", HttpsUrl: "127.0.0.1:8085"}
+	*c = Configuration{HttpHostAndPort: ":8084", HttpsHostAndPort: ":8085", HttpsUsage: "None", Url: "127.0.0.1:8084", HttpsUrl: "127.0.0.1:8085", SecurityHeaders: defaultSecurityHeaders}
 	err := c.save()
 	if err != nil {
 		log.Println("Error: couldn't create " + filenames.ConfigFilename)
@@ -1,7 +1,12 @@
 package database
 
+import (
+	"database/sql"
+)
+
 const stmtDeletePostTagsByPostId = "DELETE FROM posts_tags WHERE post_id = ?"
 const stmtDeletePostById = "DELETE FROM posts WHERE id = ?"
+const stmtDeleteTokenByIdAndUser = "DELETE FROM tokens WHERE id = ? AND user_id = ?"
 
 func DeletePostTagsForPostId(postId int64) error {
 	writeDB, err := readDB.Begin()
@@ -30,3 +35,26 @@ func DeletePostById(id int64) error {
 	}
 	return writeDB.Commit()
 }
+
+func DeleteTokenByIdAndUser(id int64, userId int64) error {
+	writeDB, err := readDB.Begin()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	result, err := writeDB.Exec(stmtDeleteTokenByIdAndUser, id, userId)
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		_ = writeDB.Rollback()
+		return sql.ErrNoRows
+	}
+	return writeDB.Commit()
+}
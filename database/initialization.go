@@ -36,7 +36,10 @@ var stmtInitialization = `CREATE TABLE IF NOT EXISTS
 		updated_at			datetime,
 		updated_by			integer,
 		published_at		datetime,
-		published_by		integer
+		published_by		integer,
+		codeinjection_head	text,
+		codeinjection_foot	text,
+		custom_template		varchar(100)
 	);
 	CREATE TABLE IF NOT EXISTS
 	users (
@@ -100,7 +103,7 @@ var stmtInitialization = `CREATE TABLE IF NOT EXISTS
 	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (3, ?, 'email', '', 'blog', ?, 1, ?, 1);
 	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (4, ?, 'logo', '/public/images/blog-logo.jpg', 'blog', ?, 1, ?, 1);
 	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (5, ?, 'cover', '/public/images/blog-cover.jpg', 'blog', ?, 1, ?, 1);
-	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (6, ?, 'postsPerPage', 5, 'blog', ?, 1, ?, 1);
+	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (6, ?, 'postsPerPage', 0, 'blog', ?, 1, ?, 1);
 	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (7, ?, 'activeTheme', 'promenade', 'theme', ?, 1, ?, 1);
 	INSERT OR IGNORE INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (8, ?, 'navigation', '[{"label":"Home", "url":"/"}]', 'blog', ?, 1, ?, 1);
 	CREATE TABLE IF NOT EXISTS
@@ -124,6 +127,39 @@ var stmtInitialization = `CREATE TABLE IF NOT EXISTS
 		role_id	integer NOT NULL,
 		user_id	integer NOT NULL
 	);
+	CREATE TABLE IF NOT EXISTS
+	tokens (
+		id				integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		uuid			varchar(36) NOT NULL,
+		name			varchar(150) NOT NULL,
+		token_hash		varchar(64) NOT NULL UNIQUE,
+		scopes			varchar(150) NOT NULL,
+		user_id			integer NOT NULL,
+		last_used_at	datetime,
+		created_at		datetime NOT NULL,
+		created_by		integer NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS
+	audit_log (
+		id			integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		user_id		integer NOT NULL DEFAULT 0,
+		user_name	varchar(150) NOT NULL,
+		action		varchar(50) NOT NULL,
+		object_type	varchar(50) NOT NULL,
+		object_id	integer NOT NULL DEFAULT 0,
+		object_name	text,
+		ip			varchar(45) NOT NULL,
+		changes		text,
+		created_at	datetime NOT NULL
+	);
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;
 	`
 
 func Initialize() error {
@@ -153,6 +189,42 @@ func Initialize() error {
 	if err != nil {
 		return err
 	}
+	err = checkPostColumns()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Function to add columns to the posts table that databases created by older versions (or Ghost) don't have yet.
+func checkPostColumns() error {
+	rows, err := readDB.Query(stmtRetrievePostColumns)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var id, notNull, primaryKey int
+		var columnType string
+		var defaultValue interface{}
+		err = rows.Scan(&id, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	for column, columnType := range map[string]string{"codeinjection_head": "text", "codeinjection_foot": "text", "custom_template": "varchar(100)"} {
+		if columns[column] {
+			continue
+		}
+		_, err = readDB.Exec("ALTER TABLE posts ADD COLUMN " + column + " " + columnType)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -214,8 +286,8 @@ func checkBlogSettings() error {
 	row = readDB.QueryRow(stmtRetrieveBlog, "postsPerPage")
 	err = row.Scan(&tempBlog.PostsPerPage)
 	if err != nil {
-		// Insert postsPerPage
-		err = insertSettingInt64("postsPerPage", 5, "blog", date.GetCurrentTime(), 1)
+		// Insert postsPerPage (0 = use the default of the theme)
+		err = insertSettingInt64("postsPerPage", 0, "blog", date.GetCurrentTime(), 1)
 		if err != nil {
 			return err
 		}
@@ -241,5 +313,47 @@ func checkBlogSettings() error {
 			return err
 		}
 	}
+	// Check for secondaryNavigation
+	row = readDB.QueryRow(stmtRetrieveBlog, "secondaryNavigation")
+	err = row.Scan(&navigation)
+	if err != nil {
+		// Insert secondaryNavigation
+		err = insertSettingString("secondaryNavigation", "[]", "blog", date.GetCurrentTime(), 1)
+		if err != nil {
+			return err
+		}
+	}
+	// Check for language
+	row = readDB.QueryRow(stmtRetrieveBlog, "language")
+	err = row.Scan(&tempBlog.Language)
+	if err != nil {
+		// Insert language
+		err = insertSettingString("language", "en", "blog", date.GetCurrentTime(), 1)
+		if err != nil {
+			return err
+		}
+	}
+	// Check for timezone
+	row = readDB.QueryRow(stmtRetrieveBlog, "timezone")
+	err = row.Scan(&tempBlog.Timezone)
+	if err != nil {
+		// Insert timezone
+		err = insertSettingString("timezone", "UTC", "blog", date.GetCurrentTime(), 1)
+		if err != nil {
+			return err
+		}
+	}
+	// Check for code injection
+	for _, key := range []string{"codeInjectionHead", "codeInjectionFoot"} {
+		var codeInjection []byte
+		row = readDB.QueryRow(stmtRetrieveBlog, key)
+		err = row.Scan(&codeInjection)
+		if err != nil {
+			err = insertSettingString(key, "", "blog", date.GetCurrentTime(), 1)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"journey/filenames"
+	"journey/structure"
+)
+
+// Function to use a new database in a temporary folder. The returned function restores the previous paths.
+func useTestDatabase(t *testing.T) func() {
+	t.Helper()
+	databasePath, err := ioutil.TempDir("", "journey-database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseFilepath, databaseFilename := filenames.DatabaseFilepath, filenames.DatabaseFilename
+	filenames.DatabaseFilepath, filenames.DatabaseFilename = databasePath, filepath.Join(databasePath, "journey.db")
+	restore := func() {
+		filenames.DatabaseFilepath, filenames.DatabaseFilename = databaseFilepath, databaseFilename
+		os.RemoveAll(databasePath)
+	}
+	if err := Initialize(); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return restore
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	defer useTestDatabase(t)()
+	createdAt := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, action := range []string{"login", "post_create"} {
+		if err := InsertAuditEntry(&structure.AuditEntry{UserId: 1, UserName: []byte("admin"), Action: action, ObjectType: "post", CreatedAt: &createdAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, statement := range []string{
+		"UPDATE audit_log SET action = 'nothing'",
+		"UPDATE audit_log SET user_name = 'someone' WHERE id = 1",
+		"DELETE FROM audit_log",
+		"DELETE FROM audit_log WHERE id = 2",
+	} {
+		if _, err := readDB.Exec(statement); err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("Expected %q to be rejected, received %v", statement, err)
+		}
+	}
+	entries, err := RetrieveAuditEntries(&structure.AuditFilter{}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "post_create" || entries[1].Action != "login" || string(entries[1].UserName) != "admin" {
+		t.Errorf("Expected the audit log to be unchanged, received %+v", entries)
+	}
+	// Opening the database again keeps the triggers
+	if err := Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDB.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("Expected the audit log to stay append-only after the database was opened again")
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"time"
 
 	"github.com/satori/go.uuid"
+	"journey/structure"
 )
 
-const stmtInsertPost = "INSERT INTO posts (id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, created_at, created_by, updated_at, updated_by, published_at, published_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+const stmtInsertPost = "INSERT INTO posts (id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, created_at, created_by, updated_at, updated_by, published_at, published_by, codeinjection_head, codeinjection_foot, custom_template) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 const stmtInsertUser = "INSERT INTO users (id, uuid, name, slug, password, email, image, cover, created_at, created_by, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 const stmtInsertRoleUser = "INSERT INTO roles_users (id, role_id, user_id) VALUES (?, ?, ?)"
 const stmtInsertTag = "INSERT INTO tags (id, uuid, name, slug, created_at, created_by, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
 const stmtInsertPostTag = "INSERT INTO posts_tags (id, post_id, tag_id) VALUES (?, ?, ?)"
 const stmtInsertSetting = "INSERT INTO settings (id, uuid, key, value, type, created_at, created_by, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+const stmtInsertAuditEntry = "INSERT INTO audit_log (id, user_id, user_name, action, object_type, object_id, object_name, ip, changes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+const stmtInsertToken = "INSERT INTO tokens (id, uuid, name, token_hash, scopes, user_id, created_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
 
-func InsertPost(title []byte, slug string, markdown []byte, html []byte, featured bool, isPage bool, published bool, metaDescription []byte, image []byte, createdAt time.Time, createdBy int64) (int64, error) {
+func InsertPost(title []byte, slug string, markdown []byte, html []byte, featured bool, isPage bool, published bool, metaDescription []byte, image []byte, codeInjectionHead []byte, codeInjectionFoot []byte, customTemplate string, createdAt time.Time, createdBy int64) (int64, error) {
 
 	status := "draft"
 	if published {
@@ -27,9 +30,9 @@ func InsertPost(title []byte, slug string, markdown []byte, html []byte, feature
 	}
 	var result sql.Result
 	if published {
-		result, err = writeDB.Exec(stmtInsertPost, nil, uuid.NewV4().String(), title, slug, markdown, html, featured, isPage, status, metaDescription, image, createdBy, createdAt, createdBy, createdAt, createdBy, createdAt, createdBy)
+		result, err = writeDB.Exec(stmtInsertPost, nil, uuid.NewV4().String(), title, slug, markdown, html, featured, isPage, status, metaDescription, image, createdBy, createdAt, createdBy, createdAt, createdBy, createdAt, createdBy, codeInjectionHead, codeInjectionFoot, customTemplate)
 	} else {
-		result, err = writeDB.Exec(stmtInsertPost, nil, uuid.NewV4().String(), title, slug, markdown, html, featured, isPage, status, metaDescription, image, createdBy, createdAt, createdBy, createdAt, createdBy, nil, nil)
+		result, err = writeDB.Exec(stmtInsertPost, nil, uuid.NewV4().String(), title, slug, markdown, html, featured, isPage, status, metaDescription, image, createdBy, createdAt, createdBy, createdAt, createdBy, nil, nil, codeInjectionHead, codeInjectionFoot, customTemplate)
 	}
 	if err != nil {
 		_ = writeDB.Rollback()
@@ -109,6 +112,39 @@ func InsertPostTag(postId int64, tagId int64) error {
 	return writeDB.Commit()
 }
 
+func InsertToken(name []byte, tokenHash string, scopes string, userId int64, createdAt time.Time) (int64, error) {
+	writeDB, err := readDB.Begin()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return 0, err
+	}
+	result, err := writeDB.Exec(stmtInsertToken, nil, uuid.NewV4().String(), name, tokenHash, scopes, userId, createdAt, userId)
+	if err != nil {
+		_ = writeDB.Rollback()
+		return 0, err
+	}
+	tokenId, err := result.LastInsertId()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return 0, err
+	}
+	return tokenId, writeDB.Commit()
+}
+
+func InsertAuditEntry(entry *structure.AuditEntry) error {
+	writeDB, err := readDB.Begin()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	_, err = writeDB.Exec(stmtInsertAuditEntry, nil, entry.UserId, entry.UserName, entry.Action, entry.ObjectType, entry.ObjectId, entry.ObjectName, entry.Ip, entry.Changes, entry.CreatedAt)
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	return writeDB.Commit()
+}
+
 func insertSettingString(key string, value string, settingType string, createdAt time.Time, createdBy int64) error {
 	writeDB, err := readDB.Begin()
 	if err != nil {
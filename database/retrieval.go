@@ -3,22 +3,61 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"journey/structure"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// queryField: a field that content queries can filter and sort by
+type queryField struct {
+	condition string // The comparison (e.g. "= ?" or "IN (?, ?)") is inserted at %s
+	column    string // Used for sorting. Empty if the field can't be sorted by.
+	kind      string // Type of the values: text, int, bool or date
+}
+
+// Fields of posts, tags and authors that can be used in content queries. Only these are ever inserted into the sql statements.
+var postQueryFields = map[string]queryField{
+	"id":           {condition: "id %s", column: "id", kind: "int"},
+	"slug":         {condition: "slug %s", column: "slug", kind: "text"},
+	"title":        {condition: "title %s", column: "title", kind: "text"},
+	"featured":     {condition: "featured %s", column: "featured", kind: "bool"},
+	"published_at": {condition: "published_at %s", column: "published_at", kind: "date"},
+	"tag":          {condition: "id IN (SELECT posts_tags.post_id FROM posts_tags, tags WHERE posts_tags.tag_id = tags.id AND tags.slug %s)", kind: "text"},
+	"tags":         {condition: "id IN (SELECT posts_tags.post_id FROM posts_tags, tags WHERE posts_tags.tag_id = tags.id AND tags.slug %s)", kind: "text"},
+	"author":       {condition: "author_id IN (SELECT id FROM users WHERE slug %s)", kind: "text"},
+	"authors":      {condition: "author_id IN (SELECT id FROM users WHERE slug %s)", kind: "text"},
+}
+var tagQueryFields = map[string]queryField{
+	"id":          {condition: "id %s", column: "id", kind: "int"},
+	"slug":        {condition: "slug %s", column: "slug", kind: "text"},
+	"name":        {condition: "name %s", column: "name", kind: "text"},
+	"count.posts": {column: "post_count"},
+}
+var userQueryFields = map[string]queryField{
+	"id":          {condition: "id %s", column: "id", kind: "int"},
+	"slug":        {condition: "slug %s", column: "slug", kind: "text"},
+	"name":        {condition: "name %s", column: "name", kind: "text"},
+	"count.posts": {column: "post_count"},
+}
+
 const stmtRetrievePostsCount = "SELECT count(*) FROM posts WHERE page = 0 AND status = 'published'"
 const stmtRetrievePostsCountByUser = "SELECT count(*) FROM posts WHERE page = 0 AND status = 'published' AND author_id = ?"
 const stmtRetrievePostsCountByTag = "SELECT count(*) FROM posts, posts_tags WHERE posts_tags.post_id = posts.id AND posts_tags.tag_id = ? AND page = 0 AND status = 'published'"
-const stmtRetrievePostsForIndex = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at FROM posts WHERE page = 0 AND status = 'published' ORDER BY published_at DESC LIMIT ? OFFSET ?"
-const stmtRetrievePostsForApi = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at FROM posts ORDER BY id DESC LIMIT ? OFFSET ?"
-const stmtRetrievePostsByUser = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at FROM posts WHERE page = 0 AND status = 'published' AND author_id = ? ORDER BY published_at DESC LIMIT ? OFFSET ?"
-const stmtRetrievePostsByTag = "SELECT posts.id, posts.uuid, posts.title, posts.slug, posts.markdown, posts.html, posts.featured, posts.page, posts.status, posts.meta_description, posts.image, posts.author_id, posts.published_at FROM posts, posts_tags WHERE posts_tags.post_id = posts.id AND posts_tags.tag_id = ? AND page = 0 AND status = 'published' ORDER BY posts.published_at DESC LIMIT ? OFFSET ?"
-const stmtRetrievePostById = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at FROM posts WHERE id = ?"
-const stmtRetrievePostBySlug = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at FROM posts WHERE slug = ?"
-const stmtRetrieveUserById = "SELECT id, name, slug, email, image, cover, bio, website, location FROM users WHERE id = ?"
-const stmtRetrieveUserBySlug = "SELECT id, name, slug, email, image, cover, bio, website, location FROM users WHERE slug = ?"
-const stmtRetrieveUserByName = "SELECT id, name, slug, email, image, cover, bio, website, location FROM users WHERE name = ?"
+const stmtRetrievePostsForIndex = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE page = 0 AND status = 'published' ORDER BY published_at DESC LIMIT ? OFFSET ?"
+const stmtRetrievePostsForApi = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts ORDER BY id DESC LIMIT ? OFFSET ?"
+const stmtRetrievePostsByUser = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE page = 0 AND status = 'published' AND author_id = ? ORDER BY published_at DESC LIMIT ? OFFSET ?"
+const stmtRetrievePostsByTag = "SELECT posts.id, posts.uuid, posts.title, posts.slug, posts.markdown, posts.html, posts.featured, posts.page, posts.status, posts.meta_description, posts.image, posts.author_id, posts.published_at, posts.codeinjection_head, posts.codeinjection_foot, posts.custom_template FROM posts, posts_tags WHERE posts_tags.post_id = posts.id AND posts_tags.tag_id = ? AND page = 0 AND status = 'published' ORDER BY posts.published_at DESC LIMIT ? OFFSET ?"
+const stmtRetrieveNextPost = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE page = 0 AND status = 'published' AND (published_at > ? OR (published_at = ? AND id > ?)) AND (? = 0 OR author_id = ?) AND (? = 0 OR (SELECT tag_id FROM posts_tags WHERE post_id = posts.id ORDER BY id LIMIT 1) = ?) ORDER BY published_at ASC, id ASC LIMIT 1"
+const stmtRetrievePreviousPost = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE page = 0 AND status = 'published' AND (published_at < ? OR (published_at = ? AND id < ?)) AND (? = 0 OR author_id = ?) AND (? = 0 OR (SELECT tag_id FROM posts_tags WHERE post_id = posts.id ORDER BY id LIMIT 1) = ?) ORDER BY published_at DESC, id DESC LIMIT 1"
+const stmtRetrievePostById = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE id = ?"
+const stmtRetrievePostBySlug = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE slug = ?"
+const stmtRetrieveUserById = "SELECT id, name, slug, email, image, cover, bio, website, location, IFNULL((SELECT role_id FROM roles_users WHERE user_id = users.id), 3) FROM users WHERE id = ?"
+const stmtRetrieveUserBySlug = "SELECT id, name, slug, email, image, cover, bio, website, location, IFNULL((SELECT role_id FROM roles_users WHERE user_id = users.id), 3) FROM users WHERE slug = ?"
+const stmtRetrieveUserByName = "SELECT id, name, slug, email, image, cover, bio, website, location, IFNULL((SELECT role_id FROM roles_users WHERE user_id = users.id), 3) FROM users WHERE name = ?"
+const stmtRetrieveUserByEmail = "SELECT id, name, slug, email, image, cover, bio, website, location, IFNULL((SELECT role_id FROM roles_users WHERE user_id = users.id), 3) FROM users WHERE lower(email) = lower(?)"
 const stmtRetrieveTags = "SELECT tag_id FROM posts_tags WHERE post_id = ?"
 const stmtRetrieveTagById = "SELECT id, name, slug FROM tags WHERE id = ?"
 const stmtRetrieveTagBySlug = "SELECT id, name, slug FROM tags WHERE slug = ?"
@@ -26,7 +65,16 @@ const stmtRetrieveTagIdBySlug = "SELECT id FROM tags WHERE slug = ?"
 const stmtRetrieveHashedPasswordByName = "SELECT password FROM users WHERE name = ?"
 const stmtRetrieveUsersCount = "SELECT count(*) FROM users"
 const stmtRetrieveBlog = "SELECT value FROM settings WHERE key = ?"
+const stmtRetrievePostColumns = "PRAGMA table_info(posts)"
 const stmtRetrievePostCreationDateById = "SELECT created_at FROM posts WHERE id = ?"
+const stmtRetrieveTokensByUser = "SELECT id, name, scopes, user_id, created_at, last_used_at FROM tokens WHERE user_id = ? ORDER BY id DESC"
+const stmtRetrieveTokenByHash = "SELECT id, name, scopes, user_id, created_at, last_used_at FROM tokens WHERE token_hash = ?"
+const stmtRetrievePostsByQuery = "SELECT id, uuid, title, slug, markdown, html, featured, page, status, meta_description, image, author_id, published_at, codeinjection_head, codeinjection_foot, custom_template FROM posts WHERE page = 0 AND status = 'published'"
+const stmtRetrieveTagsByQuery = "SELECT id, name, slug, %s FROM tags"
+const stmtRetrieveUsersByQuery = "SELECT id, name, slug, email, image, cover, bio, website, location, IFNULL((SELECT role_id FROM roles_users WHERE user_id = users.id), 3), %s FROM users"
+const stmtCountPostsOfTag = "(SELECT count(*) FROM posts, posts_tags WHERE posts_tags.post_id = posts.id AND posts_tags.tag_id = tags.id AND page = 0 AND status = 'published')"
+const stmtCountPostsOfUser = "(SELECT count(*) FROM posts WHERE posts.author_id = users.id AND page = 0 AND status = 'published')"
+const stmtRetrieveAuditEntries = "SELECT id, user_id, user_name, action, object_type, object_id, object_name, ip, changes, created_at FROM audit_log WHERE (? = 0 OR user_id = ?) AND (? = '' OR action = ?) AND (? = '' OR object_type = ?) AND (? IS NULL OR created_at >= ?) AND (? IS NULL OR created_at <= ?) ORDER BY id DESC LIMIT ? OFFSET ?"
 
 func RetrievePostById(id int64) (*structure.Post, error) {
 	// Retrieve post
@@ -40,6 +88,17 @@ func RetrievePostBySlug(slug string) (*structure.Post, error) {
 	return extractPost(row)
 }
 
+// Function to get the published post that comes after (or before) a post by publication date. If userId or
+// primaryTagId is not 0, only posts of that author or with that primary tag are used. Returns sql.ErrNoRows if there is no such post.
+func RetrieveAdjacentPost(post *structure.Post, next bool, userId int64, primaryTagId int64) (*structure.Post, error) {
+	statement := stmtRetrievePreviousPost
+	if next {
+		statement = stmtRetrieveNextPost
+	}
+	row := readDB.QueryRow(statement, post.Date, post.Date, post.Id, userId, userId, primaryTagId, primaryTagId)
+	return extractPost(row)
+}
+
 func RetrievePostsByUser(userId int64, limit int64, offset int64) ([]structure.Post, error) {
 	// Retrieve posts
 	rows, err := readDB.Query(stmtRetrievePostsByUser, userId, limit, offset)
@@ -110,10 +169,12 @@ func extractPosts(rows *sql.Rows) (*[]structure.Post, error) {
 		post := structure.Post{}
 		var userId int64
 		var status string
-		err := rows.Scan(&post.Id, &post.Uuid, &post.Title, &post.Slug, &post.Markdown, &post.Html, &post.IsFeatured, &post.IsPage, &status, &post.MetaDescription, &post.Image, &userId, &post.Date)
+		var customTemplate sql.NullString
+		err := rows.Scan(&post.Id, &post.Uuid, &post.Title, &post.Slug, &post.Markdown, &post.Html, &post.IsFeatured, &post.IsPage, &status, &post.MetaDescription, &post.Image, &userId, &post.Date, &post.CodeInjectionHead, &post.CodeInjectionFoot, &customTemplate)
 		if err != nil {
 			return nil, err
 		}
+		post.CustomTemplate = customTemplate.String
 		// If there was no publication date attached to the post, make its creation date the date of the post
 		if post.Date == nil {
 			post.Date, err = retrievePostCreationDateById(post.Id)
@@ -146,10 +207,12 @@ func extractPost(row *sql.Row) (*structure.Post, error) {
 	post := structure.Post{}
 	var userId int64
 	var status string
-	err := row.Scan(&post.Id, &post.Uuid, &post.Title, &post.Slug, &post.Markdown, &post.Html, &post.IsFeatured, &post.IsPage, &status, &post.MetaDescription, &post.Image, &userId, &post.Date)
+	var customTemplate sql.NullString
+	err := row.Scan(&post.Id, &post.Uuid, &post.Title, &post.Slug, &post.Markdown, &post.Html, &post.IsFeatured, &post.IsPage, &status, &post.MetaDescription, &post.Image, &userId, &post.Date, &post.CodeInjectionHead, &post.CodeInjectionFoot, &customTemplate)
 	if err != nil {
 		return nil, err
 	}
+	post.CustomTemplate = customTemplate.String
 	// If there was no publication date attached to the post, make its creation date the date of the post
 	if post.Date == nil {
 		post.Date, err = retrievePostCreationDateById(post.Id)
@@ -224,7 +287,7 @@ func RetrieveUser(id int64) (*structure.User, error) {
 	user := structure.User{}
 	// Retrieve user
 	row := readDB.QueryRow(stmtRetrieveUserById, id)
-	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location)
+	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location, &user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +298,7 @@ func RetrieveUserBySlug(slug string) (*structure.User, error) {
 	user := structure.User{}
 	// Retrieve user
 	row := readDB.QueryRow(stmtRetrieveUserBySlug, slug)
-	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location)
+	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location, &user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +309,18 @@ func RetrieveUserByName(name []byte) (*structure.User, error) {
 	user := structure.User{}
 	// Retrieve user
 	row := readDB.QueryRow(stmtRetrieveUserByName, name)
-	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location)
+	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location, &user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func RetrieveUserByEmail(email []byte) (*structure.User, error) {
+	user := structure.User{}
+	// Retrieve user
+	row := readDB.QueryRow(stmtRetrieveUserByEmail, email)
+	err := row.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location, &user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -320,6 +394,232 @@ func RetrieveHashedPasswordForUser(name []byte) ([]byte, error) {
 	return hashedPassword, nil
 }
 
+func RetrieveTokensByUser(userId int64) ([]structure.Token, error) {
+	tokens := make([]structure.Token, 0)
+	// Retrieve tokens
+	rows, err := readDB.Query(stmtRetrieveTokensByUser, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		token := structure.Token{}
+		var scopes string
+		err := rows.Scan(&token.Id, &token.Name, &scopes, &token.UserId, &token.CreatedAt, &token.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Split(scopes, ",")
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+func RetrieveTokenByHash(tokenHash string) (*structure.Token, error) {
+	token := structure.Token{}
+	var scopes string
+	// Retrieve token
+	row := readDB.QueryRow(stmtRetrieveTokenByHash, tokenHash)
+	err := row.Scan(&token.Id, &token.Name, &scopes, &token.UserId, &token.CreatedAt, &token.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	return &token, nil
+}
+
+func RetrieveAuditEntries(filter *structure.AuditFilter, limit int64, offset int64) ([]structure.AuditEntry, error) {
+	entries := make([]structure.AuditEntry, 0)
+	// Retrieve audit log entries
+	rows, err := readDB.Query(stmtRetrieveAuditEntries, filter.UserId, filter.UserId, filter.Action, filter.Action, filter.ObjectType, filter.ObjectType, filter.From, filter.From, filter.To, filter.To, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		entry := structure.AuditEntry{}
+		err := rows.Scan(&entry.Id, &entry.UserId, &entry.UserName, &entry.Action, &entry.ObjectType, &entry.ObjectId, &entry.ObjectName, &entry.Ip, &entry.Changes, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func RetrievePostsByQuery(query *structure.ContentQuery) ([]structure.Post, error) {
+	statement, arguments, err := buildContentQuery(stmtRetrievePostsByQuery, true, postQueryFields, query, "published_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	// Retrieve posts
+	rows, err := readDB.Query(statement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	posts, err := extractPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return *posts, nil
+}
+
+func RetrieveTagsByQuery(query *structure.ContentQuery) ([]structure.Tag, error) {
+	countColumn := "0"
+	if query.IncludeCount {
+		countColumn = stmtCountPostsOfTag
+	}
+	statement, arguments, err := buildContentQuery(fmt.Sprintf(stmtRetrieveTagsByQuery, countColumn+" AS post_count"), false, tagQueryFields, query, "name ASC")
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]structure.Tag, 0)
+	// Retrieve tags
+	rows, err := readDB.Query(statement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		tag := structure.Tag{}
+		err := rows.Scan(&tag.Id, &tag.Name, &tag.Slug, &tag.PostCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func RetrieveUsersByQuery(query *structure.ContentQuery) ([]structure.User, error) {
+	countColumn := "0"
+	if query.IncludeCount {
+		countColumn = stmtCountPostsOfUser
+	}
+	statement, arguments, err := buildContentQuery(fmt.Sprintf(stmtRetrieveUsersByQuery, countColumn+" AS post_count"), false, userQueryFields, query, "name ASC")
+	if err != nil {
+		return nil, err
+	}
+	users := make([]structure.User, 0)
+	// Retrieve users
+	rows, err := readDB.Query(statement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		user := structure.User{}
+		err := rows.Scan(&user.Id, &user.Name, &user.Slug, &user.Email, &user.Image, &user.Cover, &user.Bio, &user.Website, &user.Location, &user.Role, &user.PostCount)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Function to add the filters, order and limit of a content query to a statement. All values are passed as arguments of the statement.
+func buildContentQuery(statement string, hasWhere bool, fields map[string]queryField, query *structure.ContentQuery, defaultOrder string) (string, []interface{}, error) {
+	var buffer strings.Builder
+	buffer.WriteString(statement)
+	arguments := make([]interface{}, 0)
+	for _, filter := range query.Filters {
+		field, ok := fields[filter.Field]
+		if !ok || field.condition == "" {
+			return "", nil, errors.New("can't filter by " + filter.Field)
+		}
+		if len(filter.Values) == 0 {
+			return "", nil, errors.New("no value to filter " + filter.Field + " by")
+		}
+		comparison := ""
+		switch filter.Operator {
+		case "=", "!=":
+			if len(filter.Values) == 1 {
+				comparison = "= ?"
+			} else {
+				comparison = "IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(filter.Values)), ", ") + ")"
+			}
+		case ">", ">=", "<", "<=":
+			if len(filter.Values) != 1 || field.kind == "bool" {
+				return "", nil, errors.New("can't compare " + filter.Field + " with " + filter.Operator)
+			}
+			comparison = filter.Operator + " ?"
+		default:
+			return "", nil, errors.New("unknown operator " + filter.Operator)
+		}
+		condition := fmt.Sprintf(field.condition, comparison)
+		if filter.Operator == "!=" {
+			condition = "NOT (" + condition + ")"
+		}
+		if hasWhere {
+			buffer.WriteString(" AND ")
+		} else {
+			buffer.WriteString(" WHERE ")
+			hasWhere = true
+		}
+		buffer.WriteString(condition)
+		for _, value := range filter.Values {
+			argument, err := queryArgument(field.kind, value)
+			if err != nil {
+				return "", nil, errors.New("invalid value for " + filter.Field + ": " + value)
+			}
+			arguments = append(arguments, argument)
+		}
+	}
+	buffer.WriteString(" ORDER BY ")
+	if len(query.Order) == 0 {
+		buffer.WriteString(defaultOrder)
+	}
+	for index, order := range query.Order {
+		field, ok := fields[order.Field]
+		if !ok || field.column == "" {
+			return "", nil, errors.New("can't order by " + order.Field)
+		}
+		if index != 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(field.column)
+		if order.Descending {
+			buffer.WriteString(" DESC")
+		} else {
+			buffer.WriteString(" ASC")
+		}
+	}
+	buffer.WriteString(" LIMIT ?")
+	arguments = append(arguments, query.Limit)
+	return buffer.String(), arguments, nil
+}
+
+func queryArgument(kind string, value string) (interface{}, error) {
+	switch kind {
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "date":
+		// Dates are stored in UTC, so they can be compared as text
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date.UTC(), nil
+			}
+		}
+		return nil, errors.New("invalid date: " + value)
+	default:
+		return value, nil
+	}
+}
+
 func RetrieveBlog() (*structure.Blog, error) {
 	tempBlog := structure.Blog{}
 	// Title
@@ -375,6 +675,38 @@ func RetrieveBlog() (*structure.Blog, error) {
 	if err != nil {
 		return &tempBlog, err
 	}
+	row = readDB.QueryRow(stmtRetrieveBlog, "secondaryNavigation")
+	err = row.Scan(&navigation)
+	if err != nil {
+		return &tempBlog, err
+	}
+	tempBlog.SecondaryNavigationItems, err = makeNavigation(navigation)
+	if err != nil {
+		return &tempBlog, err
+	}
+	// Language
+	row = readDB.QueryRow(stmtRetrieveBlog, "language")
+	err = row.Scan(&tempBlog.Language)
+	if err != nil {
+		return &tempBlog, err
+	}
+	// Timezone
+	row = readDB.QueryRow(stmtRetrieveBlog, "timezone")
+	err = row.Scan(&tempBlog.Timezone)
+	if err != nil {
+		return &tempBlog, err
+	}
+	// Code injection
+	row = readDB.QueryRow(stmtRetrieveBlog, "codeInjectionHead")
+	err = row.Scan(&tempBlog.CodeInjectionHead)
+	if err != nil {
+		return &tempBlog, err
+	}
+	row = readDB.QueryRow(stmtRetrieveBlog, "codeInjectionFoot")
+	err = row.Scan(&tempBlog.CodeInjectionFoot)
+	if err != nil {
+		return &tempBlog, err
+	}
 	return &tempBlog, err
 }
 
@@ -388,6 +720,21 @@ func RetrieveActiveTheme() (*string, error) {
 	return &activeTheme, nil
 }
 
+// Function to get the values of the custom settings of a theme (as json). Returns nil if they have never been saved.
+func RetrieveThemeSettings(theme string) ([]byte, error) {
+	var values []byte
+	row := readDB.QueryRow(stmtRetrieveBlog, themeSettingsKey(theme))
+	err := row.Scan(&values)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return values, err
+}
+
+func themeSettingsKey(theme string) string {
+	return "customSettings." + theme
+}
+
 func RetrieveUsersCount() int {
 	userCount := -1
 	row := readDB.QueryRow(stmtRetrieveUsersCount)
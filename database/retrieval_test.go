@@ -0,0 +1,54 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"journey/structure"
+)
+
+func TestBuildContentQuery(t *testing.T) {
+	tests := []struct {
+		query     structure.ContentQuery
+		statement string
+		arguments []interface{}
+	}{
+		{
+			structure.ContentQuery{Limit: 15},
+			"SELECT id FROM posts WHERE page = 0 ORDER BY published_at DESC LIMIT ?",
+			[]interface{}{int64(15)},
+		},
+		{
+			structure.ContentQuery{Limit: -1, Filters: []structure.ContentFilter{{Field: "featured", Operator: "=", Values: []string{"true"}}, {Field: "tag", Operator: "!=", Values: []string{"news", "tech"}}}, Order: []structure.ContentOrder{{Field: "title"}, {Field: "id", Descending: true}}},
+			"SELECT id FROM posts WHERE page = 0 AND featured = ? AND NOT (id IN (SELECT posts_tags.post_id FROM posts_tags, tags WHERE posts_tags.tag_id = tags.id AND tags.slug IN (?, ?))) ORDER BY title ASC, id DESC LIMIT ?",
+			[]interface{}{true, "news", "tech", int64(-1)},
+		},
+		{
+			structure.ContentQuery{Limit: 1, Filters: []structure.ContentFilter{{Field: "published_at", Operator: ">", Values: []string{"2020-01-01T13:00:00+01:00"}}, {Field: "id", Operator: "<=", Values: []string{"5"}}}},
+			"SELECT id FROM posts WHERE page = 0 AND published_at > ? AND id <= ? ORDER BY published_at DESC LIMIT ?",
+			[]interface{}{time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC), int64(5), int64(1)},
+		},
+	}
+	for _, test := range tests {
+		statement, arguments, err := buildContentQuery("SELECT id FROM posts WHERE page = 0", true, postQueryFields, &test.query, "published_at DESC")
+		if err != nil || statement != test.statement || !reflect.DeepEqual(arguments, test.arguments) {
+			t.Errorf("Expected %s %v, received %s %v (%v)", test.statement, test.arguments, statement, arguments, err)
+		}
+	}
+	// Fields and operators that can't be used
+	for _, query := range []structure.ContentQuery{
+		{Filters: []structure.ContentFilter{{Field: "markdown", Operator: "=", Values: []string{"a"}}}},
+		{Filters: []structure.ContentFilter{{Field: "featured", Operator: ">", Values: []string{"true"}}}},
+		{Filters: []structure.ContentFilter{{Field: "id", Operator: ">", Values: []string{"1", "2"}}}},
+		{Filters: []structure.ContentFilter{{Field: "id", Operator: "=", Values: []string{"one"}}}},
+		{Filters: []structure.ContentFilter{{Field: "published_at", Operator: "<", Values: []string{"yesterday"}}}},
+		{Filters: []structure.ContentFilter{{Field: "slug", Operator: "~", Values: []string{"a"}}}},
+		{Filters: []structure.ContentFilter{{Field: "slug", Operator: "="}}},
+		{Order: []structure.ContentOrder{{Field: "tag"}}},
+	} {
+		if _, _, err := buildContentQuery("SELECT id FROM posts", false, postQueryFields, &query, "id"); err == nil {
+			t.Errorf("Expected an error for %+v", query)
+		}
+	}
+}
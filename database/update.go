@@ -2,16 +2,19 @@ package database
 
 import (
 	"time"
+
+	"github.com/satori/go.uuid"
 )
 
-const stmtUpdatePost = "UPDATE posts SET title = ?, slug = ?, markdown = ?, html = ?, featured = ?, page = ?, status = ?, meta_description = ?, image = ?, updated_at = ?, updated_by = ? WHERE id = ?"
-const stmtUpdatePostPublished = "UPDATE posts SET title = ?, slug = ?, markdown = ?, html = ?, featured = ?, page = ?, status = ?, meta_description = ?, image = ?, updated_at = ?, updated_by = ?, published_at = ?, published_by = ? WHERE id = ?"
+const stmtUpdatePost = "UPDATE posts SET title = ?, slug = ?, markdown = ?, html = ?, featured = ?, page = ?, status = ?, meta_description = ?, image = ?, codeinjection_head = ?, codeinjection_foot = ?, custom_template = ?, updated_at = ?, updated_by = ? WHERE id = ?"
+const stmtUpdatePostPublished = "UPDATE posts SET title = ?, slug = ?, markdown = ?, html = ?, featured = ?, page = ?, status = ?, meta_description = ?, image = ?, codeinjection_head = ?, codeinjection_foot = ?, custom_template = ?, updated_at = ?, updated_by = ?, published_at = ?, published_by = ? WHERE id = ?"
 const stmtUpdateSettings = "UPDATE settings SET value = ?, updated_at = ?, updated_by = ? WHERE key = ?"
 const stmtUpdateUser = "UPDATE users SET name = ?, slug = ?, email = ?, image = ?, cover = ?, bio = ?, website = ?, location = ?, updated_at = ?, updated_by = ? WHERE id = ?"
 const stmtUpdateLastLogin = "UPDATE users SET last_login = ? WHERE id = ?"
 const stmtUpdateUserPassword = "UPDATE users SET password = ?, updated_at = ?, updated_by = ? WHERE id = ?"
+const stmtUpdateTokenLastUsed = "UPDATE tokens SET last_used_at = ? WHERE id = ?"
 
-func UpdatePost(id int64, title []byte, slug string, markdown []byte, html []byte, featured bool, isPage bool, published bool, metaDescription []byte, image []byte, updatedAt time.Time, updatedBy int64) error {
+func UpdatePost(id int64, title []byte, slug string, markdown []byte, html []byte, featured bool, isPage bool, published bool, metaDescription []byte, image []byte, codeInjectionHead []byte, codeInjectionFoot []byte, customTemplate string, updatedAt time.Time, updatedBy int64) error {
 	currentPost, err := RetrievePostById(id)
 	if err != nil {
 		return err
@@ -27,9 +30,9 @@ func UpdatePost(id int64, title []byte, slug string, markdown []byte, html []byt
 	}
 	// If the updated post is published for the first time, add publication date and user
 	if published && !currentPost.IsPublished {
-		_, err = writeDB.Exec(stmtUpdatePostPublished, title, slug, markdown, html, featured, isPage, status, metaDescription, image, updatedAt, updatedBy, updatedAt, updatedBy, id)
+		_, err = writeDB.Exec(stmtUpdatePostPublished, title, slug, markdown, html, featured, isPage, status, metaDescription, image, codeInjectionHead, codeInjectionFoot, customTemplate, updatedAt, updatedBy, updatedAt, updatedBy, id)
 	} else {
-		_, err = writeDB.Exec(stmtUpdatePost, title, slug, markdown, html, featured, isPage, status, metaDescription, image, updatedAt, updatedBy, id)
+		_, err = writeDB.Exec(stmtUpdatePost, title, slug, markdown, html, featured, isPage, status, metaDescription, image, codeInjectionHead, codeInjectionFoot, customTemplate, updatedAt, updatedBy, id)
 	}
 	if err != nil {
 		_ = writeDB.Rollback()
@@ -38,7 +41,7 @@ func UpdatePost(id int64, title []byte, slug string, markdown []byte, html []byt
 	return writeDB.Commit()
 }
 
-func UpdateSettings(title []byte, description []byte, logo []byte, cover []byte, postsPerPage int64, activeTheme string, navigation []byte, updatedAt time.Time, updatedBy int64) error {
+func UpdateSettings(title []byte, description []byte, logo []byte, cover []byte, postsPerPage int64, activeTheme string, language string, timezone string, navigation []byte, secondaryNavigation []byte, codeInjectionHead []byte, codeInjectionFoot []byte, updatedAt time.Time, updatedBy int64) error {
 	writeDB, err := readDB.Begin()
 	if err != nil {
 		_ = writeDB.Rollback()
@@ -80,12 +83,40 @@ func UpdateSettings(title []byte, description []byte, logo []byte, cover []byte,
 		_ = writeDB.Rollback()
 		return err
 	}
+	// Language
+	_, err = writeDB.Exec(stmtUpdateSettings, language, updatedAt, updatedBy, "language")
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	// Timezone
+	_, err = writeDB.Exec(stmtUpdateSettings, timezone, updatedAt, updatedBy, "timezone")
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
 	// Navigation
 	_, err = writeDB.Exec(stmtUpdateSettings, navigation, updatedAt, updatedBy, "navigation")
 	if err != nil {
 		_ = writeDB.Rollback()
 		return err
 	}
+	_, err = writeDB.Exec(stmtUpdateSettings, secondaryNavigation, updatedAt, updatedBy, "secondaryNavigation")
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	// Code injection
+	_, err = writeDB.Exec(stmtUpdateSettings, codeInjectionHead, updatedAt, updatedBy, "codeInjectionHead")
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	_, err = writeDB.Exec(stmtUpdateSettings, codeInjectionFoot, updatedAt, updatedBy, "codeInjectionFoot")
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
 	return writeDB.Commit()
 }
 
@@ -103,6 +134,28 @@ func UpdateActiveTheme(activeTheme string, updatedAt time.Time, updatedBy int64)
 	return writeDB.Commit()
 }
 
+// Function to save the values of the custom settings of a theme. The setting is created the first time the values are saved.
+func UpdateThemeSettings(theme string, values []byte, updatedAt time.Time, updatedBy int64) error {
+	writeDB, err := readDB.Begin()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	result, err := writeDB.Exec(stmtUpdateSettings, values, updatedAt, updatedBy, themeSettingsKey(theme))
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		_, err = writeDB.Exec(stmtInsertSetting, nil, uuid.NewV4().String(), themeSettingsKey(theme), values, "theme", updatedAt, updatedBy, updatedAt, updatedBy)
+		if err != nil {
+			_ = writeDB.Rollback()
+			return err
+		}
+	}
+	return writeDB.Commit()
+}
+
 func UpdateUser(id int64, name []byte, slug string, email []byte, image []byte, cover []byte, bio []byte, website []byte, location []byte, updatedAt time.Time, updatedBy int64) error {
 	writeDB, err := readDB.Begin()
 	if err != nil {
@@ -144,3 +197,17 @@ func UpdateUserPassword(id int64, password string, updatedAt time.Time, updatedB
 	}
 	return writeDB.Commit()
 }
+
+func UpdateTokenLastUsed(id int64, lastUsedAt time.Time) error {
+	writeDB, err := readDB.Begin()
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	_, err = writeDB.Exec(stmtUpdateTokenLastUsed, lastUsedAt, id)
+	if err != nil {
+		_ = writeDB.Rollback()
+		return err
+	}
+	return writeDB.Commit()
+}
@@ -2,13 +2,22 @@ package date
 
 import (
 	"bytes"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var marchMayChecker = regexp.MustCompile("M([^a]|$)")
+// Tokens of moment.js formats that are supported. Longer tokens need to come before shorter ones with the same prefix.
+var formatTokens = []string{
+	"LTS", "LT", "LLLL", "LLL", "LL", "L", "llll", "lll", "ll", "l",
+	"YYYY", "YY", "Q",
+	"MMMM", "MMM", "MM", "M",
+	"DDDD", "DDD", "Do", "DD", "D",
+	"dddd", "ddd", "dd", "d", "e", "E",
+	"gggg", "gg", "ww", "w", "GGGG", "GG", "WW", "W",
+	"X",
+	"HH", "H", "hh", "h", "a", "A", "mm", "m", "ss", "s", "SSS", "SS", "S", "ZZ", "Z",
+}
 
 // Whenever we need time.Now(), we use this function instead so that we always use UTC in journey
 func GetCurrentTime() time.Time {
@@ -16,350 +25,278 @@ func GetCurrentTime() time.Time {
 }
 
 func GenerateTimeAgo(date *time.Time) []byte {
+	return GenerateTimeAgoInLocale(date, English)
+}
+
+// Function to describe how long ago a date was (e.g. "3 days ago") in the language of the locale
+func GenerateTimeAgoInLocale(date *time.Time, locale *Locale) []byte {
+	texts := &locale.RelativeTime
 	timeAgo := GetCurrentTime().Sub(*date)
+	var duration string
 	if timeAgo.Minutes() < 1 {
-		return []byte("a few seconds ago")
-	}
-	if timeAgo.Minutes() < 2 {
-		return []byte("a minute ago")
-	}
-	if timeAgo.Minutes() < 60 {
-		var buffer bytes.Buffer
-		buffer.WriteString(strconv.Itoa(int(timeAgo.Minutes())))
-		buffer.WriteString(" minutes ago")
-		return buffer.Bytes()
-	}
-	if timeAgo.Hours() < 2 {
-		return []byte("an hour ago")
-	}
-	if timeAgo.Hours() < 24 {
-		var buffer bytes.Buffer
-		buffer.WriteString(strconv.Itoa(int(timeAgo.Hours())))
-		buffer.WriteString(" hours ago")
-		return buffer.Bytes()
-	}
-	if timeAgo.Hours() < 48 {
-		return []byte("a day ago")
-	}
-	days := int(timeAgo.Hours() / 24)
-	if days < 25 {
-		var buffer bytes.Buffer
-		buffer.WriteString(strconv.Itoa(days))
-		buffer.WriteString(" days ago")
-		return buffer.Bytes()
-	}
-	if days < 45 {
-		return []byte("a month ago")
-	}
-	if days < 345 {
+		duration = texts.Seconds
+	} else if timeAgo.Minutes() < 2 {
+		duration = texts.Minute
+	} else if timeAgo.Minutes() < 60 {
+		duration = relativeNumber(texts.Minutes, int(timeAgo.Minutes()))
+	} else if timeAgo.Hours() < 2 {
+		duration = texts.Hour
+	} else if timeAgo.Hours() < 24 {
+		duration = relativeNumber(texts.Hours, int(timeAgo.Hours()))
+	} else if timeAgo.Hours() < 48 {
+		duration = texts.Day
+	} else if days := int(timeAgo.Hours() / 24); days < 25 {
+		duration = relativeNumber(texts.Days, days)
+	} else if days < 45 {
+		duration = texts.Month
+	} else if days < 345 {
 		months := days / 30
 		if months < 2 {
 			months = 2
 		}
-		var buffer bytes.Buffer
-		buffer.WriteString(strconv.Itoa(months))
-		buffer.WriteString(" months ago")
-		return buffer.Bytes()
-	}
-	if days < 548 {
-		return []byte("a year ago")
-	}
-	years := days / 365
-	if years < 2 {
-		years = 2
-	}
-	var buffer bytes.Buffer
-	buffer.WriteString(strconv.Itoa(years))
-	buffer.WriteString(" years ago")
-	return buffer.Bytes()
-}
-
-func FormatDate(format string, date *time.Time) []byte {
-
-	// Do these first (so they don't accidentally replace something the others insert)
-	if strings.Contains(format, "h") {
-		format = strings.Replace(format, "h", replaceh(date), -1)
-	}
-	format = strings.Replace(format, "s", strconv.Itoa(date.Second()), -1)
-
-	// Year, month, and day
-	if strings.Contains(format, "Do") {
-		format = strings.Replace(format, "Do", replaceDo(date), -1)
-	}
-	format = strings.Replace(format, "YYYY", strconv.Itoa(date.Year()), -1)
-	if date.Year() > 99 {
-		format = strings.Replace(format, "YY", strconv.Itoa(date.Year())[2:], -1)
-	}
-	format = strings.Replace(format, "Q", strconv.Itoa(((int(date.Month())-1)/3)+1), -1)
-	if strings.Contains(format, "DDDD") {
-		format = strings.Replace(format, "DDDD", replaceDDDD(date), -1)
-	}
-	if strings.Contains(format, "DDD") {
-		format = strings.Replace(format, "DDD", replaceDDD(date), -1)
-	}
-	if strings.Contains(format, "DD") {
-		format = strings.Replace(format, "DD", replaceDD(date), -1)
-	}
-	format = strings.Replace(format, "X", strconv.FormatInt(date.Unix(), 10), -1)
-	// Unix ms ('x') is not used by ghost. Excluding it for now.
-	// format = strings.Replace(format, "x", strconv.FormatInt((date.UnixNano()/1000000), 10), -1)
-
-	// Locale formats. Not supported yet
-	format = strings.Replace(format, "gggg", strconv.Itoa(date.Year()), -1)
-	if date.Year() > 99 {
-		format = strings.Replace(format, "gg", strconv.Itoa(date.Year())[2:], -1)
-	}
-	if strings.Contains(format, "ww") {
-		format = strings.Replace(format, "ww", replaceww(date), -1)
-	}
-	if strings.Contains(format, "w") {
-		format = strings.Replace(format, "w", replacew(date), -1)
-	}
-	format = strings.Replace(format, "e", strconv.Itoa(int(date.Weekday())), -1)
-
-	// ISO week date formats. Not supported yet - https://en.wikipedia.org/wiki/ISO_week_date
-	format = strings.Replace(format, "GGGG", strconv.Itoa(date.Year()), -1)
-	if date.Year() > 99 {
-		format = strings.Replace(format, "GG", strconv.Itoa(date.Year())[2:], -1)
-	}
-	if strings.Contains(format, "WW") {
-		format = strings.Replace(format, "WW", replaceww(date), -1)
-	}
-	if strings.Contains(format, "W") {
-		format = strings.Replace(format, "W", replacew(date), -1)
-	}
-	format = strings.Replace(format, "E", strconv.Itoa(int(date.Weekday())), -1)
-
-	// Hour, minute, second, millisecond, and offset
-	if strings.Contains(format, "HH") {
-		format = strings.Replace(format, "HH", replaceHH(date), -1)
-	}
-	format = strings.Replace(format, "H", strconv.Itoa(date.Hour()), -1)
-	if strings.Contains(format, "hh") {
-		format = strings.Replace(format, "hh", replacehh(date), -1)
-	}
-	if strings.Contains(format, "a") {
-		format = strings.Replace(format, "a", replacea(date), -1)
-	}
-	if strings.Contains(format, "A") {
-		format = strings.Replace(format, "A", replaceA(date), -1)
-	}
-	if strings.Contains(format, "mm") {
-		format = strings.Replace(format, "mm", replacemm(date), -1)
-	}
-	format = strings.Replace(format, "m", strconv.Itoa(date.Minute()), -1)
-	if strings.Contains(format, "ss") {
-		format = strings.Replace(format, "ss", replacess(date), -1)
-	}
-	format = strings.Replace(format, "SSS", strconv.Itoa(date.Nanosecond()/1000000), -1)
-	format = strings.Replace(format, "SS", strconv.Itoa(date.Nanosecond()/10000000), -1)
-	format = strings.Replace(format, "S", strconv.Itoa(date.Nanosecond()/100000000), -1)
-	if strings.Contains(format, "ZZ") {
-		format = strings.Replace(format, "ZZ", replaceZZ(date), -1)
-	}
-	if strings.Contains(format, "Z") {
-		format = strings.Replace(format, "Z", replaceZ(date), -1)
-	}
-
-	// Not documented for moment.js, but seems to be used by ghost themes
-	if strings.Contains(format, "dddd") {
-		format = strings.Replace(format, "dddd", date.Weekday().String(), -1)
-	}
-
-	// This needs to be last so that month strings don't interfere with the other replace functions
-	format = strings.Replace(format, "MMMM", date.Month().String(), -1)
-	if len(date.Month().String()) > 2 {
-		format = strings.Replace(format, "MMM", date.Month().String()[:3], -1)
-	}
-	if strings.Contains(format, "MM") {
-		format = strings.Replace(format, "MM", replaceMM(date), -1)
-	}
-	// Replace M - make sure the Ms in March and May don't get replaced.
-	// TODO: Regex could be improved, only recognizes 'M's that are not followed by 'a's.
-	format = marchMayChecker.ReplaceAllString(format, strconv.Itoa(int(date.Month())))
-
-	return []byte(format)
-}
-
-func replaceMM(date *time.Time) string {
-	var buffer bytes.Buffer
-	month := int(date.Month())
-	if month < 10 {
-		buffer.WriteString("0")
-		buffer.WriteString(strconv.Itoa(month))
+		duration = relativeNumber(texts.Months, months)
+	} else if days < 548 {
+		duration = texts.Year
 	} else {
-		buffer.WriteString(strconv.Itoa(month))
+		years := days / 365
+		if years < 2 {
+			years = 2
+		}
+		duration = relativeNumber(texts.Years, years)
 	}
-	return buffer.String()
+	return []byte(strings.Replace(texts.Past, "%s", duration, 1))
 }
 
-func replaceDDDD(date *time.Time) string {
-	var buffer bytes.Buffer
-	startOfYear := time.Date(date.Year()-1, time.December, 31, 0, 0, 0, 0, time.UTC)
-	days := int(date.Sub(startOfYear) / (24 * time.Hour))
-	if days < 10 {
-		buffer.WriteString("00")
-	} else if days < 100 {
-		buffer.WriteString("0")
-	}
-	buffer.WriteString(strconv.Itoa(days))
-	return buffer.String()
+func relativeNumber(text string, number int) string {
+	return strings.Replace(text, "%d", strconv.Itoa(number), 1)
 }
 
-func replaceDDD(date *time.Time) string {
-	startOfYear := time.Date(date.Year()-1, time.December, 31, 0, 0, 0, 0, time.UTC)
-	return strconv.Itoa(int(date.Sub(startOfYear) / (24 * time.Hour)))
+func FormatDate(format string, date *time.Time) []byte {
+	return FormatDateInLocale(format, date, English)
 }
 
-func replaceDD(date *time.Time) string {
+// Function to format a date like moment.js does (e.g. "MMM Do, YYYY") with the names and long date formats of
+// the locale. The date is formatted in its own location. Text in square brackets is inserted as it is.
+func FormatDateInLocale(format string, date *time.Time, locale *Locale) []byte {
 	var buffer bytes.Buffer
-	if date.Day() < 10 {
-		buffer.WriteString("0")
-		buffer.WriteString(strconv.Itoa(date.Day()))
-	} else {
-		buffer.WriteString(strconv.Itoa(date.Day()))
-	}
-	return buffer.String()
+	writeFormat(&buffer, format, date, locale)
+	return buffer.Bytes()
 }
 
-func replaceDo(date *time.Time) string {
-	var buffer bytes.Buffer
-	buffer.WriteString(strconv.Itoa(date.Day()))
-	if date.Day() == 1 || date.Day() == 21 || date.Day() == 31 {
-		buffer.WriteString("st")
-	} else if date.Day() == 2 || date.Day() == 22 {
-		buffer.WriteString("nd")
-	} else if date.Day() == 3 || date.Day() == 23 {
-		buffer.WriteString("rd")
-	} else {
-		buffer.WriteString("th")
+func writeFormat(buffer *bytes.Buffer, format string, date *time.Time, locale *Locale) {
+	for len(format) != 0 {
+		if format[0] == '[' {
+			if end := strings.IndexByte(format, ']'); end != -1 {
+				buffer.WriteString(format[1:end])
+				format = format[end+1:]
+				continue
+			}
+		}
+		token := ""
+		for _, formatToken := range formatTokens {
+			if strings.HasPrefix(format, formatToken) {
+				token = formatToken
+				break
+			}
+		}
+		if token == "" {
+			buffer.WriteByte(format[0])
+			format = format[1:]
+			continue
+		}
+		format = format[len(token):]
+		if longDateFormat, ok := lookupLongDateFormat(token, locale); ok {
+			writeFormat(buffer, longDateFormat, date, locale)
+			continue
+		}
+		buffer.WriteString(formatToken(token, date, locale))
 	}
-	return buffer.String()
 }
 
-func replaceww(date *time.Time) string {
-	var buffer bytes.Buffer
-	startOfYear := time.Date(date.Year()-1, time.December, 25, 0, 0, 0, 0, time.UTC)
-	weeks := int(date.Sub(startOfYear) / (24 * time.Hour * 7))
-	if weeks < 10 {
-		buffer.WriteString("0")
+// Function to get the long date format of a locale. The lowercase formats (l, ll, lll and llll) are shortened versions of L, LL, LLL and LLLL.
+func lookupLongDateFormat(token string, locale *Locale) (string, bool) {
+	if format, ok := locale.LongDateFormat[token]; ok {
+		return format, true
 	}
-	buffer.WriteString(strconv.Itoa(weeks))
-	return buffer.String()
+	if token[0] != 'l' {
+		return "", false
+	}
+	format, ok := locale.LongDateFormat[strings.ToUpper(token)]
+	if !ok {
+		return "", false
+	}
+	return strings.NewReplacer("MMMM", "MMM", "MM", "M", "DD", "D", "dddd", "ddd").Replace(format), true
 }
 
-func replacew(date *time.Time) string {
-	startOfYear := time.Date(date.Year()-1, time.December, 25, 0, 0, 0, 0, time.UTC)
-	return strconv.Itoa(int(date.Sub(startOfYear) / (24 * time.Hour * 7)))
-}
+func formatToken(token string, date *time.Time, locale *Locale) string {
+	switch token {
+	// Year, month, and day
+	case "YYYY":
+		return strconv.Itoa(date.Year())
+	case "YY":
+		return twoDigits(date.Year() % 100)
+	case "Q":
+		return strconv.Itoa(((int(date.Month()) - 1) / 3) + 1)
+	case "MMMM":
+		return locale.Months[date.Month()-1]
+	case "MMM":
+		return locale.MonthsShort[date.Month()-1]
+	case "MM":
+		return twoDigits(int(date.Month()))
+	case "M":
+		return strconv.Itoa(int(date.Month()))
+	case "DDDD":
+		return threeDigits(date.YearDay())
+	case "DDD":
+		return strconv.Itoa(date.YearDay())
+	case "Do":
+		return locale.Ordinal(date.Day())
+	case "DD":
+		return twoDigits(date.Day())
+	case "D":
+		return strconv.Itoa(date.Day())
+	case "X":
+		return strconv.FormatInt(date.Unix(), 10)
+	// Unix ms ('x') is not used by ghost. Excluding it for now.
 
-func replaceHH(date *time.Time) string {
-	var buffer bytes.Buffer
-	hour := date.Hour()
-	if hour < 10 {
-		buffer.WriteString("0")
-	}
-	buffer.WriteString(strconv.Itoa(hour))
-	return buffer.String()
-}
+	// Weekdays
+	case "dddd":
+		return locale.Weekdays[date.Weekday()]
+	case "ddd":
+		return locale.WeekdaysShort[date.Weekday()]
+	case "dd":
+		return minWeekday(locale.WeekdaysShort[date.Weekday()])
+	case "d":
+		return strconv.Itoa(int(date.Weekday()))
+	case "e":
+		return strconv.Itoa((int(date.Weekday()) - int(locale.WeekStart) + 7) % 7)
+	case "E":
+		return strconv.Itoa(isoWeekday(date))
 
-func replacehh(date *time.Time) string {
-	var buffer bytes.Buffer
-	hour := date.Hour()
-	if hour == 0 {
-		hour = 12
-	} else if hour > 12 {
-		hour = hour - 12
-	}
-	if hour < 10 {
-		buffer.WriteString("0")
-	}
-	buffer.WriteString(strconv.Itoa(hour))
-	return buffer.String()
-}
+	// Locale week date formats
+	case "gggg":
+		year, _ := localeWeek(date, locale)
+		return strconv.Itoa(year)
+	case "gg":
+		year, _ := localeWeek(date, locale)
+		return twoDigits(year % 100)
+	case "ww":
+		_, week := localeWeek(date, locale)
+		return twoDigits(week)
+	case "w":
+		_, week := localeWeek(date, locale)
+		return strconv.Itoa(week)
 
-func replaceh(date *time.Time) string {
-	var buffer bytes.Buffer
-	hour := date.Hour()
-	if hour == 0 {
-		hour = 12
-	} else if hour > 12 {
-		hour = hour - 12
-	}
-	buffer.WriteString(strconv.Itoa(hour))
-	return buffer.String()
+	// ISO week date formats - https://en.wikipedia.org/wiki/ISO_week_date
+	case "GGGG":
+		year, _ := date.ISOWeek()
+		return strconv.Itoa(year)
+	case "GG":
+		year, _ := date.ISOWeek()
+		return twoDigits(year % 100)
+	case "WW":
+		_, week := date.ISOWeek()
+		return twoDigits(week)
+	case "W":
+		_, week := date.ISOWeek()
+		return strconv.Itoa(week)
+
+	// Hour, minute, second, millisecond, and offset
+	case "HH":
+		return twoDigits(date.Hour())
+	case "H":
+		return strconv.Itoa(date.Hour())
+	case "hh":
+		return twoDigits(twelveHour(date))
+	case "h":
+		return strconv.Itoa(twelveHour(date))
+	case "a":
+		if date.Hour() < 12 {
+			return "am"
+		}
+		return "pm"
+	case "A":
+		if date.Hour() < 12 {
+			return "AM"
+		}
+		return "PM"
+	case "mm":
+		return twoDigits(date.Minute())
+	case "m":
+		return strconv.Itoa(date.Minute())
+	case "ss":
+		return twoDigits(date.Second())
+	case "s":
+		return strconv.Itoa(date.Second())
+	case "SSS":
+		return threeDigits(date.Nanosecond() / 1000000)
+	case "SS":
+		return twoDigits(date.Nanosecond() / 10000000)
+	case "S":
+		return strconv.Itoa(date.Nanosecond() / 100000000)
+	case "ZZ":
+		return zoneOffset(date, "")
+	case "Z":
+		return zoneOffset(date, ":")
+	}
+	return token
 }
 
-func replacea(date *time.Time) string {
-	if date.Hour() < 12 {
-		return "am"
-	}
-	return "pm"
+// Function to compute the week and the week year of a date for the first day of the week and first week of the locale
+func localeWeek(date *time.Time, locale *Locale) (int, int) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	weekStart := day.AddDate(0, 0, -((int(day.Weekday()) - int(locale.WeekStart) + 7) % 7))
+	// The week belongs to the year of its day that is 7 - FirstWeekDay days after the start of the week
+	// (e.g. Thursday for ISO weeks, Saturday for weeks that contain January 1st).
+	anchor := weekStart.AddDate(0, 0, 7-locale.FirstWeekDay)
+	return anchor.Year(), (anchor.YearDay()-1)/7 + 1
 }
 
-func replaceA(date *time.Time) string {
-	if date.Hour() < 12 {
-		return "AM"
+func isoWeekday(date *time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
 	}
-	return "PM"
+	return int(date.Weekday())
 }
 
-func replacemm(date *time.Time) string {
-	var buffer bytes.Buffer
-	minute := date.Minute()
-	if minute < 10 {
-		buffer.WriteString("0")
+func twelveHour(date *time.Time) int {
+	hour := date.Hour() % 12
+	if hour == 0 {
+		return 12
 	}
-	buffer.WriteString(strconv.Itoa(minute))
-	return buffer.String()
+	return hour
 }
 
-func replacess(date *time.Time) string {
-	var buffer bytes.Buffer
-	second := date.Second()
-	if second < 10 {
-		buffer.WriteString("0")
+func minWeekday(shortName string) string {
+	name := []rune(strings.TrimSuffix(shortName, "."))
+	if len(name) > 2 {
+		name = name[:2]
 	}
-	buffer.WriteString(strconv.Itoa(second))
-	return buffer.String()
+	return string(name)
 }
 
-func replaceZZ(date *time.Time) string {
-	var buffer bytes.Buffer
-	_, offset := date.In(time.Local).Zone()
-	offset = offset / 3600
-	if offset > 0 {
-		buffer.WriteString("+")
-	} else {
-		buffer.WriteString("-")
-	}
+func zoneOffset(date *time.Time, separator string) string {
+	_, offset := date.Zone()
+	sign := "+"
 	if offset < 0 {
+		sign = "-"
 		offset = -offset
 	}
-	if offset < 10 {
-		buffer.WriteString("0")
-	}
-	buffer.WriteString(strconv.Itoa(offset))
-	buffer.WriteString("00")
-	return buffer.String()
+	return sign + twoDigits(offset/3600) + separator + twoDigits((offset%3600)/60)
 }
 
-func replaceZ(date *time.Time) string {
-	var buffer bytes.Buffer
-	_, offset := date.In(time.Local).Zone()
-	offset = offset / 3600
-	if offset > 0 {
-		buffer.WriteString("+")
-	} else {
-		buffer.WriteString("-")
-	}
-	if offset < 0 {
-		offset = -offset
+func twoDigits(number int) string {
+	if number < 10 {
+		return "0" + strconv.Itoa(number)
 	}
-	if offset < 10 {
-		buffer.WriteString("0")
+	return strconv.Itoa(number)
+}
+
+func threeDigits(number int) string {
+	if number < 10 {
+		return "00" + strconv.Itoa(number)
+	} else if number < 100 {
+		return "0" + strconv.Itoa(number)
 	}
-	buffer.WriteString(strconv.Itoa(offset))
-	buffer.WriteString(":00")
-	return buffer.String()
+	return strconv.Itoa(number)
 }
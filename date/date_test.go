@@ -0,0 +1,119 @@
+package date
+
+import (
+	"testing"
+	"time"
+)
+
+var formatTests = []struct {
+	format   string
+	date     time.Time
+	language string
+	out      string
+}{
+	{
+		format:   "MMM Do, YYYY",
+		date:     time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC),
+		language: "en",
+		out:      "Mar 2nd, 2016",
+	},
+	{
+		format:   "dddd, MMMM D YYYY HH:mm:ss",
+		date:     time.Date(2016, time.May, 11, 9, 5, 9, 0, time.UTC),
+		language: "en",
+		out:      "Wednesday, May 11 2016 09:05:09",
+	},
+	{
+		format:   "dddd, D. MMMM YYYY",
+		date:     time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC),
+		language: "de-CH",
+		out:      "Mittwoch, 2. März 2016",
+	},
+	{
+		format:   "LL",
+		date:     time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC),
+		language: "es",
+		out:      "2 de marzo de 2016",
+	},
+	{
+		format:   "L LT",
+		date:     time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC),
+		language: "en",
+		out:      "03/02/2016 2:05 PM",
+	},
+	{
+		format:   "lll",
+		date:     time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC),
+		language: "fr",
+		out:      "2 mars 2016 14:05",
+	},
+	{
+		format:   "[Week] W [of] GGGG, E",
+		date:     time.Date(2021, time.January, 3, 12, 0, 0, 0, time.UTC),
+		language: "en",
+		out:      "Week 53 of 2020, 7",
+	},
+	{
+		format:   "WW GG",
+		date:     time.Date(2019, time.December, 30, 12, 0, 0, 0, time.UTC),
+		language: "en",
+		out:      "01 20",
+	},
+	{
+		format:   "w gggg",
+		date:     time.Date(2021, time.January, 3, 12, 0, 0, 0, time.UTC),
+		language: "en",
+		out:      "2 2021",
+	},
+	{
+		format:   "w gggg",
+		date:     time.Date(2021, time.January, 3, 12, 0, 0, 0, time.UTC),
+		language: "de",
+		out:      "53 2020",
+	},
+	{
+		format:   "HH:mm Z",
+		date:     time.Date(2016, time.July, 1, 22, 30, 0, 0, time.UTC).In(time.FixedZone("IST", 19800)),
+		language: "en",
+		out:      "04:00 +05:30",
+	},
+}
+
+func TestFormatDateInLocale(t *testing.T) {
+	for _, test := range formatTests {
+		if actual := string(FormatDateInLocale(test.format, &test.date, LookupLocale(test.language))); actual != test.out {
+			t.Errorf("Expected '%s', received '%s' for '%s' in '%s'", test.out, actual, test.format, test.language)
+		}
+	}
+}
+
+var timeAgoTests = []struct {
+	ago      time.Duration
+	language string
+	out      string
+}{
+	{
+		ago:      3 * time.Hour,
+		language: "en",
+		out:      "3 hours ago",
+	},
+	{
+		ago:      3 * time.Hour,
+		language: "de",
+		out:      "vor 3 Stunden",
+	},
+	{
+		ago:      10 * 24 * time.Hour,
+		language: "it",
+		out:      "10 giorni fa",
+	},
+}
+
+func TestGenerateTimeAgoInLocale(t *testing.T) {
+	for _, test := range timeAgoTests {
+		date := GetCurrentTime().Add(-test.ago - time.Minute)
+		if actual := string(GenerateTimeAgoInLocale(&date, LookupLocale(test.language))); actual != test.out {
+			t.Errorf("Expected '%s', received '%s' for %v in '%s'", test.out, actual, test.ago, test.language)
+		}
+	}
+}
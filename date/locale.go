@@ -0,0 +1,209 @@
+package date
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Locale: month and weekday names, long date formats and relative time texts of a language
+type Locale struct {
+	Months        [12]string
+	MonthsShort   [12]string
+	Weekdays      [7]string // Starting with Sunday
+	WeekdaysShort [7]string
+	// First day of the week and the day in January that is always in the first week of the year (used for w, ww and gggg)
+	WeekStart      time.Weekday
+	FirstWeekDay   int
+	Ordinal        func(number int) string
+	LongDateFormat map[string]string // LT, LTS, L, LL, LLL and LLLL
+	RelativeTime   RelativeTime
+}
+
+// RelativeTime: texts for GenerateTimeAgo. %s in Past is replaced with the duration, %d with a number.
+type RelativeTime struct {
+	Past    string
+	Seconds string
+	Minute  string
+	Minutes string
+	Hour    string
+	Hours   string
+	Day     string
+	Days    string
+	Month   string
+	Months  string
+	Year    string
+	Years   string
+}
+
+var English = &Locale{
+	Months:         [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	MonthsShort:    [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	Weekdays:       [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	WeekdaysShort:  [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	WeekStart:      time.Sunday,
+	FirstWeekDay:   1,
+	Ordinal:        englishOrdinal,
+	LongDateFormat: map[string]string{"LT": "h:mm A", "LTS": "h:mm:ss A", "L": "MM/DD/YYYY", "LL": "MMMM D, YYYY", "LLL": "MMMM D, YYYY h:mm A", "LLLL": "dddd, MMMM D, YYYY h:mm A"},
+	RelativeTime:   RelativeTime{Past: "%s ago", Seconds: "a few seconds", Minute: "a minute", Minutes: "%d minutes", Hour: "an hour", Hours: "%d hours", Day: "a day", Days: "%d days", Month: "a month", Months: "%d months", Year: "a year", Years: "%d years"},
+}
+
+var locales = map[string]*Locale{
+	"en": English,
+	"de": &Locale{
+		Months:         [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		MonthsShort:    [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sep.", "Okt.", "Nov.", "Dez."},
+		Weekdays:       [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		WeekdaysShort:  [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        suffixOrdinal("."),
+		LongDateFormat: map[string]string{"LT": "HH:mm", "LTS": "HH:mm:ss", "L": "DD.MM.YYYY", "LL": "D. MMMM YYYY", "LLL": "D. MMMM YYYY HH:mm", "LLLL": "dddd, D. MMMM YYYY HH:mm"},
+		RelativeTime:   RelativeTime{Past: "vor %s", Seconds: "ein paar Sekunden", Minute: "einer Minute", Minutes: "%d Minuten", Hour: "einer Stunde", Hours: "%d Stunden", Day: "einem Tag", Days: "%d Tagen", Month: "einem Monat", Months: "%d Monaten", Year: "einem Jahr", Years: "%d Jahren"},
+	},
+	"es": &Locale{
+		Months:         [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		MonthsShort:    [12]string{"ene.", "feb.", "mar.", "abr.", "may.", "jun.", "jul.", "ago.", "sep.", "oct.", "nov.", "dic."},
+		Weekdays:       [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		WeekdaysShort:  [7]string{"dom.", "lun.", "mar.", "mié.", "jue.", "vie.", "sáb."},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        suffixOrdinal("º"),
+		LongDateFormat: map[string]string{"LT": "H:mm", "LTS": "H:mm:ss", "L": "DD/MM/YYYY", "LL": "D [de] MMMM [de] YYYY", "LLL": "D [de] MMMM [de] YYYY H:mm", "LLLL": "dddd, D [de] MMMM [de] YYYY H:mm"},
+		RelativeTime:   RelativeTime{Past: "hace %s", Seconds: "unos segundos", Minute: "un minuto", Minutes: "%d minutos", Hour: "una hora", Hours: "%d horas", Day: "un día", Days: "%d días", Month: "un mes", Months: "%d meses", Year: "un año", Years: "%d años"},
+	},
+	"fr": &Locale{
+		Months:         [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		MonthsShort:    [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		Weekdays:       [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		WeekdaysShort:  [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        frenchOrdinal,
+		LongDateFormat: map[string]string{"LT": "HH:mm", "LTS": "HH:mm:ss", "L": "DD/MM/YYYY", "LL": "D MMMM YYYY", "LLL": "D MMMM YYYY HH:mm", "LLLL": "dddd D MMMM YYYY HH:mm"},
+		RelativeTime:   RelativeTime{Past: "il y a %s", Seconds: "quelques secondes", Minute: "une minute", Minutes: "%d minutes", Hour: "une heure", Hours: "%d heures", Day: "un jour", Days: "%d jours", Month: "un mois", Months: "%d mois", Year: "un an", Years: "%d ans"},
+	},
+	"it": &Locale{
+		Months:         [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		MonthsShort:    [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		Weekdays:       [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		WeekdaysShort:  [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        suffixOrdinal("º"),
+		LongDateFormat: map[string]string{"LT": "HH:mm", "LTS": "HH:mm:ss", "L": "DD/MM/YYYY", "LL": "D MMMM YYYY", "LLL": "D MMMM YYYY HH:mm", "LLLL": "dddd D MMMM YYYY HH:mm"},
+		RelativeTime:   RelativeTime{Past: "%s fa", Seconds: "alcuni secondi", Minute: "un minuto", Minutes: "%d minuti", Hour: "un'ora", Hours: "%d ore", Day: "un giorno", Days: "%d giorni", Month: "un mese", Months: "%d mesi", Year: "un anno", Years: "%d anni"},
+	},
+	"nl": &Locale{
+		Months:         [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		MonthsShort:    [12]string{"jan.", "feb.", "mrt.", "apr.", "mei", "jun.", "jul.", "aug.", "sep.", "okt.", "nov.", "dec."},
+		Weekdays:       [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		WeekdaysShort:  [7]string{"zo.", "ma.", "di.", "wo.", "do.", "vr.", "za."},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        dutchOrdinal,
+		LongDateFormat: map[string]string{"LT": "HH:mm", "LTS": "HH:mm:ss", "L": "DD-MM-YYYY", "LL": "D MMMM YYYY", "LLL": "D MMMM YYYY HH:mm", "LLLL": "dddd D MMMM YYYY HH:mm"},
+		RelativeTime:   RelativeTime{Past: "%s geleden", Seconds: "een paar seconden", Minute: "één minuut", Minutes: "%d minuten", Hour: "één uur", Hours: "%d uur", Day: "één dag", Days: "%d dagen", Month: "één maand", Months: "%d maanden", Year: "één jaar", Years: "%d jaar"},
+	},
+	"pt": &Locale{
+		Months:         [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		MonthsShort:    [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		Weekdays:       [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		WeekdaysShort:  [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+		WeekStart:      time.Monday,
+		FirstWeekDay:   4,
+		Ordinal:        suffixOrdinal("º"),
+		LongDateFormat: map[string]string{"LT": "HH:mm", "LTS": "HH:mm:ss", "L": "DD/MM/YYYY", "LL": "D [de] MMMM [de] YYYY", "LLL": "D [de] MMMM [de] YYYY HH:mm", "LLLL": "dddd, D [de] MMMM [de] YYYY HH:mm"},
+		RelativeTime:   RelativeTime{Past: "há %s", Seconds: "segundos", Minute: "um minuto", Minutes: "%d minutos", Hour: "uma hora", Hours: "%d horas", Day: "um dia", Days: "%d dias", Month: "um mês", Months: "%d meses", Year: "um ano", Years: "%d anos"},
+	},
+}
+
+// Function to get the locale of a language tag (e.g. de or de-CH). Falls back to the base language and then to English.
+func LookupLocale(language string) *Locale {
+	language = strings.ToLower(strings.Replace(language, "_", "-", -1))
+	if locale, ok := locales[language]; ok {
+		return locale
+	}
+	if index := strings.Index(language, "-"); index != -1 {
+		if locale, ok := locales[language[:index]]; ok {
+			return locale
+		}
+	}
+	return English
+}
+
+var (
+	locationsMutex sync.RWMutex
+	locations      = make(map[string]*time.Location)
+)
+
+// Function to check if a timezone is a valid IANA timezone name (e.g. Europe/Berlin)
+func ValidTimezone(name string) bool {
+	_, err := loadLocation(name)
+	return err == nil
+}
+
+// Function to get the location of a timezone. Unknown timezones fall back to UTC.
+func Location(name string) *time.Location {
+	location, err := loadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	locationsMutex.RLock()
+	location, ok := locations[name]
+	locationsMutex.RUnlock()
+	if ok {
+		return location, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationsMutex.Lock()
+	locations[name] = location
+	locationsMutex.Unlock()
+	return location, nil
+}
+
+func englishOrdinal(number int) string {
+	suffix := "th"
+	if number%100 < 11 || number%100 > 13 {
+		switch number % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(number) + suffix
+}
+
+func frenchOrdinal(number int) string {
+	if number == 1 {
+		return "1er"
+	}
+	return strconv.Itoa(number)
+}
+
+func dutchOrdinal(number int) string {
+	if number == 1 || number == 8 || number >= 20 {
+		return strconv.Itoa(number) + "ste"
+	}
+	return strconv.Itoa(number) + "de"
+}
+
+func suffixOrdinal(suffix string) func(int) string {
+	return func(number int) string {
+		return strconv.Itoa(number) + suffix
+	}
+}
@@ -10,29 +10,29 @@ import (
 )
 
 var (
-	// Determine the path to the assets folder (default: Journey root folder)
-	AssetPath = determineAssetPath()
+	// Path to the assets folder (default: Journey root folder)
+	AssetPath string
 
 	// For assets that are created, changed, our user-provided while running journey
-	ConfigFilename   = filepath.Join(AssetPath, "config.json")
-	ContentFilepath  = filepath.Join(AssetPath, "content")
-	DatabaseFilepath = filepath.Join(ContentFilepath, "data")
-	DatabaseFilename = filepath.Join(ContentFilepath, "data", "journey.db")
-	ThemesFilepath   = filepath.Join(ContentFilepath, "themes")
-	ImagesFilepath   = filepath.Join(ContentFilepath, "images")
-	PluginsFilepath  = filepath.Join(ContentFilepath, "plugins")
-	PagesFilepath    = filepath.Join(ContentFilepath, "pages")
+	ConfigFilename   string
+	ContentFilepath  string
+	DatabaseFilepath string
+	DatabaseFilename string
+	ThemesFilepath   string
+	ImagesFilepath   string
+	PluginsFilepath  string
+	PagesFilepath    string
 
 	// For https
-	HttpsFilepath     = filepath.Join(ContentFilepath, "https")
-	HttpsCertFilename = filepath.Join(ContentFilepath, "https", "cert.pem")
-	HttpsKeyFilename  = filepath.Join(ContentFilepath, "https", "key.pem")
+	HttpsFilepath     string
+	HttpsCertFilename string
+	HttpsKeyFilename  string
 
 	// For built-in files (e.g. the admin interface)
-	BuiltInPath    = determineBuiltInPath()
-	AdminFilepath  = filepath.Join(BuiltInPath, "admin")
-	PublicFilepath = filepath.Join(BuiltInPath, "public")
-	HbsFilepath    = filepath.Join(BuiltInPath, "hbs")
+	BuiltInPath    string
+	AdminFilepath  string
+	PublicFilepath string
+	HbsFilepath    string
 
 	// For blog  (this is a url string)
 	// TODO: This is not used at the moment because it is still hard-coded into the create database string
@@ -45,12 +45,35 @@ var (
 )
 
 func init() {
-	// Create content directories if they are not created already
-	err := createDirectories()
-	if err != nil {
-		log.Fatal("Error: Couldn't create directories:", err)
-	}
+	// Use the folder of the executable until the flags have been parsed
+	executablePath := determineExecutablePath()
+	setPaths(executablePath, filepath.Join(executablePath, "built-in"))
+}
 
+// Function to set the paths from the custom-path and custom-built-in-path flags and to create
+// the content directories. Has to be called after the flags have been parsed.
+func Initialize() error {
+	setPaths(determineAssetPath(), determineBuiltInPath())
+	return createDirectories()
+}
+
+func setPaths(assetPath string, builtInPath string) {
+	AssetPath = assetPath
+	ConfigFilename = filepath.Join(AssetPath, "config.json")
+	ContentFilepath = filepath.Join(AssetPath, "content")
+	DatabaseFilepath = filepath.Join(ContentFilepath, "data")
+	DatabaseFilename = filepath.Join(ContentFilepath, "data", "journey.db")
+	ThemesFilepath = filepath.Join(ContentFilepath, "themes")
+	ImagesFilepath = filepath.Join(ContentFilepath, "images")
+	PluginsFilepath = filepath.Join(ContentFilepath, "plugins")
+	PagesFilepath = filepath.Join(ContentFilepath, "pages")
+	HttpsFilepath = filepath.Join(ContentFilepath, "https")
+	HttpsCertFilename = filepath.Join(ContentFilepath, "https", "cert.pem")
+	HttpsKeyFilename = filepath.Join(ContentFilepath, "https", "key.pem")
+	BuiltInPath = builtInPath
+	AdminFilepath = filepath.Join(BuiltInPath, "admin")
+	PublicFilepath = filepath.Join(BuiltInPath, "public")
+	HbsFilepath = filepath.Join(BuiltInPath, "hbs")
 }
 
 func createDirectories() error {
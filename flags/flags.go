@@ -23,17 +23,28 @@ var (
 
 	CustomBuiltInPath     = ""
 	CustomBuiltInPathFlag = "custom-built-in-path"
+
+	CheckTheme     = ""
+	CheckThemeFlag = "check-theme"
+
+	CheckThemeFormat     = "text"
+	CheckThemeFormatFlag = "check-theme-format"
 )
 
 func init() {
-	// Parse all flags
-	parseFlags()
+	// Register all flags. They are parsed by main with Parse.
+	registerFlags()
+}
+
+// Function to parse the command line. Has to be called before the paths and the configuration are initialized.
+func Parse() {
+	flag.Parse()
 	if IsInDevMode {
 		log.Println("Starting Journey in developer mode...")
 	}
 }
 
-func parseFlags() {
+func registerFlags() {
 	// Check if the log should be output to a file
 	flag.StringVar(&Log, LogFlag, "", "Use this option to save to log output to a file. Note: Journey needs create, read, and write access to that file. Example: -log=path/to/log.txt")
 
@@ -52,5 +63,9 @@ func parseFlags() {
 	// Check if custom built-in path has been provided by user
 	flag.StringVar(&CustomBuiltInPath, CustomBuiltInPathFlag, "", "Specify a custom path to store builtin files. Read-only access is needed.")
 
-	flag.Parse()
+	// Check if a theme should be checked instead of starting the server
+	flag.StringVar(&CheckTheme, CheckThemeFlag, "", "Use this option to check a theme for problems without starting Journey. Exits with status 1 if errors were found. Example: -check-theme=content/themes/promenade")
+
+	// Check if the result of the theme check should be output as json
+	flag.StringVar(&CheckThemeFormat, CheckThemeFormatFlag, "text", "Output format of -check-theme. Can be text or json. Example: -check-theme-format=json")
 }
@@ -8,12 +8,14 @@ import (
 	"strings"
 
 	"github.com/dimfeld/httptreemux"
+	"journey/cache"
 	"journey/configuration"
 	"journey/database"
 	"journey/filenames"
 	"journey/flags"
 	"journey/https"
 	"journey/plugins"
+	"journey/security"
 	"journey/server"
 	"journey/structure/methods"
 	"journey/templates"
@@ -24,6 +26,28 @@ func httpsRedirect(w http.ResponseWriter, r *http.Request, _ map[string]string)
 	return
 }
 
+// Function to check a theme and print the result. Returns the exit status.
+func checkTheme(themePath string, format string) int {
+	report, err := templates.CheckTheme(themePath)
+	if err != nil {
+		log.Println("Error: Couldn't check theme:", err)
+		return 2
+	}
+	if format == "json" {
+		err = report.WriteJson(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Println("Error: Couldn't write theme check result:", err)
+		return 2
+	}
+	if report.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
 func main() {
 	// Setup
 	var err error
@@ -31,6 +55,9 @@ func main() {
 	// GOMAXPROCS - Maybe not needed
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	// Command line flags
+	flags.Parse()
+
 	// Write log to file if the log flag was provided
 	if flags.Log != "" {
 		logFile, err := os.OpenFile(flags.Log, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -43,7 +70,20 @@ func main() {
 		log.SetOutput(logFile)
 	}
 
-	// Configuration is read from config.json by loading the configuration package
+	// Paths of the content and built-in files
+	if err = filenames.Initialize(); err != nil {
+		log.Fatal("Error: Couldn't create directories:", err)
+		return
+	}
+
+	// Check a theme and exit if the check-theme flag was provided
+	if flags.CheckTheme != "" {
+		os.Exit(checkTheme(flags.CheckTheme, flags.CheckThemeFormat))
+	}
+
+	// Configuration is read from config.json
+	configuration.Initialize()
+	cache.Initialize()
 
 	// Database
 	if err = database.Initialize(); err != nil {
@@ -62,6 +102,7 @@ func main() {
 		log.Fatal("Error: Couldn't compile templates:", err)
 		return
 	}
+	reloadOnHangup()
 
 	// Plugins
 	if err = plugins.Load(); err == nil {
@@ -102,13 +143,13 @@ func main() {
 		// Start https server
 		log.Println("Starting https server on port " + httpsPort + "...")
 		go func() {
-			if err := https.StartServer(httpsPort, httpsRouter); err != nil {
+			if err := https.StartServer(httpsPort, security.Handler(httpsRouter)); err != nil {
 				log.Fatal("Error: Couldn't start the HTTPS server:", err)
 			}
 		}()
 		// Start http server
 		log.Println("Starting http server on port " + httpPort + "...")
-		if err := http.ListenAndServe(httpPort, httpRouter); err != nil {
+		if err := http.ListenAndServe(httpPort, security.Handler(httpRouter)); err != nil {
 			log.Fatal("Error: Couldn't start the HTTP server:", err)
 		}
 	case "All":
@@ -125,13 +166,13 @@ func main() {
 		// Start https server
 		log.Println("Starting https server on port " + httpsPort + "...")
 		go func() {
-			if err := https.StartServer(httpsPort, httpsRouter); err != nil {
+			if err := https.StartServer(httpsPort, security.Handler(httpsRouter)); err != nil {
 				log.Fatal("Error: Couldn't start the HTTPS server:", err)
 			}
 		}()
 		// Start http server
 		log.Println("Starting http server on port " + httpPort + "...")
-		if err := http.ListenAndServe(httpPort, httpRouter); err != nil {
+		if err := http.ListenAndServe(httpPort, security.Handler(httpRouter)); err != nil {
 			log.Fatal("Error: Couldn't start the HTTP server:", err)
 		}
 	default: // This is configuration.HttpsUsage == "None"
@@ -144,7 +185,7 @@ func main() {
 		// Start http server
 		log.Println("Starting server without HTTPS support. Please enable HTTPS in " + filenames.ConfigFilename + " to improve security.")
 		log.Println("Starting http server on port " + httpPort + "...")
-		if err := http.ListenAndServe(httpPort, httpRouter); err != nil {
+		if err := http.ListenAndServe(httpPort, security.Handler(httpRouter)); err != nil {
 			log.Fatal("Error: Couldn't start the HTTP server:", err)
 		}
 	}
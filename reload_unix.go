@@ -0,0 +1,27 @@
+// +build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"journey/templates"
+)
+
+// Function to compile the theme again when the process receives SIGHUP (e.g. kill -HUP <pid>)
+func reloadOnHangup() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			log.Println("Received SIGHUP, reloading templates...")
+			// Errors are logged by Generate, the blog keeps using the previous templates
+			if err := templates.Generate(); err == nil {
+				log.Println("Templates reloaded.")
+			}
+		}
+	}()
+}
@@ -0,0 +1,4 @@
+package main
+
+// There is no SIGHUP on Windows, the templates can be reloaded with the admin api
+func reloadOnHangup() {}
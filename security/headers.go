@@ -0,0 +1,75 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+
+	"journey/configuration"
+)
+
+// Placeholder in the Content-Security-Policy that gets replaced with the nonce of the request
+const NoncePlaceholder = "{nonce}"
+
+type contextKey int
+
+const nonceKey contextKey = 0
+
+// Function to wrap a router so that every response carries the security headers set in config.json.
+// Requests to the admin area get the admin policy, everything else gets the blog policy.
+func Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := &configuration.Config.SecurityHeaders.Blog
+		if r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/admin/") {
+			policy = &configuration.Config.SecurityHeaders.Admin
+		}
+		header := w.Header()
+		// HSTS is ignored by browsers on plain http and would lock users out if sent on a http only blog
+		if policy.Hsts != "" && r.TLS != nil {
+			header.Set("Strict-Transport-Security", policy.Hsts)
+		}
+		if policy.ContentTypeOptions != "" {
+			header.Set("X-Content-Type-Options", policy.ContentTypeOptions)
+		}
+		if policy.FrameOptions != "" {
+			header.Set("X-Frame-Options", policy.FrameOptions)
+		}
+		if policy.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", policy.ReferrerPolicy)
+		}
+		if policy.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", policy.PermissionsPolicy)
+		}
+		if policy.ContentSecurityPolicy != "" {
+			nonce, err := generateNonce()
+			if err != nil {
+				log.Println("Couldn't generate nonce:", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			header.Set("Content-Security-Policy", strings.Replace(policy.ContentSecurityPolicy, NoncePlaceholder, nonce, -1))
+			r = r.WithContext(context.WithValue(r.Context(), nonceKey, nonce))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Function to get the Content-Security-Policy nonce of a request. Returns an empty string if there is none.
+func Nonce(r *http.Request) string {
+	if nonce, ok := r.Context().Value(nonceKey).(string); ok {
+		return nonce
+	}
+	return ""
+}
+
+func generateNonce() (string, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(randomBytes), nil
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"journey/configuration"
+)
+
+var testPolicies = configuration.SecurityHeadersConfiguration{
+	Blog: configuration.SecurityPolicy{
+		Hsts:                  "max-age=60",
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "SAMEORIGIN",
+		ContentSecurityPolicy: "script-src 'nonce-{nonce}'",
+	},
+	Admin: configuration.SecurityPolicy{
+		Hsts:                  "max-age=120",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "camera=()",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'nonce-{nonce}' 'nonce-{nonce}'",
+	},
+}
+
+// Function to serve a request with the given policies. Returns the response and the nonce the handler received.
+func serveWithPolicies(t *testing.T, policies configuration.SecurityHeadersConfiguration, path string, https bool) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	config := configuration.Config
+	configuration.Config = &configuration.Configuration{SecurityHeaders: policies}
+	defer func() {
+		configuration.Config = config
+	}()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if https {
+		request.TLS = &tls.ConnectionState{}
+	}
+	nonce := ""
+	response := httptest.NewRecorder()
+	Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = Nonce(r)
+	})).ServeHTTP(response, request)
+	return response, nonce
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		path     string
+		https    bool
+		expected map[string]string
+	}{
+		{"/", false, map[string]string{"Strict-Transport-Security": "", "X-Content-Type-Options": "nosniff", "X-Frame-Options": "SAMEORIGIN", "Referrer-Policy": "", "Permissions-Policy": ""}},
+		{"/", true, map[string]string{"Strict-Transport-Security": "max-age=60", "X-Frame-Options": "SAMEORIGIN"}},
+		{"/administrator/", true, map[string]string{"Strict-Transport-Security": "max-age=60", "X-Frame-Options": "SAMEORIGIN"}},
+		{"/admin", false, map[string]string{"Strict-Transport-Security": "", "X-Content-Type-Options": "", "X-Frame-Options": "DENY", "Referrer-Policy": "no-referrer", "Permissions-Policy": "camera=()"}},
+		{"/admin/api/posts/1", true, map[string]string{"Strict-Transport-Security": "max-age=120", "X-Frame-Options": "DENY"}},
+	}
+	for _, test := range tests {
+		response, _ := serveWithPolicies(t, testPolicies, test.path, test.https)
+		for name, value := range test.expected {
+			if received := response.Header().Get(name); received != value {
+				t.Errorf("Expected %s: %q for %s (https: %v), received %q", name, value, test.path, test.https, received)
+			}
+		}
+	}
+}
+
+func TestHandlerNonce(t *testing.T) {
+	nonces := make(map[string]bool)
+	for _, path := range []string{"/", "/", "/admin/"} {
+		response, nonce := serveWithPolicies(t, testPolicies, path, false)
+		if nonce == "" || nonces[nonce] {
+			t.Fatalf("Expected a new nonce for every request, received %q", nonce)
+		}
+		nonces[nonce] = true
+		policy := response.Header().Get("Content-Security-Policy")
+		if strings.Contains(policy, NoncePlaceholder) || strings.Count(policy, "'nonce-"+nonce+"'") != strings.Count(policy, "'nonce-") {
+			t.Errorf("Expected every placeholder to be replaced with %q, received %q", nonce, policy)
+		}
+	}
+	// Requests that didn't pass the handler have no nonce
+	if nonce := Nonce(httptest.NewRequest(http.MethodGet, "/", nil)); nonce != "" {
+		t.Errorf("Expected no nonce, received %q", nonce)
+	}
+}
+
+func TestHandlerDisabled(t *testing.T) {
+	for _, path := range []string{"/", "/admin/"} {
+		response, nonce := serveWithPolicies(t, configuration.SecurityHeadersConfiguration{}, path, true)
+		if len(response.Header()) != 0 || nonce != "" {
+			t.Errorf("Expected no headers and no nonce for %s, received %v and %q", path, response.Header(), nonce)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -15,15 +18,18 @@ import (
 	"github.com/dimfeld/httptreemux"
 	"github.com/satori/go.uuid"
 	"journey/authentication"
+	"journey/cache"
 	"journey/configuration"
 	"journey/conversion"
 	"journey/database"
 	"journey/date"
 	"journey/filenames"
+	"journey/helpers"
 	"journey/slug"
 	"journey/structure"
 	"journey/structure/methods"
 	"journey/templates"
+	"journey/upload"
 )
 
 type JsonPost struct {
@@ -39,6 +45,10 @@ type JsonPost struct {
 	MetaDescription string
 	Date            *time.Time
 	Tags            string
+	// Only administrators can change the code injection
+	CodeInjectionHead string
+	CodeInjectionFoot string
+	CustomTemplate    string
 }
 
 type JsonBlog struct {
@@ -48,9 +58,17 @@ type JsonBlog struct {
 	Logo            string
 	Cover           string
 	Themes          []string
+	CustomTemplates []string // Custom templates of the active theme that posts can be shown with
 	ActiveTheme     string
+	Language        string
+	Timezone        string
 	PostsPerPage    int64
 	NavigationItems []structure.Navigation
+	// Navigation that themes show with {{navigation type="secondary"}}
+	SecondaryNavigationItems []structure.Navigation
+	// Only administrators can change the code injection
+	CodeInjectionHead string
+	CodeInjectionFoot string
 }
 
 type JsonUser struct {
@@ -75,6 +93,22 @@ type JsonImage struct {
 	Filename string
 }
 
+type JsonUploadError struct {
+	Error    string `json:"error"` // The upload widget of the admin area shows this field
+	Code     string
+	Filename string
+	Report   *templates.ThemeReport `json:",omitempty"` // Problems found in an uploaded theme
+}
+
+type JsonToken struct {
+	Id         int64
+	Name       string
+	Scopes     []string
+	Token      string `json:",omitempty"` // Only set once, right after the token was created
+	CreatedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
 // Function to serve the login page
 func getLoginHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
 	if database.RetrieveUsersCount() == 0 {
@@ -92,8 +126,10 @@ func postLoginHandler(w http.ResponseWriter, r *http.Request, _ map[string]strin
 	if name != "" && password != "" {
 		if authentication.LoginIsCorrect(name, password) {
 			logInUser(name, w)
+			recordAudit(r, name, auditLogin, "session", 0, "password", nil)
 		} else {
 			log.Println("Failed login attempt for user " + name)
+			recordAudit(r, name, auditLoginFailed, "session", 0, "password", nil)
 		}
 	}
 	http.Redirect(w, r, "/admin/", 302)
@@ -128,6 +164,7 @@ func postRegistrationHandler(w http.ResponseWriter, r *http.Request, _ map[strin
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			recordAudit(r, name, auditUserCreate, "user", user.Id, name, map[string]auditChange{"Email": {New: email}, "Role": {New: user.Role}})
 			http.Redirect(w, r, "/admin/", 302)
 			return
 		}
@@ -179,7 +216,7 @@ func adminFileHandler(w http.ResponseWriter, r *http.Request, params map[string]
 
 // API function to get all posts by pages
 func apiPostsHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		number := params["number"]
 
@@ -215,7 +252,7 @@ func apiPostsHandler(w http.ResponseWriter, r *http.Request, params map[string]s
 
 // API function to get a post by id
 func getApiPostHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		id := params["id"]
 		// Get post
@@ -252,7 +289,7 @@ func getApiPostHandler(w http.ResponseWriter, r *http.Request, params map[string
 
 // API function to create a post
 func postApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopePosts)
 	if userName != "" {
 		userId, err := getUserId(userName)
 		if err != nil {
@@ -274,12 +311,20 @@ func postApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]str
 			postSlug = slug.Generate(jsonPost.Title, "posts")
 		}
 		currentTime := date.GetCurrentTime()
-		post := structure.Post{Title: []byte(jsonPost.Title), Slug: postSlug, Markdown: []byte(jsonPost.Markdown), Html: conversion.GenerateHtmlFromMarkdown([]byte(jsonPost.Markdown)), IsFeatured: jsonPost.IsFeatured, IsPage: jsonPost.IsPage, IsPublished: jsonPost.IsPublished, MetaDescription: []byte(jsonPost.MetaDescription), Image: []byte(jsonPost.Image), Date: &currentTime, Tags: methods.GenerateTagsFromCommaString(jsonPost.Tags), Author: &structure.User{Id: userId}}
+		post := structure.Post{Title: []byte(jsonPost.Title), Slug: postSlug, Markdown: []byte(jsonPost.Markdown), Html: conversion.GenerateHtmlFromMarkdown([]byte(jsonPost.Markdown)), IsFeatured: jsonPost.IsFeatured, IsPage: jsonPost.IsPage, IsPublished: jsonPost.IsPublished, MetaDescription: []byte(jsonPost.MetaDescription), Image: []byte(jsonPost.Image), Date: &currentTime, Tags: methods.GenerateTagsFromCommaString(jsonPost.Tags), Author: &structure.User{Id: userId}, CustomTemplate: customTemplate(jsonPost.CustomTemplate)}
+		if isAdministrator(userName) {
+			post.CodeInjectionHead = []byte(jsonPost.CodeInjectionHead)
+			post.CodeInjectionFoot = []byte(jsonPost.CodeInjectionFoot)
+		}
 		err = methods.SavePost(&post)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordAudit(r, userName, auditPostCreate, "post", post.Id, string(post.Title), auditDiff(&JsonPost{}, postToJson(&post), "Id", "Html", "Date"))
+		if post.IsPublished {
+			recordAudit(r, userName, auditPostPublish, "post", post.Id, string(post.Title), nil)
+		}
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Post created!"))
 		return
@@ -291,7 +336,7 @@ func postApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]str
 
 // API function to update a post.
 func patchApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopePosts)
 	if userName != "" {
 		userId, err := getUserId(userName)
 		if err != nil {
@@ -318,13 +363,24 @@ func patchApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 		} else {
 			postSlug = post.Slug
 		}
+		oldPost := postToJson(post)
+		codeInjectionHead, codeInjectionFoot := post.CodeInjectionHead, post.CodeInjectionFoot
+		if isAdministrator(userName) {
+			codeInjectionHead, codeInjectionFoot = []byte(jsonPost.CodeInjectionHead), []byte(jsonPost.CodeInjectionFoot)
+		}
 		currentTime := date.GetCurrentTime()
-		*post = structure.Post{Id: jsonPost.Id, Title: []byte(jsonPost.Title), Slug: postSlug, Markdown: []byte(jsonPost.Markdown), Html: conversion.GenerateHtmlFromMarkdown([]byte(jsonPost.Markdown)), IsFeatured: jsonPost.IsFeatured, IsPage: jsonPost.IsPage, IsPublished: jsonPost.IsPublished, MetaDescription: []byte(jsonPost.MetaDescription), Image: []byte(jsonPost.Image), Date: &currentTime, Tags: methods.GenerateTagsFromCommaString(jsonPost.Tags), Author: &structure.User{Id: userId}}
+		*post = structure.Post{Id: jsonPost.Id, Title: []byte(jsonPost.Title), Slug: postSlug, Markdown: []byte(jsonPost.Markdown), Html: conversion.GenerateHtmlFromMarkdown([]byte(jsonPost.Markdown)), IsFeatured: jsonPost.IsFeatured, IsPage: jsonPost.IsPage, IsPublished: jsonPost.IsPublished, MetaDescription: []byte(jsonPost.MetaDescription), Image: []byte(jsonPost.Image), Date: &currentTime, Tags: methods.GenerateTagsFromCommaString(jsonPost.Tags), Author: &structure.User{Id: userId}, CodeInjectionHead: codeInjectionHead, CodeInjectionFoot: codeInjectionFoot, CustomTemplate: customTemplate(jsonPost.CustomTemplate)}
 		err = methods.UpdatePost(post)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordAudit(r, userName, auditPostUpdate, "post", post.Id, string(post.Title), auditDiff(oldPost, postToJson(post), "Html", "Date"))
+		if post.IsPublished && !oldPost.IsPublished {
+			recordAudit(r, userName, auditPostPublish, "post", post.Id, string(post.Title), nil)
+		} else if !post.IsPublished && oldPost.IsPublished {
+			recordAudit(r, userName, auditPostUnpublish, "post", post.Id, string(post.Title), nil)
+		}
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Post updated!"))
 		return
@@ -336,7 +392,7 @@ func patchApiPostHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 
 // API function to delete a post by id.
 func deleteApiPostHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopePosts)
 	if userName != "" {
 		id := params["id"]
 		// Delete post
@@ -350,11 +406,17 @@ func deleteApiPostHandler(w http.ResponseWriter, r *http.Request, params map[str
 			return
 		}
 
+		// Get the title of the post for the audit log
+		postTitle := ""
+		if post, err := database.RetrievePostById(postId); err == nil {
+			postTitle = string(post.Title)
+		}
 		err = methods.DeletePost(postId)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordAudit(r, userName, auditPostDelete, "post", postId, postTitle, nil)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Post deleted!"))
 		return
@@ -366,55 +428,61 @@ func deleteApiPostHandler(w http.ResponseWriter, r *http.Request, params map[str
 
 // API function to upload images
 func apiUploadHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeUpload)
 	if userName != "" {
+		r.Body = http.MaxBytesReader(w, r.Body, configuration.Config.Uploads.MaxRequestSize)
 		// Create multipart reader
 		reader, err := r.MultipartReader()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeUploadError(w, toUploadError(err), nil)
 			return
 		}
-		// Slice to hold all paths to the files
+		// Slices to hold all paths to the files (on disk and as url)
+		savedFiles := make([]string, 0)
 		allFilePaths := make([]string, 0)
-		// Copy each part to destination.
+		// Names of the files as sent by the client (for the audit log)
+		originalNames := make([]string, 0)
+		// Check and copy each part to destination.
 		for {
 			part, err := reader.NextPart()
-			if part == nil || err == io.EOF {
+			if err == io.EOF {
 				break
+			} else if err != nil {
+				writeUploadError(w, toUploadError(err), savedFiles)
+				return
 			}
-
 			// If part.FileName() is empty, skip this iteration.
 			if part.FileName() == "" {
 				continue
 			}
+			// The file type is determined by the content. The extension sent by the client is ignored.
+			data, extension, err := upload.Process(part.FileName(), part, configuration.Config.Uploads.MaxFileSize)
+			if err != nil {
+				writeUploadError(w, toUploadError(err), savedFiles)
+				return
+			}
 			// Folder structure: year/month/randomname
 			currentDate := date.GetCurrentTime()
 			filePath := filepath.Join(filenames.ImagesFilepath, currentDate.Format("2006"), currentDate.Format("01"))
 			if err = os.MkdirAll(filePath, 0777); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeUploadError(w, err, savedFiles)
 				return
 			}
-
-			dst, err := os.Create(filepath.Join(filePath, strconv.FormatInt(currentDate.Unix(), 10)+"_"+uuid.NewV4().String()+filepath.Ext(part.FileName())))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			filePath = filepath.Join(filePath, strconv.FormatInt(currentDate.Unix(), 10)+"_"+uuid.NewV4().String()+extension)
+			if err = ioutil.WriteFile(filePath, data, 0644); err != nil {
+				writeUploadError(w, err, savedFiles)
 				return
 			}
-
-			defer func() {
-				_ = dst.Close()
-			}()
-
-			if _, err := io.Copy(dst, part); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
+			savedFiles = append(savedFiles, filePath)
 			// Rewrite to file path on server
-			filePath = strings.Replace(dst.Name(), filenames.ImagesFilepath, "/images", 1)
+			filePath = strings.Replace(filePath, filenames.ImagesFilepath, "/images", 1)
 			// Make sure to always use "/" as path separator (to make a valid url that we can use on the blog)
 			filePath = filepath.ToSlash(filePath)
 			allFilePaths = append(allFilePaths, filePath)
+			originalNames = append(originalNames, part.FileName())
+		}
+		for index, filePath := range allFilePaths {
+			recordAudit(r, userName, auditImageUpload, "image", 0, filePath, map[string]auditChange{"Filename": {New: originalNames[index]}})
 		}
 		jsonBytes, err := json.Marshal(allFilePaths)
 		if err != nil {
@@ -430,9 +498,51 @@ func apiUploadHandler(w http.ResponseWriter, r *http.Request, _ map[string]strin
 	}
 }
 
+// Function to turn errors that happen while reading an upload into errors that can be shown in the admin area
+func toUploadError(err error) error {
+	var uploadError *upload.Error
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &uploadError) {
+		return uploadError
+	} else if errors.As(err, &maxBytesError) {
+		return &upload.Error{Code: upload.ErrorRequestTooLarge, Message: "The upload is larger than " + upload.FormatSize(maxBytesError.Limit) + ". Please upload fewer files at once.", StatusCode: http.StatusRequestEntityTooLarge}
+	}
+	return &upload.Error{Code: upload.ErrorMalformedRequest, Message: err.Error(), StatusCode: http.StatusBadRequest}
+}
+
+// Function to reject an upload. Files of the same request that were already saved are removed again.
+func writeUploadError(w http.ResponseWriter, err error, savedFiles []string) {
+	for _, filePath := range savedFiles {
+		if removeErr := os.Remove(filePath); removeErr != nil {
+			log.Println("Couldn't remove uploaded file:", removeErr)
+		}
+	}
+	writeJsonError(w, JsonUploadError{Error: err.Error(), Code: "internal_error"}, err)
+}
+
+func writeJsonError(w http.ResponseWriter, jsonError JsonUploadError, err error) {
+	statusCode := http.StatusInternalServerError
+	if uploadError, ok := err.(*upload.Error); ok {
+		jsonError.Code = uploadError.Code
+		// Errors of an uploaded theme don't carry the name of the archive
+		if uploadError.Filename != "" {
+			jsonError.Filename = uploadError.Filename
+		}
+		statusCode = uploadError.StatusCode
+	}
+	jsonBytes, err := json.Marshal(jsonError)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(jsonBytes)
+}
+
 // API function to get all images by pages
 func apiImagesHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		number := params["number"]
 		page, err := strconv.Atoi(number)
@@ -443,7 +553,7 @@ func apiImagesHandler(w http.ResponseWriter, r *http.Request, params map[string]
 		images := make([]string, 0)
 		// Walk all files in images folder
 		err = filepath.Walk(filenames.ImagesFilepath, func(filePath string, info os.FileInfo, err error) error {
-			if !info.IsDir() && (strings.EqualFold(filepath.Ext(filePath), ".jpg") || strings.EqualFold(filepath.Ext(filePath), ".jpeg") || strings.EqualFold(filepath.Ext(filePath), ".gif") || strings.EqualFold(filepath.Ext(filePath), ".png") || strings.EqualFold(filepath.Ext(filePath), ".svg")) {
+			if !info.IsDir() && upload.IsImageFilename(filePath) {
 				// Rewrite to file path on server
 				filePath = strings.Replace(filePath, filenames.ImagesFilepath, "/images", 1)
 				// Make sure to always use "/" as path separator (to make a valid url that we can use on the blog)
@@ -487,7 +597,7 @@ func apiImagesHandler(w http.ResponseWriter, r *http.Request, params map[string]
 
 // API function to delete an image by its filename.
 func deleteApiImageHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeUpload)
 	if userName != "" { // TODO: Check if the user has permissions to delete the image
 		// Get the file name from the json data
 		decoder := json.NewDecoder(r.Body)
@@ -510,6 +620,7 @@ func deleteApiImageHandler(w http.ResponseWriter, r *http.Request, _ map[string]
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordAudit(r, userName, auditImageDelete, "image", 0, jsonImg.Filename, nil)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Image deleted!"))
 		return
@@ -521,7 +632,7 @@ func deleteApiImageHandler(w http.ResponseWriter, r *http.Request, _ map[string]
 
 // API function to get blog settings
 func getApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		// Read lock the global blog
 		methods.Blog.RLock()
@@ -543,7 +654,7 @@ func getApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]stri
 
 // API function to update blog settings
 func patchApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeSettings)
 	if userName != "" {
 		userId, err := getUserId(userName)
 		if err != nil {
@@ -557,18 +668,27 @@ func patchApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		// Make sure postPerPage is over 0
-		if jsonPost.PostsPerPage < 1 {
-			jsonPost.PostsPerPage = 1
+		// Make sure postPerPage isn't negative (0 = use the default of the theme)
+		if jsonPost.PostsPerPage < 0 {
+			jsonPost.PostsPerPage = 0
 		}
-		// Remove blog url in front of navigation urls
-		for index := range jsonPost.NavigationItems {
-			if strings.HasPrefix(jsonPost.NavigationItems[index].Url, jsonPost.Url) {
-				jsonPost.NavigationItems[index].Url = strings.Replace(jsonPost.NavigationItems[index].Url, jsonPost.Url, "", 1)
-				// If we removed the blog url, there should be a / in front of the url
-				if !strings.HasPrefix(jsonPost.NavigationItems[index].Url, "/") {
-					jsonPost.NavigationItems[index].Url = "/" + jsonPost.NavigationItems[index].Url
-				}
+		if jsonPost.Language == "" {
+			jsonPost.Language = "en"
+		} else if !templates.ValidLanguage(jsonPost.Language) {
+			http.Error(w, "Invalid language: "+jsonPost.Language, http.StatusBadRequest)
+			return
+		}
+		if jsonPost.Timezone == "" {
+			jsonPost.Timezone = "UTC"
+		} else if !date.ValidTimezone(jsonPost.Timezone) {
+			http.Error(w, "Invalid timezone: "+jsonPost.Timezone, http.StatusBadRequest)
+			return
+		}
+		// Remove blog url in front of navigation urls and make sure that all urls are links
+		for _, items := range [][]structure.Navigation{jsonPost.NavigationItems, jsonPost.SecondaryNavigationItems} {
+			if err = checkNavigation(items, jsonPost.Url, 1); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 		}
 		// Retrieve old blog settings for comparison
@@ -577,21 +697,33 @@ func patchApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		tempBlog := structure.Blog{Url: []byte(configuration.Config.Url), Title: []byte(jsonPost.Title), Description: []byte(jsonPost.Description), Logo: []byte(jsonPost.Logo), Cover: []byte(jsonPost.Cover), AssetPath: []byte("/assets/"), PostCount: blog.PostCount, PostsPerPage: jsonPost.PostsPerPage, ActiveTheme: jsonPost.ActiveTheme, NavigationItems: jsonPost.NavigationItems}
+		tempBlog := structure.Blog{Url: []byte(configuration.Config.Url), Title: []byte(jsonPost.Title), Description: []byte(jsonPost.Description), Logo: []byte(jsonPost.Logo), Cover: []byte(jsonPost.Cover), AssetPath: []byte("/assets/"), PostCount: blog.PostCount, PostsPerPage: jsonPost.PostsPerPage, ActiveTheme: jsonPost.ActiveTheme, Language: jsonPost.Language, Timezone: jsonPost.Timezone, NavigationItems: jsonPost.NavigationItems, SecondaryNavigationItems: jsonPost.SecondaryNavigationItems, CodeInjectionHead: blog.CodeInjectionHead, CodeInjectionFoot: blog.CodeInjectionFoot}
+		if isAdministrator(userName) {
+			tempBlog.CodeInjectionHead = []byte(jsonPost.CodeInjectionHead)
+			tempBlog.CodeInjectionFoot = []byte(jsonPost.CodeInjectionFoot)
+		}
 		err = methods.UpdateBlog(&tempBlog, userId)
-		// Check if active theme setting has been changed, if so, generate templates from new theme
-		if tempBlog.ActiveTheme != blog.ActiveTheme {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Check if active theme or language setting has been changed, if so, generate templates from new theme and load its translations
+		if tempBlog.ActiveTheme != blog.ActiveTheme || tempBlog.Language != blog.Language {
 			err = templates.Generate()
 			if err != nil {
-				// If there's an error while generating the new templates, the whole program must be stopped.
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				log.Fatal("Fatal error: Template data couldn't be generated from theme files: " + err.Error())
+				// The blog keeps using the previous templates, so the previous settings (including theme and language) are restored
+				if revertErr := methods.UpdateBlog(blog, userId); revertErr != nil {
+					log.Println("Couldn't restore the blog settings:", revertErr)
+				}
+				http.Error(w, "Couldn't compile the theme "+tempBlog.ActiveTheme+": "+err.Error(), http.StatusBadRequest)
 				return
 			}
+			if tempBlog.ActiveTheme != blog.ActiveTheme {
+				recordAudit(r, userName, auditThemeChange, "theme", 0, tempBlog.ActiveTheme, map[string]auditChange{"ActiveTheme": {Old: blog.ActiveTheme, New: tempBlog.ActiveTheme}})
+			}
 		}
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		if changes := auditDiff(blogToJson(blog), blogToJson(&tempBlog), "Themes", "CustomTemplates", "ActiveTheme", "Url"); len(changes) > 0 {
+			recordAudit(r, userName, auditSettingsUpdate, "settings", 0, "blog", changes)
 		}
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Blog settings updated!"))
@@ -604,7 +736,7 @@ func patchApiBlogHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 
 // API function to get user settings
 func getApiUserHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		userId, err := getUserId(userName)
 		if err != nil {
@@ -709,6 +841,7 @@ func patchApiUserHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		changes := auditDiff(userToJson(tempUser), userToJson(&user), "Password", "PasswordRepeated")
 		if jsonPost.Password != "" && (jsonPost.Password == jsonPost.PasswordRepeated) { // Update password if a new one was submitted
 			encryptedPassword, err := authentication.EncryptPassword(jsonPost.Password)
 			if err != nil {
@@ -720,7 +853,10 @@ func patchApiUserHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			// Never write passwords or their hashes to the audit log
+			changes["Password"] = auditChange{New: "changed"}
 		}
+		recordAudit(r, jsonPost.Name, auditUserUpdate, "user", user.Id, jsonPost.Name, changes)
 		// Check if the user name was changed. If so, update the session cookie to the new user name.
 		if jsonPost.Name != string(tempUser.Name) {
 			logInUser(jsonPost.Name, w)
@@ -736,7 +872,7 @@ func patchApiUserHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 
 // API function to get the id of the currently authenticated user
 func getApiUserIdHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-	userName := authentication.GetUserName(r)
+	userName := getApiUserName(r, authentication.ScopeRead)
 	if userName != "" {
 		userId, err := getUserId(userName)
 		if err != nil {
@@ -758,6 +894,454 @@ func getApiUserIdHandler(w http.ResponseWriter, r *http.Request, _ map[string]st
 	}
 }
 
+// API function to get the hit and miss counters of the page cache
+func getApiCacheHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		if !isAdministrator(userName) {
+			http.Error(w, "You don't have permission to access the cache statistics.", http.StatusForbidden)
+			return
+		}
+		jsonBytes, err := json.Marshal(cache.Pages.Stats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to install a theme from a zip archive. Installed themes are only replaced if "overwrite" is set.
+func postApiThemeHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		if !isAdministrator(userName) {
+			http.Error(w, "You don't have permission to install themes.", http.StatusForbidden)
+			return
+		}
+		maxSize := configuration.Config.Uploads.MaxThemeSize
+		// Leave some room for the other form fields
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+1024*1024)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeUploadError(w, toUploadError(err), nil)
+			return
+		}
+		file, header, err := r.FormFile("theme")
+		if err != nil {
+			writeUploadError(w, toUploadError(err), nil)
+			return
+		}
+		defer file.Close()
+		archive, err := ioutil.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			writeUploadError(w, toUploadError(err), nil)
+			return
+		}
+		if int64(len(archive)) > maxSize {
+			writeUploadError(w, &upload.Error{Code: upload.ErrorFileTooLarge, Message: "The theme is larger than " + upload.FormatSize(maxSize) + ".", Filename: header.Filename, StatusCode: http.StatusRequestEntityTooLarge}, nil)
+			return
+		}
+		// The name of the theme is the name of the archive unless a name is given
+		name := r.FormValue("name")
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+		}
+		overwrite := r.FormValue("overwrite") == "true"
+		report, err := templates.InstallTheme(name, archive, maxSize, overwrite)
+		if err != nil {
+			writeJsonError(w, JsonUploadError{Error: err.Error(), Code: "internal_error", Filename: header.Filename, Report: report}, err)
+			return
+		}
+		changes := map[string]auditChange{"Filename": {New: header.Filename}}
+		if overwrite {
+			changes["Overwrite"] = auditChange{New: true}
+		}
+		recordAudit(r, userName, auditThemeInstall, "theme", 0, name, changes)
+		// The active theme has been replaced, so the templates need to be compiled again
+		methods.Blog.RLock()
+		activeTheme := methods.Blog.ActiveTheme
+		methods.Blog.RUnlock()
+		if name == activeTheme {
+			err = templates.Generate()
+			if err != nil {
+				http.Error(w, "The theme was installed, but the blog keeps using the previous templates: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		jsonBytes, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to download an installed theme as a zip archive
+func getApiThemeHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		if !isAdministrator(userName) {
+			http.Error(w, "You don't have permission to download themes.", http.StatusForbidden)
+			return
+		}
+		name := params["name"]
+		if !templates.ValidThemeName(name) || !helpers.IsDirectory(filepath.Join(filenames.ThemesFilepath, name)) {
+			http.Error(w, "Theme not found.", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+name+".zip\"")
+		err := templates.WriteThemeArchive(w, name)
+		if err != nil {
+			// The headers have already been sent at this point
+			log.Println("Couldn't write theme archive:", err)
+		}
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to delete a theme that isn't active
+func deleteApiThemeHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		if !isAdministrator(userName) {
+			http.Error(w, "You don't have permission to delete themes.", http.StatusForbidden)
+			return
+		}
+		name := params["name"]
+		err := templates.DeleteTheme(name)
+		if err != nil {
+			writeUploadError(w, err, nil)
+			return
+		}
+		recordAudit(r, userName, auditThemeDelete, "theme", 0, name, nil)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Theme deleted!"))
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to get the custom settings of the active theme
+func getApiThemeSettingsHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeRead)
+	if userName != "" {
+		jsonBytes, err := json.Marshal(templates.GetThemeSettings())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to change the custom settings of the active theme. The body maps setting names to values.
+func patchApiThemeSettingsHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		userId, err := getUserId(userName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var values map[string]interface{}
+		err = json.NewDecoder(r.Body).Decode(&values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		oldSettings := templates.GetThemeSettings()
+		theme, checkedValues, err := templates.CheckThemeSettings(values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonBytes, err := json.Marshal(checkedValues)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = database.UpdateThemeSettings(theme, jsonBytes, date.GetCurrentTime(), userId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = templates.ReloadThemeSettings()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		changes := make(map[string]auditChange)
+		for _, setting := range oldSettings.Settings {
+			if newValue := checkedValues[setting.Name]; newValue != setting.Value {
+				changes[setting.Name] = auditChange{Old: setting.Value, New: newValue}
+			}
+		}
+		if len(changes) > 0 {
+			recordAudit(r, userName, auditThemeSettingsUpdate, "theme", 0, theme, changes)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Theme settings updated!"))
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to get the result of the last compilation of the templates
+func getApiReloadHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeRead)
+	if userName != "" {
+		writeReloadStatus(w, http.StatusOK)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to compile the active theme again, e.g. after its files have been changed on the server
+func postApiReloadHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		if !isAdministrator(userName) {
+			http.Error(w, "You don't have permission to reload the templates.", http.StatusForbidden)
+			return
+		}
+		// The error is part of the status
+		statusCode := http.StatusOK
+		if err := templates.Generate(); err != nil {
+			statusCode = http.StatusBadRequest
+		}
+		writeReloadStatus(w, statusCode)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+func writeReloadStatus(w http.ResponseWriter, statusCode int) {
+	jsonBytes, err := json.Marshal(templates.GetReloadStatus())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(jsonBytes)
+}
+
+// API function to get all tokens of the authenticated user
+func getApiTokensHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := authentication.GetUserName(r)
+	if userName != "" {
+		userId, err := getUserId(userName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tokens, err := database.RetrieveTokensByUser(userId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonBytes, err := json.Marshal(tokensToJson(tokens))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to create a token. The token itself is only returned once and can't be retrieved later on.
+func postApiTokenHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	userName := authentication.GetUserName(r)
+	if userName != "" {
+		user, err := database.RetrieveUserByName([]byte(userName))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		decoder := json.NewDecoder(r.Body)
+		var jsonToken JsonToken
+		err = decoder.Decode(&jsonToken)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.TrimSpace(jsonToken.Name) == "" {
+			http.Error(w, "The token needs a name.", http.StatusBadRequest)
+			return
+		}
+		if len(jsonToken.Scopes) == 0 {
+			http.Error(w, "The token needs at least one scope.", http.StatusBadRequest)
+			return
+		}
+		for _, scope := range jsonToken.Scopes {
+			if !authentication.IsValidScope(scope) {
+				http.Error(w, "Unknown scope: "+scope, http.StatusBadRequest)
+				return
+			} else if !authentication.ScopeAllowedForRole(scope, user.Role) {
+				http.Error(w, "You don't have permission to create a token with scope "+scope+".", http.StatusForbidden)
+				return
+			}
+		}
+		token, tokenHash, err := authentication.GenerateToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		currentTime := date.GetCurrentTime()
+		tokenId, err := database.InsertToken([]byte(jsonToken.Name), tokenHash, strings.Join(jsonToken.Scopes, ","), user.Id, currentTime)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, userName, auditTokenCreate, "token", tokenId, jsonToken.Name, map[string]auditChange{"Scopes": {New: jsonToken.Scopes}})
+		jsonBytes, err := json.Marshal(JsonToken{Id: tokenId, Name: jsonToken.Name, Scopes: jsonToken.Scopes, Token: token, CreatedAt: &currentTime})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// API function to revoke a token by id
+func deleteApiTokenHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	userName := authentication.GetUserName(r)
+	if userName != "" {
+		userId, err := getUserId(userName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tokenId, err := strconv.ParseInt(params["id"], 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Users can only revoke their own tokens
+		err = database.DeleteTokenByIdAndUser(tokenId, userId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, userName, auditTokenDelete, "token", tokenId, "", nil)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Token revoked!"))
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Function to get the name of the user calling the api. Accepts the session cookie as well as a bearer token with the given scope.
+func getApiUserName(r *http.Request, scope string) string {
+	userName := authentication.GetUserName(r)
+	if userName != "" {
+		return userName
+	}
+	return authentication.GetTokenUserName(r, scope)
+}
+
+// Function to check if a user is an administrator or the owner of the blog
+func isAdministrator(userName string) bool {
+	user, err := database.RetrieveUserByName([]byte(userName))
+	if err != nil {
+		return false
+	}
+	return user.Role == 1 || user.Role == 4 // 1 = Administrator, 4 = Owner
+}
+
+// Levels of navigation items: top level items and their children
+const maxNavigationDepth = 2
+
+// Function to remove the blog url in front of navigation urls and to check that the urls can be used as links.
+// Items can have children (drop-down menus), but children can't have children of their own.
+func checkNavigation(items []structure.Navigation, blogUrl string, depth int) error {
+	for index := range items {
+		item := &items[index]
+		if blogUrl != "" && strings.HasPrefix(item.Url, blogUrl) {
+			item.Url = strings.Replace(item.Url, blogUrl, "", 1)
+			// If we removed the blog url, there should be a / in front of the url
+			if !strings.HasPrefix(item.Url, "/") {
+				item.Url = "/" + item.Url
+			}
+		}
+		if !validNavigationUrl(item.Url) {
+			return errors.New("Invalid url for navigation item " + item.Label + ": " + item.Url)
+		}
+		if len(item.Children) != 0 && depth >= maxNavigationDepth {
+			return errors.New("The navigation item " + item.Label + " can't have children.")
+		}
+		if err := checkNavigation(item.Children, blogUrl, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to check if a navigation url is a path on the blog, an anchor or a web, email or phone link
+func validNavigationUrl(navigationUrl string) bool {
+	parsedUrl, err := url.Parse(navigationUrl)
+	if err != nil {
+		return false
+	}
+	switch parsedUrl.Scheme {
+	case "":
+		return parsedUrl.Host == "" && (strings.HasPrefix(navigationUrl, "/") || strings.HasPrefix(navigationUrl, "#"))
+	case "http", "https":
+		return parsedUrl.Host != ""
+	case "mailto", "tel":
+		return parsedUrl.Opaque != ""
+	}
+	return false
+}
+
+// Function to make sure that only custom-<name> templates can be selected for a post
+func customTemplate(name string) string {
+	if !strings.HasPrefix(name, "custom-") {
+		return ""
+	}
+	return name
+}
+
 func getUserId(userName string) (int64, error) {
 	user, err := database.RetrieveUserByName([]byte(userName))
 	if err != nil {
@@ -799,6 +1383,9 @@ func postToJson(post *structure.Post) *JsonPost {
 	jsonPost.MetaDescription = string(post.MetaDescription)
 	jsonPost.Image = string(post.Image)
 	jsonPost.Date = post.Date
+	jsonPost.CodeInjectionHead = string(post.CodeInjectionHead)
+	jsonPost.CodeInjectionFoot = string(post.CodeInjectionFoot)
+	jsonPost.CustomTemplate = post.CustomTemplate
 	tags := make([]string, len(post.Tags))
 	for index := range post.Tags {
 		tags[index] = string(post.Tags[index].Name)
@@ -807,6 +1394,14 @@ func postToJson(post *structure.Post) *JsonPost {
 	return &jsonPost
 }
 
+func tokensToJson(tokens []structure.Token) *[]JsonToken {
+	jsonTokens := make([]JsonToken, len(tokens))
+	for index := range tokens {
+		jsonTokens[index] = JsonToken{Id: tokens[index].Id, Name: string(tokens[index].Name), Scopes: tokens[index].Scopes, CreatedAt: tokens[index].CreatedAt, LastUsedAt: tokens[index].LastUsedAt}
+	}
+	return &jsonTokens
+}
+
 func blogToJson(blog *structure.Blog) *JsonBlog {
 	var jsonBlog JsonBlog
 	jsonBlog.Url = string(blog.Url)
@@ -816,8 +1411,14 @@ func blogToJson(blog *structure.Blog) *JsonBlog {
 	jsonBlog.Cover = string(blog.Cover)
 	jsonBlog.PostsPerPage = blog.PostsPerPage
 	jsonBlog.Themes = templates.GetAllThemes()
+	jsonBlog.CustomTemplates = templates.GetCustomTemplates()
 	jsonBlog.ActiveTheme = blog.ActiveTheme
+	jsonBlog.Language = blog.Language
+	jsonBlog.Timezone = blog.Timezone
 	jsonBlog.NavigationItems = blog.NavigationItems
+	jsonBlog.SecondaryNavigationItems = blog.SecondaryNavigationItems
+	jsonBlog.CodeInjectionHead = string(blog.CodeInjectionHead)
+	jsonBlog.CodeInjectionFoot = string(blog.CodeInjectionFoot)
 	return &jsonBlog
 }
 
@@ -843,6 +1444,10 @@ func InitializeAdmin(router *httptreemux.TreeMux) {
 	router.GET("/admin/register/", getRegistrationHandler)
 	router.POST("/admin/register/", postRegistrationHandler)
 	router.GET("/admin/logout/", logoutHandler)
+	// Single sign-on
+	router.GET("/admin/oidc/", getOidcHandler)
+	router.GET("/admin/oidc/login/", oidcLoginHandler)
+	router.GET("/admin/oidc/callback/", oidcCallbackHandler)
 	router.GET("/admin/*filepath", adminFileHandler)
 
 	// For admin API (no trailing slash)
@@ -866,4 +1471,22 @@ func InitializeAdmin(router *httptreemux.TreeMux) {
 	router.PATCH("/admin/api/user", patchApiUserHandler)
 	// User id
 	router.GET("/admin/api/userid", getApiUserIdHandler)
+	// Tokens
+	router.GET("/admin/api/tokens", getApiTokensHandler)
+	router.POST("/admin/api/token", postApiTokenHandler)
+	router.DELETE("/admin/api/token/:id", deleteApiTokenHandler)
+	// Audit log
+	router.GET("/admin/api/audit/:number", apiAuditLogHandler)
+	// Page cache
+	router.GET("/admin/api/cache", getApiCacheHandler)
+
+	router.POST("/admin/api/theme", postApiThemeHandler)
+	router.GET("/admin/api/theme/:name", getApiThemeHandler)
+	router.DELETE("/admin/api/theme/:name", deleteApiThemeHandler)
+
+	router.GET("/admin/api/themesettings", getApiThemeSettingsHandler)
+	router.PATCH("/admin/api/themesettings", patchApiThemeSettingsHandler)
+
+	router.GET("/admin/api/reload", getApiReloadHandler)
+	router.POST("/admin/api/reload", postApiReloadHandler)
 }
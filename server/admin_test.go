@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"journey/authentication"
+	"journey/structure"
+	"journey/structure/methods"
+	"journey/upload"
+)
+
+// Function to create a user with the given role (1 = Administrator, 2 = Editor, 3 = Author, 4 = Owner) in the test database
+func createTestUser(t *testing.T, name string, role int) *structure.User {
+	t.Helper()
+	user := structure.User{Name: []byte(name), Slug: name, Email: []byte(name + "@example.com"), Role: role}
+	if err := methods.SaveUser(&user, "", 1); err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// Function to call an admin handler with the session of the given user (or without a session if it is empty)
+func callAdminHandler(handler func(http.ResponseWriter, *http.Request, map[string]string), userName string, method string, target string, body string, params map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if userName != "" {
+		session := httptest.NewRecorder()
+		authentication.SetSession(userName, session)
+		for _, cookie := range session.Result().Cookies() {
+			request.AddCookie(cookie)
+		}
+	}
+	response := httptest.NewRecorder()
+	handler(response, request, params)
+	return response
+}
+
+func TestTokenHandlers(t *testing.T) {
+	defer useTestDatabase(t)()
+	createTestUser(t, "admin", 1)
+	createTestUser(t, "author", 3)
+	tests := []struct {
+		userName string
+		body     string
+		code     int
+	}{
+		{"author", `{"Name": "Deploy", "Scopes": ["settings"]}`, http.StatusForbidden},
+		{"author", `{"Name": "Deploy", "Scopes": ["posts", "admin"]}`, http.StatusBadRequest},
+		{"author", `{"Name": " ", "Scopes": ["posts"]}`, http.StatusBadRequest},
+		{"author", `{"Name": "Deploy", "Scopes": []}`, http.StatusBadRequest},
+		{"", `{"Name": "Deploy", "Scopes": ["posts"]}`, http.StatusInternalServerError},
+		{"author", `{"Name": "Deploy", "Scopes": ["posts"]}`, http.StatusOK},
+		{"admin", `{"Name": "Deploy", "Scopes": ["settings", "upload"]}`, http.StatusOK},
+	}
+	var token JsonToken
+	for _, test := range tests {
+		response := callAdminHandler(postApiTokenHandler, test.userName, http.MethodPost, "/admin/api/token", test.body, nil)
+		if response.Code != test.code {
+			t.Errorf("Expected %d for %s by %q, received %d: %s", test.code, test.body, test.userName, response.Code, response.Body.String())
+		}
+		if response.Code == http.StatusOK {
+			if err := json.Unmarshal(response.Body.Bytes(), &token); err != nil || token.Token == "" {
+				t.Fatalf("Expected a new token, received %s", response.Body.String())
+			}
+		}
+	}
+	// The last token belongs to the administrator
+	authorized := func() bool {
+		request := httptest.NewRequest(http.MethodGet, "/admin/api/blog", nil)
+		request.Header.Set("Authorization", "Bearer "+token.Token)
+		return getApiUserName(request, authentication.ScopeSettings) == "admin"
+	}
+	if !authorized() {
+		t.Fatal("Expected the token to be accepted")
+	}
+	params := map[string]string{"id": strconv.FormatInt(token.Id, 10)}
+	if response := callAdminHandler(deleteApiTokenHandler, "author", http.MethodDelete, "/admin/api/token/"+params["id"], "", params); response.Code == http.StatusOK || !authorized() {
+		t.Errorf("Expected the token of another user to not be revoked, received %d", response.Code)
+	}
+	if response := callAdminHandler(deleteApiTokenHandler, "admin", http.MethodDelete, "/admin/api/token/"+params["id"], "", params); response.Code != http.StatusOK || authorized() {
+		t.Errorf("Expected the token to be revoked, received %d", response.Code)
+	}
+}
+
+func TestWriteJsonError(t *testing.T) {
+	tests := []struct {
+		err      error
+		code     int
+		filename string
+	}{
+		{&upload.Error{Code: "theme_invalid", Message: "No index.hbs", StatusCode: http.StatusBadRequest}, http.StatusBadRequest, "theme.zip"},
+		{&upload.Error{Code: "file_too_large", Message: "Too large", Filename: "inner.png", StatusCode: http.StatusRequestEntityTooLarge}, http.StatusRequestEntityTooLarge, "inner.png"},
+		{errors.New("disk full"), http.StatusInternalServerError, "theme.zip"},
+	}
+	for _, test := range tests {
+		response := httptest.NewRecorder()
+		writeJsonError(response, JsonUploadError{Error: test.err.Error(), Code: "internal_error", Filename: "theme.zip"}, test.err)
+		var jsonError JsonUploadError
+		if err := json.Unmarshal(response.Body.Bytes(), &jsonError); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != test.code || jsonError.Filename != test.filename {
+			t.Errorf("Expected %d with filename %q for %v, received %d with %q", test.code, test.filename, test.err, response.Code, jsonError.Filename)
+		}
+	}
+}
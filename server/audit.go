@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"journey/authentication"
+	"journey/database"
+	"journey/date"
+	"journey/structure"
+)
+
+// Actions that are recorded in the audit log
+const (
+	auditLogin               = "login"
+	auditLoginFailed         = "login_failed"
+	auditPostCreate          = "post_create"
+	auditPostUpdate          = "post_update"
+	auditPostDelete          = "post_delete"
+	auditPostPublish         = "post_publish"
+	auditPostUnpublish       = "post_unpublish"
+	auditSettingsUpdate      = "settings_update"
+	auditThemeChange         = "theme_change"
+	auditThemeInstall        = "theme_install"
+	auditThemeDelete         = "theme_delete"
+	auditThemeSettingsUpdate = "theme_settings_update"
+	auditUserCreate          = "user_create"
+	auditUserUpdate          = "user_update"
+	auditTokenCreate         = "token_create"
+	auditTokenDelete         = "token_delete"
+	auditImageUpload         = "image_upload"
+	auditImageDelete         = "image_delete"
+)
+
+type JsonAuditEntry struct {
+	Id         int64
+	UserId     int64
+	UserName   string
+	Action     string
+	ObjectType string
+	ObjectId   int64
+	ObjectName string
+	Ip         string
+	Changes    json.RawMessage
+	CreatedAt  *time.Time
+}
+
+// auditChange: old and new value of a changed field
+type auditChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// Function to add an entry to the audit log. Errors are only logged since the action itself already happened.
+func recordAudit(r *http.Request, userName string, action string, objectType string, objectId int64, objectName string, changes map[string]auditChange) {
+	currentTime := date.GetCurrentTime()
+	entry := structure.AuditEntry{UserName: []byte(userName), Action: action, ObjectType: objectType, ObjectId: objectId, ObjectName: []byte(objectName), Ip: remoteIp(r), CreatedAt: &currentTime}
+	if user, err := database.RetrieveUserByName([]byte(userName)); err == nil {
+		entry.UserId = user.Id
+	}
+	if len(changes) > 0 {
+		changesJson, err := json.Marshal(changes)
+		if err != nil {
+			log.Println("Couldn't encode audit log changes:", err)
+		}
+		entry.Changes = changesJson
+	}
+	err := database.InsertAuditEntry(&entry)
+	if err != nil {
+		log.Println("Couldn't write audit log entry for "+action+":", err)
+	}
+}
+
+// Function to compare two structs of the same type. Returns the old and new value of all fields that differ, except the ignored ones.
+func auditDiff(old interface{}, new interface{}, ignoredFields ...string) map[string]auditChange {
+	changes := make(map[string]auditChange)
+	oldValue := reflect.Indirect(reflect.ValueOf(old))
+	newValue := reflect.Indirect(reflect.ValueOf(new))
+	if oldValue.Type() != newValue.Type() || oldValue.Kind() != reflect.Struct {
+		return changes
+	}
+fields:
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		for _, ignoredField := range ignoredFields {
+			if name == ignoredField {
+				continue fields
+			}
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changes[name] = auditChange{Old: oldValue.Field(i).Interface(), New: newValue.Field(i).Interface()}
+		}
+	}
+	return changes
+}
+
+// Function to get the ip address of the client. Forwarding headers are ignored since they can be set by anyone.
+func remoteIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// API function to get the audit log by pages. Can be filtered by user id, action, object type and date (from/to as RFC 3339 or yyyy-mm-dd).
+func apiAuditLogHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	userName := getApiUserName(r, authentication.ScopeSettings)
+	if userName != "" {
+		user, err := database.RetrieveUserByName([]byte(userName))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user.Role != 1 && user.Role != 4 { // 1 = Administrator, 4 = Owner
+			http.Error(w, "You don't have permission to access the audit log.", http.StatusForbidden)
+			return
+		}
+		page, err := strconv.Atoi(params["number"])
+		if err != nil || page < 1 {
+			http.Error(w, "Not a valid api function!", http.StatusInternalServerError)
+			return
+		}
+		filter := structure.AuditFilter{Action: r.FormValue("action"), ObjectType: r.FormValue("type")}
+		if userId := r.FormValue("user"); userId != "" {
+			filter.UserId, err = strconv.ParseInt(userId, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid user id: "+userId, http.StatusBadRequest)
+				return
+			}
+		}
+		filter.From, err = parseAuditDate(r.FormValue("from"), false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.To, err = parseAuditDate(r.FormValue("to"), true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entriesPerPage := int64(50)
+		entries, err := database.RetrieveAuditEntries(&filter, entriesPerPage, (int64(page)-1)*entriesPerPage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonBytes, err := json.Marshal(auditEntriesToJson(entries))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonBytes)
+		return
+	} else {
+		http.Error(w, "Not logged in!", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Function to parse a date of the audit log filter. A day without time ends at midnight if it is used as end of the range.
+func parseAuditDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date: %s", value)
+		}
+		if endOfDay {
+			parsed = parsed.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	parsed = parsed.UTC()
+	return &parsed, nil
+}
+
+func auditEntriesToJson(entries []structure.AuditEntry) *[]JsonAuditEntry {
+	jsonEntries := make([]JsonAuditEntry, len(entries))
+	for index, entry := range entries {
+		jsonEntries[index] = JsonAuditEntry{Id: entry.Id, UserId: entry.UserId, UserName: string(entry.UserName), Action: entry.Action, ObjectType: entry.ObjectType, ObjectId: entry.ObjectId, ObjectName: string(entry.ObjectName), Ip: entry.Ip, CreatedAt: entry.CreatedAt}
+		if len(entry.Changes) > 0 {
+			jsonEntries[index].Changes = json.RawMessage(entry.Changes)
+		}
+	}
+	return &jsonEntries
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"journey/database"
+	"journey/structure"
+)
+
+func TestAuditLogHandler(t *testing.T) {
+	defer useTestDatabase(t)()
+	admin := createTestUser(t, "admin", 1)
+	createTestUser(t, "owner", 4)
+	editor := createTestUser(t, "editor", 2)
+	insert := func(user *structure.User, action string, objectType string, createdAt time.Time) {
+		entry := structure.AuditEntry{UserId: user.Id, UserName: user.Name, Action: action, ObjectType: objectType, CreatedAt: &createdAt}
+		if err := database.InsertAuditEntry(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	// More entries than fit on one page (50)
+	for i := 0; i < 51; i++ {
+		insert(admin, auditPostUpdate, "post", day.Add(time.Duration(i)*time.Minute))
+	}
+	insert(editor, auditLogin, "user", day.Add(24*time.Hour))
+	insert(admin, auditThemeChange, "theme", day.Add(48*time.Hour))
+	editorId := strconv.FormatInt(editor.Id, 10)
+	tests := []struct {
+		userName string
+		query    string
+		page     string
+		code     int
+		count    int
+		action   string // Action of the first (newest) entry
+	}{
+		{"admin", "", "1", http.StatusOK, 50, auditThemeChange},
+		{"admin", "", "2", http.StatusOK, 3, auditPostUpdate},
+		{"owner", "", "3", http.StatusOK, 0, ""},
+		{"owner", "?user=" + editorId, "1", http.StatusOK, 1, auditLogin},
+		{"admin", "?action=" + auditThemeChange, "1", http.StatusOK, 1, auditThemeChange},
+		{"admin", "?type=post", "2", http.StatusOK, 1, auditPostUpdate},
+		{"admin", "?type=post&action=" + auditLogin, "1", http.StatusOK, 0, ""},
+		{"admin", "?from=2020-01-02", "1", http.StatusOK, 2, auditThemeChange},
+		{"admin", "?from=2020-01-02&to=2020-01-02", "1", http.StatusOK, 1, auditLogin},
+		{"admin", "?to=2020-01-01T10:30:00Z", "1", http.StatusOK, 31, auditPostUpdate},
+		{"admin", "?from=2020-01-03T09:00:00%2B01:00", "1", http.StatusOK, 1, auditThemeChange},
+		// Only administrators and the owner can read the audit log
+		{"editor", "", "1", http.StatusForbidden, 0, ""},
+		{"", "", "1", http.StatusInternalServerError, 0, ""},
+		{"admin", "?user=editor", "1", http.StatusBadRequest, 0, ""},
+		{"admin", "?from=yesterday", "1", http.StatusBadRequest, 0, ""},
+		{"admin", "?to=2020-13-01", "1", http.StatusBadRequest, 0, ""},
+		{"admin", "", "0", http.StatusInternalServerError, 0, ""},
+	}
+	for _, test := range tests {
+		response := callAdminHandler(apiAuditLogHandler, test.userName, http.MethodGet, "/admin/api/audit/"+test.page+test.query, "", map[string]string{"number": test.page})
+		if response.Code != test.code {
+			t.Errorf("Expected %d for page %s%s by %q, received %d: %s", test.code, test.page, test.query, test.userName, response.Code, response.Body.String())
+			continue
+		}
+		if response.Code != http.StatusOK {
+			continue
+		}
+		var entries []JsonAuditEntry
+		if err := json.Unmarshal(response.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.count || (len(entries) != 0 && entries[0].Action != test.action) {
+			t.Errorf("Expected %d entries starting with %q for page %s%s, received %d", test.count, test.action, test.page, test.query, len(entries))
+		}
+	}
+}
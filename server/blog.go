@@ -1,14 +1,20 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/dimfeld/httptreemux"
+	"journey/authentication"
+	"journey/cache"
 	"journey/database"
 	"journey/filenames"
+	"journey/security"
 	"journey/structure/methods"
 	"journey/templates"
 )
@@ -17,7 +23,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request, params map[string]stri
 	number := params["number"]
 	if number == "" {
 		// Render index template (first page)
-		err := templates.ShowIndexTemplate(w, r, 1)
+		err := servePage(w, r, 1, func(writer io.Writer) error {
+			return templates.ShowIndexTemplate(writer, r, 1)
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -30,7 +38,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request, params map[string]stri
 		return
 	}
 	// Render index template
-	err = templates.ShowIndexTemplate(w, r, page)
+	err = servePage(w, r, page, func(writer io.Writer) error {
+		return templates.ShowIndexTemplate(writer, r, page)
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -44,7 +54,9 @@ func authorHandler(w http.ResponseWriter, r *http.Request, params map[string]str
 	number := params["number"]
 	if function == "" {
 		// Render author template (first page)
-		err := templates.ShowAuthorTemplate(w, r, slug, 1)
+		err := servePage(w, r, 1, func(writer io.Writer) error {
+			return templates.ShowAuthorTemplate(writer, r, slug, 1)
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -65,7 +77,9 @@ func authorHandler(w http.ResponseWriter, r *http.Request, params map[string]str
 		return
 	}
 	// Render author template
-	err = templates.ShowAuthorTemplate(w, r, slug, page)
+	err = servePage(w, r, page, func(writer io.Writer) error {
+		return templates.ShowAuthorTemplate(writer, r, slug, page)
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -79,7 +93,9 @@ func tagHandler(w http.ResponseWriter, r *http.Request, params map[string]string
 	number := params["number"]
 	if function == "" {
 		// Render tag template (first page)
-		err := templates.ShowTagTemplate(w, r, slug, 1)
+		err := servePage(w, r, 1, func(writer io.Writer) error {
+			return templates.ShowTagTemplate(writer, r, slug, 1)
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -100,7 +116,9 @@ func tagHandler(w http.ResponseWriter, r *http.Request, params map[string]string
 		return
 	}
 	// Render tag template
-	err = templates.ShowTagTemplate(w, r, slug, page)
+	err = servePage(w, r, page, func(writer io.Writer) error {
+		return templates.ShowTagTemplate(writer, r, slug, page)
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -124,7 +142,9 @@ func postHandler(w http.ResponseWriter, r *http.Request, params map[string]strin
 	}
 
 	// Render post template
-	err := templates.ShowPostTemplate(w, r, slug)
+	err := servePage(w, r, 1, func(writer io.Writer) error {
+		return templates.ShowPostTemplate(writer, r, slug)
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -132,6 +152,29 @@ func postHandler(w http.ResponseWriter, r *http.Request, params map[string]strin
 	return
 }
 
+// Function to serve a page of the blog from the page cache. Pages that aren't cached yet are rendered and added to the cache.
+// Logged in users always get a freshly rendered page. Pages that bypass the cache are written directly to the response.
+func servePage(w http.ResponseWriter, r *http.Request, page int, render func(io.Writer) error) error {
+	if !cache.Pages.Enabled() || authentication.GetBlogUserName(r) != "" {
+		return render(w)
+	}
+	key := cache.Key{Path: r.URL.Path, Page: page}
+	nonce := security.Nonce(r)
+	if body, ok := cache.Pages.Get(key, nonce); ok {
+		_, err := w.Write(body)
+		return err
+	}
+	generation := cache.Pages.Generation()
+	var buffer bytes.Buffer
+	err := render(&buffer)
+	if err != nil {
+		return err
+	}
+	cache.Pages.Add(key, buffer.Bytes(), nonce, generation)
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
+
 func postEditHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	slug := params["slug"]
 
@@ -159,6 +202,11 @@ func assetsHandler(w http.ResponseWriter, r *http.Request, params map[string]str
 }
 
 func imagesHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	// Svg files are sanitized on upload. Files that were added to the images folder by other means could still contain scripts,
+	// so they are never allowed to run anything when opened directly.
+	if strings.EqualFold(filepath.Ext(params["filepath"]), ".svg") {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
 	http.ServeFile(w, r, filepath.Join(filenames.ImagesFilepath, params["filepath"]))
 	return
 }
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"journey/authentication"
+	"journey/cache"
+	"journey/database"
+	"journey/filenames"
+	"journey/structure"
+	"journey/structure/methods"
+)
+
+// Function to use a new database in a temporary folder. The returned function restores the previous paths.
+func useTestDatabase(t *testing.T) func() {
+	t.Helper()
+	databasePath, err := ioutil.TempDir("", "journey-database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseFilepath, databaseFilename := filenames.DatabaseFilepath, filenames.DatabaseFilename
+	filenames.DatabaseFilepath, filenames.DatabaseFilename = databasePath, filepath.Join(databasePath, "journey.db")
+	restore := func() {
+		filenames.DatabaseFilepath, filenames.DatabaseFilename = databaseFilepath, databaseFilename
+		os.RemoveAll(databasePath)
+	}
+	if err := database.Initialize(); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return restore
+}
+
+func TestServePageBypassesCacheWhenLoggedIn(t *testing.T) {
+	pages := cache.Pages
+	cache.Pages = cache.New(1 << 20)
+	defer func() {
+		cache.Pages = pages
+	}()
+	// Log in like the admin area does and let a cookie jar decide which cookies the blog receives
+	login := httptest.NewRecorder()
+	authentication.SetSession("admin", login)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blogUrl, _ := url.Parse("http://example.com/hello/")
+	jar.SetCookies(&url.URL{Scheme: "http", Host: "example.com", Path: "/admin/login"}, login.Result().Cookies())
+	renders := 0
+	render := func(writer io.Writer) error {
+		renders++
+		_, err := io.WriteString(writer, "page")
+		return err
+	}
+	serve := func(loggedIn bool) string {
+		request := httptest.NewRequest(http.MethodGet, blogUrl.String(), nil)
+		if loggedIn {
+			for _, cookie := range jar.Cookies(blogUrl) {
+				request.AddCookie(cookie)
+			}
+		}
+		response := httptest.NewRecorder()
+		if err := servePage(response, request, 1, render); err != nil {
+			t.Fatal(err)
+		}
+		return response.Body.String()
+	}
+	for i := 0; i < 2; i++ {
+		if body := serve(true); body != "page" {
+			t.Fatalf("Unexpected body: %q", body)
+		}
+	}
+	if stats := cache.Pages.Stats(); renders != 2 || stats.Entries != 0 || stats.Hits != 0 {
+		t.Errorf("Expected logged in requests to skip the cache, rendered %d times, stats %+v", renders, stats)
+	}
+	// Visitors get the cached page
+	serve(false)
+	serve(false)
+	if stats := cache.Pages.Stats(); renders != 3 || stats.Hits != 1 {
+		t.Errorf("Expected the second visitor request to be served from the cache, rendered %d times, stats %+v", renders, stats)
+	}
+}
+
+func TestServePageWritesDirectlyWithoutCache(t *testing.T) {
+	pages := cache.Pages
+	cache.Pages = cache.New(-1)
+	defer func() {
+		cache.Pages = pages
+	}()
+	response := httptest.NewRecorder()
+	err := servePage(response, httptest.NewRequest(http.MethodGet, "/", nil), 1, func(writer io.Writer) error {
+		if writer != io.Writer(response) {
+			t.Error("Expected the page to be rendered to the response")
+		}
+		_, err := io.WriteString(writer, "page")
+		return err
+	})
+	if err != nil || response.Body.String() != "page" {
+		t.Errorf("Unexpected response: %q %v", response.Body.String(), err)
+	}
+}
+
+func TestServePageAfterUserUpdate(t *testing.T) {
+	defer useTestDatabase(t)()
+	pages := cache.Pages
+	cache.Pages = cache.New(1 << 20)
+	defer func() {
+		cache.Pages = pages
+	}()
+	user := structure.User{Name: []byte("Alice"), Slug: "alice", Email: []byte("alice@example.com"), Role: 1}
+	if err := methods.SaveUser(&user, "", 1); err != nil {
+		t.Fatal(err)
+	}
+	// The page shows the byline of the post
+	render := func(writer io.Writer) error {
+		author, err := database.RetrieveUser(user.Id)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(author.Name)
+		return err
+	}
+	serve := func() string {
+		response := httptest.NewRecorder()
+		if err := servePage(response, httptest.NewRequest(http.MethodGet, "/hello/", nil), 1, render); err != nil {
+			t.Fatal(err)
+		}
+		return response.Body.String()
+	}
+	if body := serve(); body != "Alice" {
+		t.Fatalf("Unexpected body: %q", body)
+	}
+	user.Name = []byte("Alice Smith")
+	if err := methods.UpdateUser(&user, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if body := serve(); body != "Alice Smith" {
+		t.Errorf("Expected the cached page to be purged after the user was updated, received %q", body)
+	}
+}
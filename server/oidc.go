@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"journey/authentication"
+	"journey/configuration"
+	"journey/database"
+	"journey/filenames"
+	"journey/slug"
+	"journey/structure"
+	"journey/structure/methods"
+)
+
+type JsonOidc struct {
+	Enabled bool
+}
+
+// The provider is created on first use so that Journey can still start if the identity provider is unreachable
+var oidc struct {
+	sync.Mutex
+	provider *authentication.OidcProvider
+}
+
+func getOidcProvider() (*authentication.OidcProvider, error) {
+	oidc.Lock()
+	defer oidc.Unlock()
+	if oidc.provider != nil {
+		return oidc.provider, nil
+	}
+	provider, err := authentication.NewOidcProvider(configuration.Config.Oidc.Issuer, configuration.Config.Oidc.ClientId, configuration.Config.Oidc.ClientSecret, adminUrl()+"/admin/oidc/callback/")
+	if err != nil {
+		return nil, err
+	}
+	oidc.provider = provider
+	return provider, nil
+}
+
+// Function to tell the login page if single sign-on is available
+func getOidcHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	jsonBytes, err := json.Marshal(JsonOidc{Enabled: configuration.Config.Oidc.Enabled})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(jsonBytes)
+	return
+}
+
+// Function to redirect the user to the identity provider
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !configuration.Config.Oidc.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	provider, err := getOidcProvider()
+	if err != nil {
+		log.Println("Couldn't reach OpenID Connect provider:", err)
+		http.Error(w, "Single sign-on is not available at the moment.", http.StatusBadGateway)
+		return
+	}
+	state, err := authentication.GenerateOidcSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nonce, err := authentication.GenerateOidcSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	codeVerifier, err := authentication.GenerateOidcSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = authentication.SetOidcState(state, nonce, codeVerifier, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, provider.AuthCodeUrl(state, nonce, codeVerifier), 302)
+	return
+}
+
+// Function to receive the authorization code from the identity provider and log in the matching user
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !configuration.Config.Oidc.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	state, nonce, codeVerifier := authentication.GetOidcState(r)
+	authentication.ClearOidcState(w)
+	if state == "" || r.FormValue("state") != state {
+		http.Error(w, "Invalid login state. Please try again.", http.StatusBadRequest)
+		return
+	}
+	if errorCode := r.FormValue("error"); errorCode != "" {
+		log.Println("Single sign-on failed:", errorCode, r.FormValue("error_description"))
+		http.Redirect(w, r, "/admin/login/", 302)
+		return
+	}
+	provider, err := getOidcProvider()
+	if err != nil {
+		log.Println("Couldn't reach OpenID Connect provider:", err)
+		http.Error(w, "Single sign-on is not available at the moment.", http.StatusBadGateway)
+		return
+	}
+	claims, err := provider.Exchange(r.FormValue("code"), codeVerifier, nonce)
+	if err != nil {
+		log.Println("Single sign-on failed:", err)
+		recordAudit(r, "", auditLoginFailed, "session", 0, "single sign-on", nil)
+		http.Error(w, "Single sign-on failed.", http.StatusUnauthorized)
+		return
+	}
+	user, err := database.RetrieveUserByEmail([]byte(claims.Email))
+	if err != nil {
+		if !configuration.Config.Oidc.AutoProvision {
+			log.Println("Single sign-on for unknown email address " + claims.Email)
+			recordAudit(r, claims.Email, auditLoginFailed, "session", 0, "single sign-on", nil)
+			http.Error(w, "There is no user with the email address "+claims.Email+".", http.StatusForbidden)
+			return
+		}
+		user, err = provisionOidcUser(claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, string(user.Name), auditUserCreate, "user", user.Id, string(user.Name), map[string]auditChange{"Email": {New: string(user.Email)}, "Role": {New: user.Role}})
+	}
+	logInUser(string(user.Name), w)
+	recordAudit(r, string(user.Name), auditLogin, "session", 0, "single sign-on", nil)
+	http.Redirect(w, r, "/admin/", 302)
+	return
+}
+
+// Function to create a new user for a single sign-on login
+func provisionOidcUser(claims *authentication.OidcClaims) (*structure.User, error) {
+	name := claims.PreferredUsername
+	if name == "" {
+		name = claims.Name
+	}
+	// User names must be unique since they identify the session
+	if _, err := database.RetrieveUserByName([]byte(name)); name == "" || err == nil {
+		name = claims.Email
+	}
+	// Nobody can log in with this password. It just fills the column.
+	randomPassword, err := authentication.GenerateOidcSecret()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := authentication.EncryptPassword(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+	role := configuration.Config.Oidc.DefaultRole
+	// The first user of the blog is the owner
+	if database.RetrieveUsersCount() == 0 {
+		role = 4
+	}
+	user := structure.User{Name: []byte(name), Slug: slug.Generate(name, "users"), Email: []byte(claims.Email), Image: []byte(filenames.DefaultUserImageFilename), Cover: []byte(filenames.DefaultUserCoverFilename), Role: role}
+	err = methods.SaveUser(&user, hashedPassword, 1)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Created user " + name + " for single sign-on login of " + claims.Email)
+	return database.RetrieveUserByEmail([]byte(claims.Email))
+}
+
+// Function to get the url the admin area is reachable with
+func adminUrl() string {
+	if configuration.Config.HttpsUsage == "None" {
+		return configuration.Config.Url
+	}
+	return configuration.Config.HttpsUrl
+}
@@ -0,0 +1,28 @@
+package structure
+
+import (
+	"time"
+)
+
+// AuditEntry: an entry of the audit log. Entries can only be added, never changed or deleted.
+type AuditEntry struct {
+	Id         int64
+	UserId     int64 // 0 if the action wasn't done by a known user (e.g. a failed login)
+	UserName   []byte
+	Action     string
+	ObjectType string
+	ObjectId   int64
+	ObjectName []byte
+	Ip         string
+	Changes    []byte // JSON object with the old and new value of every changed field
+	CreatedAt  *time.Time
+}
+
+// AuditFilter: restricts the audit log entries that are retrieved. Empty fields match everything.
+type AuditFilter struct {
+	UserId     int64
+	Action     string
+	ObjectType string
+	From       *time.Time
+	To         *time.Time
+}
@@ -16,5 +16,12 @@ type Blog struct {
 	PostCount       int64
 	PostsPerPage    int64
 	ActiveTheme     string
+	Language        string // Language tag used for the translations of the theme and for dates, e.g. en or de-CH
+	Timezone        string // IANA timezone that dates are shown in, e.g. Europe/Berlin
 	NavigationItems []Navigation
+	// Navigation that themes show with {{navigation type="secondary"}}, e.g. in the footer
+	SecondaryNavigationItems []Navigation
+	// Code that is inserted by {{ghost_head}} and {{ghost_foot}} on every page
+	CodeInjectionHead []byte
+	CodeInjectionFoot []byte
 }
@@ -0,0 +1,22 @@
+package structure
+
+// ContentQuery: a query for posts, tags or authors that was written in a theme (e.g. with the {{#get}} helper)
+type ContentQuery struct {
+	Filters      []ContentFilter // All filters have to match
+	Order        []ContentOrder
+	Limit        int64 // -1 = no limit
+	IncludeCount bool  // Count the published posts of each tag or author
+}
+
+// ContentFilter: compares a field with one or more values (e.g. tag:[news,tech] or id:-5)
+type ContentFilter struct {
+	Field    string
+	Operator string // "=", "!=", ">", ">=", "<" or "<=". Only "=" and "!=" can have more than one value.
+	Values   []string
+}
+
+// ContentOrder: a field to sort the results by
+type ContentOrder struct {
+	Field      string
+	Descending bool
+}
@@ -0,0 +1,15 @@
+package structure
+
+// DataContext: the object a block helper is executed with (e.g. the current post in {{#foreach posts}}).
+// Paths like title, ../title and @root.title are resolved against these.
+type DataContext struct {
+	Type       int // Same values as RequestData.CurrentHelperContext
+	Post       *Post
+	Tag        *Tag
+	Author     *User
+	Navigation *Navigation
+	// Hash parameters of a partial (e.g. size="small" in {{> card size="small"}}). Values are objects or []byte.
+	Parameters map[string]interface{}
+	// Content of a partial block ({{#> card}}...{{/card}}), inserted with {{> @partial-block}}
+	PartialBlock *Helper
+}
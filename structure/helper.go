@@ -1,13 +1,20 @@
 package structure
 
+import (
+	"io"
+)
+
 // Helpers are created during parsing of the theme (template files). Helpers should never be altered during template execution (Helpers are shared across all requests).
 type Helper struct {
-	Name       string
-	Arguments  []Helper
-	Unescaped  bool
-	Position   int
-	Block      []byte
-	Children   []Helper
-	Function   func(*Helper, *RequestData) []byte
-	BodyHelper *Helper
+	Name      string
+	Arguments []Helper
+	Unescaped bool
+	Position  int
+	Block     []byte
+	Children  []Helper
+	Function  func(*Helper, *RequestData) []byte
+	// Set for block helpers that can write their output straight to the page (e.g. foreach). Function returns the same output.
+	Write func(io.Writer, *Helper, *RequestData) error
+	// Set if the helper is called as (helper ...) inside of another helper. Block helpers then return their condition instead of the block.
+	Subexpression bool
 }
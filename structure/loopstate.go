@@ -0,0 +1,9 @@
+package structure
+
+// LoopState: the position of a foreach helper in the items it iterates. Used for data variables like @index and @first.
+type LoopState struct {
+	Index   int // Index of the current item in all items
+	First   int // Index of the first item that is rendered (set by from)
+	Last    int // Index of the last item that is rendered (set by to and limit)
+	Columns int // 0 if the columns option wasn't used
+}
@@ -2,6 +2,7 @@ package methods
 
 import (
 	"encoding/json"
+	"journey/cache"
 	"journey/configuration"
 	"journey/database"
 	"journey/date"
@@ -21,7 +22,11 @@ func UpdateBlog(b *structure.Blog, userId int64) error {
 	if err != nil {
 		return err
 	}
-	err = database.UpdateSettings(b.Title, b.Description, b.Logo, b.Cover, b.PostsPerPage, b.ActiveTheme, navigation, date.GetCurrentTime(), userId)
+	secondaryNavigation, err := json.Marshal(b.SecondaryNavigationItems)
+	if err != nil {
+		return err
+	}
+	err = database.UpdateSettings(b.Title, b.Description, b.Logo, b.Cover, b.PostsPerPage, b.ActiveTheme, b.Language, b.Timezone, navigation, secondaryNavigation, b.CodeInjectionHead, b.CodeInjectionFoot, date.GetCurrentTime(), userId)
 	if err != nil {
 		return err
 	}
@@ -61,9 +66,17 @@ func GenerateBlog() error {
 	blog.Url = []byte(configuration.Config.Url)
 	blog.AssetPath = assetPath
 	// Create navigation slugs
-	for index := range blog.NavigationItems {
-		blog.NavigationItems[index].Slug = slug.Generate(blog.NavigationItems[index].Label, "navigation")
-	}
+	generateNavigationSlugs(blog.NavigationItems)
+	generateNavigationSlugs(blog.SecondaryNavigationItems)
 	Blog = blog
+	// Cached pages could show old posts or settings
+	cache.Pages.Purge()
 	return nil
 }
+
+func generateNavigationSlugs(items []structure.Navigation) {
+	for index := range items {
+		items[index].Slug = slug.Generate(items[index].Label, "navigation")
+		generateNavigationSlugs(items[index].Children)
+	}
+}
@@ -25,10 +25,11 @@ func SavePost(p *structure.Post) error {
 		}
 	}
 	// Insert post
-	postId, err := database.InsertPost(p.Title, p.Slug, p.Markdown, p.Html, p.IsFeatured, p.IsPage, p.IsPublished, p.MetaDescription, p.Image, *p.Date, p.Author.Id)
+	postId, err := database.InsertPost(p.Title, p.Slug, p.Markdown, p.Html, p.IsFeatured, p.IsPage, p.IsPublished, p.MetaDescription, p.Image, p.CodeInjectionHead, p.CodeInjectionFoot, p.CustomTemplate, *p.Date, p.Author.Id)
 	if err != nil {
 		return err
 	}
+	p.Id = postId
 	// Insert postTags
 	for _, tagId := range tagIds {
 		err = database.InsertPostTag(postId, tagId)
@@ -62,7 +63,7 @@ func UpdatePost(p *structure.Post) error {
 		}
 	}
 	// Update post
-	err := database.UpdatePost(p.Id, p.Title, p.Slug, p.Markdown, p.Html, p.IsFeatured, p.IsPage, p.IsPublished, p.MetaDescription, p.Image, *p.Date, p.Author.Id)
+	err := database.UpdatePost(p.Id, p.Title, p.Slug, p.Markdown, p.Html, p.IsFeatured, p.IsPage, p.IsPublished, p.MetaDescription, p.Image, p.CodeInjectionHead, p.CodeInjectionFoot, p.CustomTemplate, *p.Date, p.Author.Id)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package methods
 
 import (
+	"journey/cache"
 	"journey/database"
 	"journey/date"
 	"journey/structure"
@@ -11,10 +12,13 @@ func SaveUser(u *structure.User, hashedPassword string, createdBy int64) error {
 	if err != nil {
 		return err
 	}
+	u.Id = userId
 	err = database.InsertRoleUser(u.Role, userId)
 	if err != nil {
 		return err
 	}
+	// Cached pages could show old authors
+	cache.Pages.Purge()
 	return nil
 }
 
@@ -23,5 +27,7 @@ func UpdateUser(u *structure.User, updatedById int64) error {
 	if err != nil {
 		return err
 	}
+	// Cached posts and author pages show the old name, bio, image or slug of the user
+	cache.Pages.Purge()
 	return nil
 }
@@ -1,8 +1,9 @@
 package structure
 
-// Navigation: an entry in the navigation menu
+// Navigation: an entry in the navigation menu. Children are shown as drop-down menu.
 type Navigation struct {
-	Label string `json:"label"`
-	Url   string `json:"url"`
-	Slug  string `json:"-"`
+	Label    string       `json:"label"`
+	Url      string       `json:"url"`
+	Slug     string       `json:"-"`
+	Children []Navigation `json:"children,omitempty"`
 }
@@ -19,4 +19,9 @@ type Post struct {
 	Author      *User
 	MetaDescription []byte
 	Image       []byte
+	// Code that is inserted by {{ghost_head}} and {{ghost_foot}} on the page of the post
+	CodeInjectionHead []byte
+	CodeInjectionFoot []byte
+	// Name of the custom-<name> template of the theme that the post is shown with, empty for the default template
+	CustomTemplate string
 }
@@ -12,8 +12,13 @@ type RequestData struct {
 	CurrentPostIndex       int
 	CurrentTagIndex        int
 	CurrentNavigationIndex int
-	CurrentHelperContext   int      // 0 = index, 1 = post, 2 = tag, 3 = author, 4 = navigation - used by block helpers
-	CurrentTemplate        int      // 0 = index, 1 = post, 2 = tag, 3 = author - never changes during execution. Used by funcs like body_classFunc etc to output the correct class
-	ContentForHelpers      []Helper // contentFor helpers that are attached to the currently rendering helper
-	CurrentPath            string   // path of the the url of this request
+	CurrentHelperContext   int           // 0 = index, 1 = post, 2 = tag, 3 = author, 4 = navigation - used by block helpers
+	CurrentTemplate        int           // 0 = index, 1 = post, 2 = tag, 3 = author - never changes during execution. Used by funcs like body_classFunc etc to output the correct class
+	ContentForHelpers      []Helper      // contentFor helpers that are attached to the currently rendering helper
+	CurrentPath            string        // path of the the url of this request
+	CspNonce               string        // Content-Security-Policy nonce of this request
+	Loops                  []LoopState   // foreach helpers that are currently executing. The last one is the innermost.
+	DataContexts           []DataContext // Objects of the block helpers that are executing. The first one is @root, the last one is this.
+	Body                   []byte        // Rendered template that {{body}} inserts into the layout it extends
+	NavigationItems        []Navigation  // Items that {{#foreach navigation}} iterates: the primary or secondary navigation or the children of an item
 }
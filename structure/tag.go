@@ -4,4 +4,6 @@ type Tag struct {
 	Id   int64
 	Name []byte
 	Slug string
+	// Only set if it was requested (e.g. with include="count.posts" in {{#get}})
+	PostCount int64
 }
@@ -0,0 +1,15 @@
+package structure
+
+import (
+	"time"
+)
+
+// Token: a personal access token that can be used instead of a session cookie to access the admin api
+type Token struct {
+	Id         int64
+	Name       []byte
+	Scopes     []string
+	UserId     int64
+	CreatedAt  *time.Time
+	LastUsedAt *time.Time
+}
@@ -11,4 +11,6 @@ type User struct {
 	Website  []byte
 	Location []byte
 	Role     int //1 = Administrator, 2 = Editor, 3 = Author, 4 = Owner
+	// Only set if it was requested (e.g. with include="count.posts" in {{#get}})
+	PostCount int64
 }
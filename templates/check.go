@@ -0,0 +1,314 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"journey/helpers"
+)
+
+// Levels of the problems found by CheckTheme. Only errors make the check fail.
+const (
+	ThemeCheckError   = "error"
+	ThemeCheckWarning = "warning"
+)
+
+// ThemeProblem: a problem found in a theme. Line and Column are 0 if the problem isn't at a position in a file.
+type ThemeProblem struct {
+	Level   string
+	Code    string
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// ThemeReport: the result of a theme check
+type ThemeReport struct {
+	Theme    string
+	Problems []ThemeProblem
+}
+
+// Helpers and data that Ghost themes can use but Journey doesn't provide
+var ghostFeatures = map[string]bool{
+	"@member":            true,
+	"@labs":              true,
+	"@config":            true,
+	"@price":             true,
+	"@setting":           true,
+	"authors":            true,
+	"reading_time":       true,
+	"link":               true,
+	"link_class":         true,
+	"concat":             true,
+	"img_url":            true,
+	"search":             true,
+	"social_url":         true,
+	"twitter_url":        true,
+	"facebook_url":       true,
+	"cancel_link":        true,
+	"comments":           true,
+	"comment_count":      true,
+	"price":              true,
+	"tiers":              true,
+	"total_members":      true,
+	"total_paid_members": true,
+	"recommendations":    true,
+	"readable_url":       true,
+	"split":              true,
+	"collection":         true,
+	"content_api_key":    true,
+	"content_api_url":    true,
+	"log":                true,
+	"raw":                true,
+}
+
+// Templates that Journey inserts by itself and provides built-in versions of
+var builtInPartials = []string{"navigation", "pagination"}
+
+type themeFile struct {
+	path  string // Relative to the theme folder
+	data  []byte
+	items []item
+}
+
+type themeChecker struct {
+	themePath    string
+	report       ThemeReport
+	templates    map[string]string // Template name -> file
+	partialFiles map[string]string // Path relative to the partials folder without extension -> file
+	partials     map[string]bool   // Names of the partials that are used
+	config       *ThemeConfig      // Config from the package.json
+}
+
+// Function to check a theme without loading it. Reports problems that would make the theme fail to load or render incompletely.
+func CheckTheme(themePath string) (*ThemeReport, error) {
+	info, err := os.Stat(themePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", themePath)
+	}
+	c := &themeChecker{themePath: themePath, report: ThemeReport{Theme: themePath, Problems: make([]ThemeProblem, 0)}, templates: make(map[string]string), partialFiles: make(map[string]string), partials: make(map[string]bool)}
+	config, warnings, err := loadThemeConfig(themePath)
+	if err != nil {
+		c.add(ThemeCheckWarning, "invalid-package-json", "package.json", 0, 0, err.Error())
+	}
+	for _, warning := range warnings {
+		c.add(ThemeCheckWarning, "invalid-custom-setting", "package.json", 0, 0, warning)
+	}
+	c.config = config
+	files := make([]themeFile, 0)
+	err = filepath.Walk(themePath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(filePath) != ".hbs" {
+			return nil
+		}
+		relativePath, err := filepath.Rel(themePath, filePath)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		name := helpers.GetFilenameWithoutExtension(filePath)
+		if partialName, ok := partialNameOf(themePath, filePath); ok {
+			c.partialFiles[partialName] = relativePath
+		} else if other, ok := c.templates[name]; ok {
+			c.add(ThemeCheckError, "duplicate-template", relativePath, 0, 0, fmt.Sprintf("Template name %q is already used by %s", name, other))
+		} else {
+			c.templates[name] = relativePath
+		}
+		// Only inspect the helpers of files that compile
+		_, _, err = parseTemplate(data, relativePath)
+		if compileError, ok := err.(*CompileError); ok {
+			c.add(ThemeCheckError, "compile-error", compileError.File, compileError.Line, compileError.Column, compileError.Message)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		items, err := lex(data)
+		if err != nil {
+			return err
+		}
+		files = append(files, themeFile{path: relativePath, data: data, items: items})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"index", "post"} {
+		if _, ok := c.templates[name]; !ok {
+			c.add(ThemeCheckError, "missing-template", name+".hbs", 0, 0, "Required template "+name+".hbs is missing")
+		}
+	}
+	for _, file := range files {
+		c.inspectFile(&file)
+	}
+	// Partials that are never inserted
+	for name, file := range c.partialFiles {
+		if !c.partials[name] && !isBuiltInPartial(name) {
+			c.add(ThemeCheckWarning, "unused-partial", file, 0, 0, fmt.Sprintf("Partial %q is never used", name))
+		}
+	}
+	sort.SliceStable(c.report.Problems, func(i, j int) bool {
+		a, b := c.report.Problems[i], c.report.Problems[j]
+		if a.File != b.File {
+			return a.File < b.File
+		} else if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return &c.report, nil
+}
+
+func (c *themeChecker) add(level string, code string, file string, line int, column int, message string) {
+	c.report.Problems = append(c.report.Problems, ThemeProblem{Level: level, Code: code, File: file, Line: line, Column: column, Message: message})
+}
+
+func (c *themeChecker) addAt(level string, code string, file *themeFile, offset int, message string) {
+	line, column := filePosition(file.data, offset)
+	c.add(level, code, file.path, line, column, message)
+}
+
+func (c *themeChecker) inspectFile(file *themeFile) {
+	items := file.items
+	for index := 0; index+1 < len(items); index++ {
+		current := items[index]
+		next := items[index+1]
+		switch current.typ {
+		case itemOpen, itemOpenUnescaped, itemOpenBlock, itemOpenInverse, itemOpenParen:
+			if next.typ != itemId {
+				continue
+			}
+			// The helper of {{else if ...}} comes after the else
+			if next.value == "else" {
+				if index+2 >= len(items) || items[index+2].typ != itemId {
+					continue
+				}
+				index++
+				next = items[index+1]
+			}
+			c.checkHelper(file, next)
+			if next.value == "asset" && index+2 < len(items) {
+				c.checkAsset(file, items[index+2])
+			}
+		case itemOpenPartial, itemOpenPartialBlock:
+			if (next.typ != itemId && next.typ != itemString) || next.value == partialBlockName {
+				// Dynamic partial names can't be checked
+				continue
+			}
+			c.partials[next.value] = true
+			// Partial blocks render their content if the partial doesn't exist
+			if current.typ == itemOpenPartialBlock {
+				continue
+			}
+			_, isPartial := c.partialFiles[next.value]
+			_, isTemplate := c.templates[next.value]
+			if !isPartial && !isTemplate && !isBuiltInPartial(next.value) {
+				c.addAt(ThemeCheckError, "missing-partial", file, next.offset, fmt.Sprintf("Partial %q doesn't exist", next.value))
+			}
+		case itemOpenExtend:
+			if next.typ != itemId && next.typ != itemString {
+				continue
+			}
+			if _, ok := c.templates[next.value]; !ok {
+				c.addAt(ThemeCheckError, "missing-layout", file, next.offset, fmt.Sprintf("Layout %q doesn't exist", next.value))
+			}
+		}
+	}
+}
+
+func (c *themeChecker) checkHelper(file *themeFile, name item) {
+	if strings.HasPrefix(name.value, "@custom.") {
+		if _, ok := c.config.Custom[strings.TrimPrefix(name.value, "@custom.")]; !ok {
+			c.addAt(ThemeCheckWarning, "unknown-custom-setting", file, name.offset, fmt.Sprintf("%q isn't declared in the custom settings of package.json", name.value))
+		}
+		return
+	}
+	if _, ok := helperFuctions[name.value]; ok || isKnownPath(name.value) {
+		return
+	}
+	root := strings.SplitN(strings.TrimLeft(name.value, "./"), ".", 2)[0]
+	if ghostFeatures[root] {
+		c.addAt(ThemeCheckWarning, "unsupported-feature", file, name.offset, fmt.Sprintf("%q is a Ghost feature that Journey doesn't support", name.value))
+		return
+	}
+	// Only a warning since the helper may be defined by a plugin
+	c.addAt(ThemeCheckWarning, "unknown-helper", file, name.offset, fmt.Sprintf("Unknown helper %q will render nothing unless a plugin defines it", name.value))
+}
+
+func (c *themeChecker) checkAsset(file *themeFile, argument item) {
+	if argument.typ != itemString && argument.typ != itemId {
+		return
+	}
+	assetPath := filepath.Join(c.themePath, "assets", filepath.FromSlash(argument.value))
+	if _, err := os.Stat(assetPath); err != nil {
+		c.addAt(ThemeCheckError, "missing-asset", file, argument.offset, fmt.Sprintf("Asset %q doesn't exist in the assets folder", argument.value))
+	}
+}
+
+func isBuiltInPartial(name string) bool {
+	for _, builtInPartial := range builtInPartials {
+		if name == builtInPartial {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check if the theme has problems that make the check fail
+func (r *ThemeReport) HasErrors() bool {
+	for _, problem := range r.Problems {
+		if problem.Level == ThemeCheckError {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to write the report as human-readable text
+func (r *ThemeReport) WriteText(w io.Writer) error {
+	errorCount := 0
+	_, err := fmt.Fprintf(w, "Checking theme %s\n", r.Theme)
+	if err != nil {
+		return err
+	}
+	for _, problem := range r.Problems {
+		if problem.Level == ThemeCheckError {
+			errorCount++
+		}
+		position := problem.File
+		if problem.Line != 0 {
+			position = fmt.Sprintf("%s:%d:%d", problem.File, problem.Line, problem.Column)
+		}
+		_, err = fmt.Fprintf(w, "%s: %s: %s (%s)\n", position, problem.Level, problem.Message, problem.Code)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errorCount, len(r.Problems)-errorCount)
+	return err
+}
+
+// Function to write the report as json
+func (r *ThemeReport) WriteJson(w io.Writer) error {
+	jsonBytes, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(jsonBytes, '\n'))
+	return err
+}
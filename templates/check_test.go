@@ -0,0 +1,83 @@
+package templates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Function to write the files of a theme to a temporary folder. The returned function removes the theme.
+func writeTestTheme(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	themePath, err := ioutil.TempDir("", "journey-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(themePath, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			os.RemoveAll(themePath)
+			t.Fatal(err)
+		}
+	}
+	return themePath, func() {
+		os.RemoveAll(themePath)
+	}
+}
+
+func TestCheckTheme(t *testing.T) {
+	themePath, remove := writeTestTheme(t, map[string]string{
+		"default.hbs":            "<link href=\"{{asset \"css/screen.css\"}}\">{{asset \"js/missing.js\"}}{{{body}}}",
+		"index.hbs":              "{{!< default}}{{#foreach posts}}{{> \"post-card\"}}{{/foreach}}{{@labs.title}}{{@site.title}}",
+		"partials/post-card.hbs": "{{title}}{{reading_tme}}{{> missing}}",
+		"partials/unused.hbs":    "{{title}}",
+		"tag.hbs":                "{{#if tag}}\n{{/unless}}",
+		"assets/css/screen.css":  "",
+	})
+	defer remove()
+	report, err := CheckTheme(themePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ThemeProblem{
+		{ThemeCheckError, "missing-asset", "default.hbs", 1, 49, "Asset \"js/missing.js\" doesn't exist in the assets folder"},
+		{ThemeCheckWarning, "unsupported-feature", "index.hbs", 1, 64, "\"@labs.title\" is a Ghost feature that Journey doesn't support"},
+		{ThemeCheckWarning, "unknown-helper", "partials/post-card.hbs", 1, 12, "Unknown helper \"reading_tme\" will render nothing unless a plugin defines it"},
+		{ThemeCheckError, "missing-partial", "partials/post-card.hbs", 1, 29, "Partial \"missing\" doesn't exist"},
+		{ThemeCheckWarning, "unused-partial", "partials/unused.hbs", 0, 0, "Partial \"unused\" is never used"},
+		{ThemeCheckError, "missing-template", "post.hbs", 0, 0, "Required template post.hbs is missing"},
+		{ThemeCheckError, "compile-error", "tag.hbs", 2, 1, "{{/unless}} doesn't match {{#if}} opened at line 1, column 1"},
+	}
+	if len(report.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, received %+v", len(expected), report.Problems)
+	}
+	for index, problem := range report.Problems {
+		if problem != expected[index] {
+			t.Errorf("Expected %+v, received %+v", expected[index], problem)
+		}
+	}
+	if !report.HasErrors() {
+		t.Error("Expected the report to have errors")
+	}
+}
+
+func TestCheckThemePluginHelper(t *testing.T) {
+	// Helpers that only a plugin defines don't fail the check
+	themePath, remove := writeTestTheme(t, map[string]string{
+		"index.hbs": "{{#foreach posts}}{{title}} {{word_count}}{{/foreach}}",
+		"post.hbs":  "{{#post}}{{title}}{{/post}}",
+	})
+	defer remove()
+	report, err := CheckTheme(themePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HasErrors() || len(report.Problems) != 1 || report.Problems[0].Code != "unknown-helper" {
+		t.Errorf("Expected only a warning for the unknown helper, received %+v", report.Problems)
+	}
+}
@@ -3,28 +3,40 @@ package templates
 import (
 	"bytes"
 	"errors"
+	"io"
 	"journey/database"
 	"journey/filenames"
 	"journey/helpers"
 	"journey/plugins"
+	"journey/security"
 	"journey/structure"
 	"journey/structure/methods"
 	"net/http"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
 type Templates struct {
 	sync.RWMutex
-	m map[string]*structure.Helper
+	m        map[string]*structure.Helper
+	partials map[string]*structure.Helper // Path relative to the partials folder without extension -> partial
+	// Translations of the theme for the language of the blog
+	translations map[string]string
+	theme        string                 // Name of the compiled theme
+	config       *ThemeConfig           // Config from the package.json of the theme
+	custom       map[string]interface{} // Values of the custom settings of the theme (@custom)
 }
 
-func newTemplates() *Templates { return &Templates{m: make(map[string]*structure.Helper)} }
+func newTemplates() *Templates {
+	return &Templates{m: make(map[string]*structure.Helper), partials: make(map[string]*structure.Helper), translations: make(map[string]string), config: newThemeConfig(), custom: make(map[string]interface{})}
+}
 
 // Global compiled templates - thread safe and accessible by all requests
 var compiledTemplates = newTemplates()
 
-func ShowPostTemplate(writer http.ResponseWriter, r *http.Request, slug string) error {
+func ShowPostTemplate(writer io.Writer, r *http.Request, slug string) error {
 	// Read lock templates and global blog
 	compiledTemplates.RLock()
 	defer compiledTemplates.RUnlock()
@@ -36,21 +48,9 @@ func ShowPostTemplate(writer http.ResponseWriter, r *http.Request, slug string)
 	} else if !post.IsPublished { // Make sure the post is published before rendering it
 		return errors.New("post not published")
 	}
-	requestData := structure.RequestData{Posts: make([]structure.Post, 1), Blog: methods.Blog, CurrentTemplate: 1, CurrentPath: r.URL.Path} // CurrentTemplate = post
+	requestData := structure.RequestData{Posts: make([]structure.Post, 1), Blog: methods.Blog, CurrentTemplate: 1, CurrentPath: r.URL.Path, CspNonce: security.Nonce(r)} // CurrentTemplate = post
 	requestData.Posts[0] = *post
-	// Check if there's a custom page template available for this slug
-	if template, ok := compiledTemplates.m["page-"+slug]; ok {
-		_, err = writer.Write(executeHelper(template, &requestData, 1)) // context = post
-		return err
-	}
-	// If the post is a page and the page template is available, use the page template
-	if post.IsPage {
-		if template, ok := compiledTemplates.m["page"]; ok {
-			_, err = writer.Write(executeHelper(template, &requestData, 1)) // context = post
-			return err
-		}
-	}
-	_, err = writer.Write(executeHelper(compiledTemplates.m["post"], &requestData, 1)) // context = post
+	err = executeTemplate(writer, postTemplate(post), &requestData, 1) // context = post
 	if requestData.PluginVMs != nil {
 		// Put the lua state map back into the pool
 		plugins.LuaPool.Put(requestData.PluginVMs)
@@ -58,7 +58,7 @@ func ShowPostTemplate(writer http.ResponseWriter, r *http.Request, slug string)
 	return err
 }
 
-func ShowAuthorTemplate(writer http.ResponseWriter, r *http.Request, slug string, page int) error {
+func ShowAuthorTemplate(writer io.Writer, r *http.Request, slug string, page int) error {
 	// Read lock templates and global blog
 	compiledTemplates.RLock()
 	defer compiledTemplates.RUnlock()
@@ -72,16 +72,13 @@ func ShowAuthorTemplate(writer http.ResponseWriter, r *http.Request, slug string
 	if err != nil {
 		return err
 	}
-	posts, err := database.RetrievePostsByUser(author.Id, methods.Blog.PostsPerPage, methods.Blog.PostsPerPage*postIndex)
+	posts, err := database.RetrievePostsByUser(author.Id, postsPerPage(methods.Blog), postsPerPage(methods.Blog)*postIndex)
 	if err != nil {
 		return err
 	}
-	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTemplate: 3, CurrentPath: r.URL.Path} // CurrentTemplate = author
-	if template, ok := compiledTemplates.m["author"]; ok {
-		_, err = writer.Write(executeHelper(template, &requestData, 0)) // context = index
-	} else {
-		_, err = writer.Write(executeHelper(compiledTemplates.m["index"], &requestData, 0)) // context = index
-	}
+	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTemplate: 3, CurrentPath: r.URL.Path, CspNonce: security.Nonce(r)} // CurrentTemplate = author
+	template := lookupTemplate("author-"+slug, "author", "index")
+	err = executeTemplate(writer, template, &requestData, 0) // context = index
 	if requestData.PluginVMs != nil {
 		// Put the lua state map back into the pool
 		plugins.LuaPool.Put(requestData.PluginVMs)
@@ -89,7 +86,7 @@ func ShowAuthorTemplate(writer http.ResponseWriter, r *http.Request, slug string
 	return err
 }
 
-func ShowTagTemplate(writer http.ResponseWriter, r *http.Request, slug string, page int) error {
+func ShowTagTemplate(writer io.Writer, r *http.Request, slug string, page int) error {
 	// Read lock templates and global blog
 	compiledTemplates.RLock()
 	defer compiledTemplates.RUnlock()
@@ -103,16 +100,13 @@ func ShowTagTemplate(writer http.ResponseWriter, r *http.Request, slug string, p
 	if err != nil {
 		return err
 	}
-	posts, err := database.RetrievePostsByTag(tag.Id, methods.Blog.PostsPerPage, methods.Blog.PostsPerPage*postIndex)
+	posts, err := database.RetrievePostsByTag(tag.Id, postsPerPage(methods.Blog), postsPerPage(methods.Blog)*postIndex)
 	if err != nil {
 		return err
 	}
-	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTag: tag, CurrentTemplate: 2, CurrentPath: r.URL.Path} // CurrentTemplate = tag
-	if template, ok := compiledTemplates.m["tag"]; ok {
-		_, err = writer.Write(executeHelper(template, &requestData, 0)) // context = index
-	} else {
-		_, err = writer.Write(executeHelper(compiledTemplates.m["index"], &requestData, 0)) // context = index
-	}
+	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTag: tag, CurrentTemplate: 2, CurrentPath: r.URL.Path, CspNonce: security.Nonce(r)} // CurrentTemplate = tag
+	template := lookupTemplate("tag-"+slug, "tag", "index")
+	err = executeTemplate(writer, template, &requestData, 0) // context = index
 	if requestData.PluginVMs != nil {
 		// Put the lua state map back into the pool
 		plugins.LuaPool.Put(requestData.PluginVMs)
@@ -120,7 +114,7 @@ func ShowTagTemplate(writer http.ResponseWriter, r *http.Request, slug string, p
 	return err
 }
 
-func ShowIndexTemplate(w http.ResponseWriter, r *http.Request, page int) error {
+func ShowIndexTemplate(w io.Writer, r *http.Request, page int) error {
 	// Read lock templates and global blog
 	compiledTemplates.RLock()
 	defer compiledTemplates.RUnlock()
@@ -130,12 +124,12 @@ func ShowIndexTemplate(w http.ResponseWriter, r *http.Request, page int) error {
 	if postIndex < 0 {
 		postIndex = 0
 	}
-	posts, err := database.RetrievePostsForIndex(methods.Blog.PostsPerPage, methods.Blog.PostsPerPage*postIndex)
+	posts, err := database.RetrievePostsForIndex(postsPerPage(methods.Blog), postsPerPage(methods.Blog)*postIndex)
 	if err != nil {
 		return err
 	}
-	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTemplate: 0, CurrentPath: r.URL.Path} // CurrentTemplate = index
-	_, err = w.Write(executeHelper(compiledTemplates.m["index"], &requestData, 0))                                                              // context = index
+	requestData := structure.RequestData{Posts: posts, Blog: methods.Blog, CurrentIndexPage: page, CurrentTemplate: 0, CurrentPath: r.URL.Path, CspNonce: security.Nonce(r)} // CurrentTemplate = index
+	err = executeTemplate(w, compiledTemplates.m["index"], &requestData, 0)                                                                                                  // context = index
 	if requestData.PluginVMs != nil {
 		// Put the lua state map back into the pool
 		plugins.LuaPool.Put(requestData.PluginVMs)
@@ -143,48 +137,178 @@ func ShowIndexTemplate(w http.ResponseWriter, r *http.Request, page int) error {
 	return err
 }
 
+// Function to find the template of a post in the order Ghost uses: the custom template selected for the post,
+// post-<slug> (page-<slug> and page for pages) and post. page-<slug> is also used for posts to keep older themes working.
+func postTemplate(post *structure.Post) *structure.Helper {
+	names := make([]string, 0, 5)
+	if isCustomTemplate(post.CustomTemplate) {
+		names = append(names, post.CustomTemplate)
+	}
+	if post.IsPage {
+		names = append(names, "page-"+post.Slug, "page")
+	} else {
+		names = append(names, "post-"+post.Slug, "page-"+post.Slug)
+	}
+	return lookupTemplate(append(names, "post")...)
+}
+
+// Function to get the first of the templates that the theme has. Falls back to the index template.
+func lookupTemplate(names ...string) *structure.Helper {
+	for _, name := range names {
+		if template, ok := compiledTemplates.m[name]; ok {
+			return template
+		}
+	}
+	return compiledTemplates.m["index"]
+}
+
+func isCustomTemplate(name string) bool {
+	return strings.HasPrefix(name, "custom-") && len(name) > len("custom-")
+}
+
+// Function to get the custom-<name> templates of the active theme that posts can be shown with
+func GetCustomTemplates() []string {
+	compiledTemplates.RLock()
+	defer compiledTemplates.RUnlock()
+	names := make([]string, 0)
+	for name := range compiledTemplates.m {
+		if isCustomTemplate(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func GetAllThemes() []string {
 	themes := make([]string, 0)
 	files, _ := filepath.Glob(filepath.Join(filenames.ThemesFilepath, "*"))
 	for _, file := range files {
-		if helpers.IsDirectory(file) {
+		// Folders starting with a dot are themes that are being installed
+		if helpers.IsDirectory(file) && !strings.HasPrefix(filepath.Base(file), ".") {
 			themes = append(themes, filepath.Base(file))
 		}
 	}
 	return themes
 }
 
+// Buffers for the output of helpers - reused across all requests
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+func putBuffer(buffer *bytes.Buffer) {
+	// Don't keep the buffers of unusually large pages around
+	if buffer.Cap() > 1<<20 {
+		return
+	}
+	bufferPool.Put(buffer)
+}
+
+// Function to execute a helper and return its output. Used by helpers that need the output of their block.
 func executeHelper(helper *structure.Helper, values *structure.RequestData, context int) []byte {
+	return bufferOutput(func(writer io.Writer) error {
+		return executeTemplate(writer, helper, values, context)
+	})
+}
+
+// Function to get the output of a helper that writes to a writer as []byte
+func bufferOutput(write func(io.Writer) error) []byte {
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	_ = write(buffer) // Writing to a buffer doesn't fail
+	return append([]byte(nil), buffer.Bytes()...)
+}
+
+// Function to execute a helper and write its output to the writer. The literal parts of the block and the output of the
+// children are written in order, so the output doesn't need to be held in memory as a whole.
+func executeTemplate(writer io.Writer, helper *structure.Helper, values *structure.RequestData, context int) error {
 	// Set context and set it back to the old value once fuction returns
 	defer setCurrentHelperContext(values, values.CurrentHelperContext)
 	values.CurrentHelperContext = context
+	// Push the object of the block so that paths like this and ../title can be resolved
+	dataContext := makeDataContext(values, context)
+	if length := len(values.DataContexts); length == 0 || !sameDataContext(values.DataContexts[length-1], dataContext) {
+		values.DataContexts = append(values.DataContexts, dataContext)
+		defer popDataContext(values, length)
+	}
 
-	block := helper.Block
-	indexTracker := 0
-	extended := false
-	var extendHelper *structure.Helper
-	for index, child := range helper.Children {
-		// Handle extend helper
-		if index == 0 && child.Name == "!<" {
-			extended = true
-			extendHelper = compiledTemplates.m[string(child.Function(&child, values))]
-		} else {
-			var buffer bytes.Buffer
-			toAdd := child.Function(&child, values)
-			buffer.Write(block[:child.Position+indexTracker])
-			buffer.Write(toAdd)
-			buffer.Write(block[child.Position+indexTracker:])
-			block = buffer.Bytes()
-			indexTracker += len(toAdd)
+	// Handle extend helper
+	if len(helper.Children) != 0 && helper.Children[0].Name == "!<" {
+		extendHelper, ok := compiledTemplates.m[string(helper.Children[0].Function(&helper.Children[0], values))]
+		if ok {
+			// Render the template first, the layout inserts it with {{body}}. The template can't be written to the page
+			// once the layout reaches {{body}}, since its contentFor helpers fill the blocks of the layout (e.g. in the head).
+			buffer := getBuffer()
+			defer putBuffer(buffer)
+			err := writeBlock(buffer, helper.Block, helper.Children[1:], values)
+			if err != nil {
+				return err
+			}
+			defer setBody(values, values.Body)
+			values.Body = buffer.Bytes()
+			return executeTemplate(writer, extendHelper, values, values.CurrentHelperContext) // TODO: not sure if context = values.CurrentHelperContext is right.
 		}
 	}
-	if extended {
-		extendHelper.BodyHelper.Block = block
-		return executeHelper(extendHelper, values, values.CurrentHelperContext) // TODO: not sure if context = values.CurrentHelperContext is right.
+	return writeBlock(writer, helper.Block, helper.Children, values)
+}
+
+func writeBlock(writer io.Writer, block []byte, children []structure.Helper, values *structure.RequestData) error {
+	position := 0
+	for index := range children {
+		child := &children[index]
+		if child.Name == "!<" {
+			continue
+		}
+		_, err := writer.Write(block[position:child.Position])
+		if err != nil {
+			return err
+		}
+		position = child.Position
+		// Hash parameters of a partial take precedence over helpers of the same name
+		var output []byte
+		if value, ok := partialParameter(values, child.Name); ok && len(child.Arguments) == 0 {
+			output = evaluateEscape(pathValue(value), child.Unescaped)
+		} else if child.Write != nil {
+			// Block helpers like foreach write their output without holding it in memory
+			err = child.Write(writer, child, values)
+			if err != nil {
+				return err
+			}
+			continue
+		} else {
+			output = child.Function(child, values)
+		}
+		// Conditions (e.g. objects like primary_author) evaluate to []byte{1}, which is only used for if and unless
+		if isCondition(output) {
+			continue
+		}
+		_, err = writer.Write(output)
+		if err != nil {
+			return err
+		}
 	}
-	return block
+	_, err := writer.Write(block[position:])
+	return err
+}
+
+// Function to check if the output of a helper is the marker of a true condition instead of text
+func isCondition(output []byte) bool {
+	return len(output) == 1 && output[0] == 1
 }
 
 func setCurrentHelperContext(values *structure.RequestData, context int) {
 	values.CurrentHelperContext = context
 }
+
+func setBody(values *structure.RequestData, body []byte) {
+	values.Body = body
+}
+
+func popDataContext(values *structure.RequestData, length int) {
+	values.DataContexts = values.DataContexts[:length]
+}
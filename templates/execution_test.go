@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"journey/filenames"
+	"journey/structure"
+	"journey/structure/methods"
+)
+
+// Theme to benchmark against, modeled after the default theme (promenade)
+var benchmarkThemePath = filepath.Join("testdata", "themes", "benchmark")
+
+func benchmarkRequestData(postCount int) *structure.RequestData {
+	now := time.Date(2016, time.March, 2, 14, 5, 9, 0, time.UTC)
+	author := &structure.User{Name: []byte("Jane"), Slug: "jane"}
+	tags := []structure.Tag{{Name: []byte("News"), Slug: "news"}, {Name: []byte("Go"), Slug: "go"}}
+	html := []byte(strings.Repeat("<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore.</p>\n", 40))
+	posts := make([]structure.Post, postCount)
+	for index := range posts {
+		posts[index] = structure.Post{Id: int64(index + 1), Title: []byte("A post title"), Slug: "a-post-title", Html: html, Date: &now, Tags: tags, Author: author, IsPublished: true}
+	}
+	blog := &structure.Blog{Url: []byte("https://example.com"), Title: []byte("Blog"), Description: []byte("About things"), AssetPath: []byte("/assets/"), PostCount: 100, PostsPerPage: int64(postCount), NavigationItems: []structure.Navigation{{Label: "Home", Url: "/", Slug: "home"}}}
+	return &structure.RequestData{Posts: posts, Blog: blog, CurrentIndexPage: 1, CurrentPath: "/"}
+}
+
+func BenchmarkThemeIndex(b *testing.B) {
+	benchmarkTheme(b, "index", 0)
+}
+
+func BenchmarkThemePost(b *testing.B) {
+	benchmarkTheme(b, "post", 1)
+}
+
+func benchmarkTheme(b *testing.B, name string, context int) {
+	defer replaceCompiledTemplates()()
+	blog := methods.Blog
+	hbsPath := filenames.HbsFilepath
+	defer func() {
+		methods.Blog = blog
+		filenames.HbsFilepath = hbsPath
+	}()
+	// The theme uses the built-in pagination and navigation partials
+	filenames.HbsFilepath = filepath.Join("..", "built-in", "hbs")
+	values := benchmarkRequestData(10)
+	methods.Blog = values.Blog
+	compiled, err := compileTheme(benchmarkThemePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	compiledTemplates = compiled
+	if name == "post" {
+		values.Posts = values.Posts[:1]
+		values.CurrentTemplate = 1
+	}
+	benchmarkExecution(b, compiledTemplates.m[name], values, context)
+}
+
+// Long template with many helpers, independent of the themes that are installed. The helpers are at the top level
+// of the page, where the previous execution copied the rest of the page for every helper.
+func BenchmarkLongTemplate(b *testing.B) {
+	var template bytes.Buffer
+	for i := 0; i < 200; i++ {
+		template.WriteString("<article><h2><a href=\"{{@blog.url}}\">{{@blog.title}}</a></h2>{{#foreach posts limit=\"1\"}}{{title}}{{/foreach}}<footer>{{@blog.description}}</footer></article>\n")
+	}
+	helper, err := compileTemplate(template.Bytes(), "long", "long.hbs")
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkExecution(b, helper, benchmarkRequestData(10), 0)
+}
+
+// Function to benchmark the execution of a template against the previous execution (see spliceTemplate)
+func benchmarkExecution(b *testing.B, template *structure.Helper, values *structure.RequestData, context int) {
+	execute := func() ([]byte, error) {
+		var buffer bytes.Buffer
+		requestData := *values
+		err := executeTemplate(&buffer, template, &requestData, context)
+		return buffer.Bytes(), err
+	}
+	output, err := execute()
+	if err != nil {
+		b.Fatal(err)
+	}
+	requestData := *values
+	if spliced := spliceTemplate(template, &requestData, context); !bytes.Equal(output, spliced) {
+		b.Fatalf("Expected the same output from both executions, received %q and %q", output, spliced)
+	}
+	b.Run("splicing", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			requestData := *values
+			spliceTemplate(template, &requestData, context)
+		}
+	})
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			requestData := *values
+			if err := executeTemplate(ioutil.Discard, template, &requestData, context); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Function to execute a template the way it was executed before the output was streamed: the output of every helper
+// of the page is spliced into the block, which copies the rest of the page each time. Block helpers return their
+// output as []byte like they did then.
+func spliceTemplate(helper *structure.Helper, values *structure.RequestData, context int) []byte {
+	defer setCurrentHelperContext(values, values.CurrentHelperContext)
+	values.CurrentHelperContext = context
+	dataContext := makeDataContext(values, context)
+	if length := len(values.DataContexts); length == 0 || !sameDataContext(values.DataContexts[length-1], dataContext) {
+		values.DataContexts = append(values.DataContexts, dataContext)
+		defer popDataContext(values, length)
+	}
+	if len(helper.Children) != 0 && helper.Children[0].Name == "!<" {
+		if extendHelper, ok := compiledTemplates.m[string(helper.Children[0].Function(&helper.Children[0], values))]; ok {
+			defer setBody(values, values.Body)
+			values.Body = splice(helper.Block, helper.Children[1:], values)
+			return spliceTemplate(extendHelper, values, values.CurrentHelperContext)
+		}
+	}
+	return splice(helper.Block, helper.Children, values)
+}
+
+func splice(block []byte, children []structure.Helper, values *structure.RequestData) []byte {
+	indexTracker := 0
+	for index := range children {
+		child := &children[index]
+		if child.Name == "!<" {
+			continue
+		}
+		var buffer bytes.Buffer
+		var toAdd []byte
+		if value, ok := partialParameter(values, child.Name); ok && len(child.Arguments) == 0 {
+			toAdd = evaluateEscape(pathValue(value), child.Unescaped)
+		} else {
+			toAdd = child.Function(child, values)
+		}
+		buffer.Write(block[:child.Position+indexTracker])
+		buffer.Write(toAdd)
+		buffer.Write(block[child.Position+indexTracker:])
+		block = buffer.Bytes()
+		indexTracker += len(toAdd)
+	}
+	return block
+}
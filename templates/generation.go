@@ -1,10 +1,11 @@
 package templates
 
 import (
-	"bytes"
 	"errors"
 	"io/ioutil"
+	"journey/cache"
 	"journey/database"
+	"journey/date"
 	"journey/filenames"
 	"journey/flags"
 	"journey/helpers"
@@ -15,169 +16,36 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 // For parsing of the theme files
 var openTag = []byte("{{")
-var closeTag = []byte("}}")
-var twoPartArgumentChecker = regexp.MustCompile("(\\S+?)\\s*?=\\s*?['\"](.*?)['\"]")
-var quoteTagChecker = regexp.MustCompile("(.*?)[\"'](.+?)[\"']$")
 
 func getFunction(name string) func(*structure.Helper, *structure.RequestData) []byte {
 	if helperFuctions[name] != nil {
 		return helperFuctions[name]
 	} else {
-		return helperFuctions["null"]
+		// Not a helper, so it has to be a path like post.title or ../title
+		return pathFunc
 	}
 }
 
-func createHelper(helperName []byte, unescaped bool, startPos int, block []byte, children []structure.Helper, elseHelper *structure.Helper) *structure.Helper {
-	var helper *structure.Helper
-	// Check for =arguments
-	twoPartArgumentResult := twoPartArgumentChecker.FindAllSubmatch(helperName, -1)
-	twoPartArguments := make([][]byte, 0)
-	for _, arg := range twoPartArgumentResult {
-		if len(arg) == 3 {
-			twoPartArguments = append(twoPartArguments, bytes.Join(arg[1:], []byte("=")))
-			//remove =argument from helper name
-			helperName = bytes.Replace(helperName, arg[0], []byte(""), 1)
-		}
-	}
-	// Separate arguments (e.g. 'if @blog.title')
-	tags := bytes.Fields(helperName)
-	for index, tag := range tags {
-		//remove "" around tag if present
-		quoteTagResult := quoteTagChecker.FindSubmatch(tag)
-		if len(quoteTagResult) != 0 {
-			// Get the string inside the quotes (3rd element in array)
-			tag = quoteTagResult[2]
-		}
-		//TODO: This may have to change if the first argument is surrounded by quotes
-		if index == 0 {
-			helper = makeHelper(string(tag), unescaped, startPos, block, children)
-		} else {
-			// Handle whitespaces in arguments
-			helper.Arguments = append(helper.Arguments, *makeHelper(string(tag), unescaped, 0, []byte{}, nil))
-		}
-	}
-	if len(twoPartArguments) != 0 {
-		for _, arg := range twoPartArguments {
-			// Check for quotes in the =argument (has beem omitted from the check above)
-			quoteTagResult := quoteTagChecker.FindSubmatch(arg)
-			if len(quoteTagResult) != 0 {
-				// Join poth parts, this time without the quotes
-				arg = bytes.Join([][]byte{quoteTagResult[1], quoteTagResult[2]}, []byte(""))
-			}
-			helper.Arguments = append(helper.Arguments, *makeHelper(string(arg), unescaped, 0, []byte{}, nil))
-		}
-	}
-	if elseHelper != nil {
-		helper.Arguments = append(helper.Arguments, *elseHelper)
-	}
-	return helper
-}
-
 func makeHelper(tag string, unescaped bool, startPos int, block []byte, children []structure.Helper) *structure.Helper {
-	return &structure.Helper{Name: tag, Arguments: nil, Unescaped: unescaped, Position: startPos, Block: block, Children: children, Function: getFunction(tag)}
-}
-
-func findHelper(data []byte, allHelpers []structure.Helper) ([]byte, []structure.Helper) {
-	startPos := bytes.Index(data, openTag)
-	endPos := bytes.Index(data, closeTag)
-	if startPos != -1 && endPos != -1 {
-		openTagLength := len(openTag)
-		closeTagLength := len(closeTag)
-		unescaped := false
-		helperName := data[startPos+openTagLength : endPos]
-		// Check if helper calls for unescaped text (e.g. three brackets - {{{title}}})
-		if bytes.HasPrefix(helperName, []byte("{")) {
-			unescaped = true
-			openTagLength++ //not necessary
-			closeTagLength++
-			helperName = helperName[len([]byte("{")):]
-		}
-		helperName = bytes.Trim(helperName, " ") //make sure there are no trailing whitespaces
-		// Remove helper from data
-		parts := [][]byte{data[:startPos], data[endPos+closeTagLength:]}
-		data = bytes.Join(parts, []byte(""))
-		// Check if comment
-		if bytes.HasPrefix(helperName, []byte("! ")) || bytes.HasPrefix(helperName, []byte("!--")) {
-			return findHelper(data, allHelpers)
-		}
-		// Check if block
-		if bytes.HasPrefix(helperName, []byte("#")) {
-			helperName = helperName[len([]byte("#")):] //remove '#' from helperName
-			var helper structure.Helper
-			data, helper = findBlock(data, helperName, unescaped, startPos) //only use the data string after the opening tag
-			allHelpers = append(allHelpers, helper)
-			return findHelper(data, allHelpers)
-		}
-		allHelpers = append(allHelpers, *createHelper(helperName, unescaped, startPos, []byte{}, nil, nil))
-		return findHelper(data, allHelpers)
-	} else {
-		return data, allHelpers
-	}
-}
-
-func findBlock(data []byte, helperName []byte, unescaped bool, startPos int) ([]byte, structure.Helper) {
-	arguments := bytes.Fields(helperName)
-	tag := arguments[0] // Get only the first tag (e.g. 'if' in 'if @blog.cover')
-	arguments = arguments[1:]
-	closeParts := []string{"{{2,3}\\s*/", string(tag), ".?}{2,3}"}
-	openParts := []string{"{{2,3}\\s*#", string(tag), ".+?}{2,3}"}
-	closeRegex := regexp.MustCompile(strings.Join(closeParts, ""))
-	openRegex := regexp.MustCompile(strings.Join(openParts, ""))
-	closePositions := closeRegex.FindAllIndex(data, -1)
-	openPositions := openRegex.FindAllIndex(data, -1)
-	// Check if there are opening tags before the closing tag
-	positionIndex := 0
-	for _, openPosition := range openPositions {
-		if openPosition[0] < closePositions[positionIndex][0] {
-			positionIndex++
-		}
-	}
-	block := data[startPos:closePositions[positionIndex][0]]
-	parts := [][]byte{data[:startPos], data[closePositions[positionIndex][1]:]}
-	data = bytes.Join(parts, []byte(""))
-	children := make([]structure.Helper, 0)
-	block, children = findHelper(block, children)
-	// Handle else (search children for else helper)
-	for index, child := range children {
-		if child.Name == "else" {
-			elseHelper := child
-			// Change blocks
-			elseHelper.Block = block[elseHelper.Position:]
-			block = block[:elseHelper.Position]
-			// Change children, omit else helper
-			elseHelper.Children = children[(index + 1):]
-			// Change Position in children of else helper
-			for indexElse := range elseHelper.Children {
-				elseHelper.Children[indexElse].Position = elseHelper.Children[indexElse].Position - elseHelper.Position
-			}
-			children = children[:index]
-			helper := createHelper(helperName, unescaped, startPos, block, children, &elseHelper)
-			return data, *helper
-		}
-	}
-	helper := createHelper(helperName, unescaped, startPos, block, children, nil)
-	return data, *helper
+	return &structure.Helper{Name: tag, Arguments: nil, Unescaped: unescaped, Position: startPos, Block: block, Children: children, Function: getFunction(tag), Write: helperWriters[tag]}
 }
 
-func compileTemplate(data []byte, name string) *structure.Helper {
+func compileTemplate(data []byte, name string, fileName string) (*structure.Helper, error) {
 	baseHelper := structure.Helper{Name: name, Arguments: nil, Unescaped: false, Position: 0, Block: []byte{}, Children: nil, Function: getFunction(name)}
-	allHelpers := make([]structure.Helper, 0)
-	data, allHelpers = findHelper(data, allHelpers)
-	baseHelper.Block = data
-	baseHelper.Children = allHelpers
-	// Handle extend helpers
-	for index, child := range baseHelper.Children {
-		if child.Name == "body" {
-			baseHelper.BodyHelper = &baseHelper.Children[index] //TODO: This handles only one body helper per hbs file. That is a potential bug source, but no theme should be using more than one per file anyway.
-		}
+	block, allHelpers, err := parseTemplate(data, fileName)
+	if err != nil {
+		return nil, err
 	}
-	return &baseHelper
+	baseHelper.Block = block
+	baseHelper.Children = allHelpers
+	return &baseHelper, nil
 }
 
 func createTemplateFromFile(filename string) (*structure.Helper, error) {
@@ -185,129 +53,231 @@ func createTemplateFromFile(filename string) (*structure.Helper, error) {
 	if err != nil {
 		return nil, err
 	}
-	fileNameWithoutExtension := helpers.GetFilenameWithoutExtension(filename)
-	// Check if a helper with the same name is already in the map
-	if compiledTemplates.m[fileNameWithoutExtension] != nil {
-		return nil, errors.New("Error: Conflicting .hbs name '" + fileNameWithoutExtension + "'. A theme file of the same name already exists.")
-	}
-	helper := compileTemplate(data, fileNameWithoutExtension)
-	return helper, nil
+	return compileTemplate(data, helpers.GetFilenameWithoutExtension(filename), filename)
 }
 
-func compileFile(fileName string) error {
+// Function to compile a theme file. Partials are saved by their path relative to the partials folder (e.g. post/card),
+// all other templates by their file name. Templates and partials can have the same name.
+func (t *Templates) compileFile(themePath string, fileName string) error {
 	helper, err := createTemplateFromFile(fileName)
 	if err != nil {
 		return err
 	}
-	compiledTemplates.m[helper.Name] = helper
+	if partialName, ok := partialNameOf(themePath, fileName); ok {
+		t.partials[partialName] = helper
+		return nil
+	}
+	// Check if a template with the same name is already in the map
+	if t.m[helper.Name] != nil {
+		return errors.New("Error: Conflicting .hbs name '" + helper.Name + "'. A theme file of the same name already exists.")
+	}
+	t.m[helper.Name] = helper
 	return nil
 }
 
-func inspectTemplateFile(filePath string, info os.FileInfo, _ error) error {
-	if !info.IsDir() && filepath.Ext(filePath) == ".hbs" {
-		err := compileFile(filePath)
-		if err != nil {
-			return err
-		}
+// Function to get the name of a partial from its file path. Returns false if the file is not in the partials folder.
+func partialNameOf(themePath string, fileName string) (string, bool) {
+	relativePath, err := filepath.Rel(filepath.Join(themePath, "partials"), fileName)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(strings.TrimSuffix(relativePath, filepath.Ext(relativePath))), true
+}
+
+func (t *Templates) compileBuiltInPartial(name string) error {
+	helper, err := createTemplateFromFile(filepath.Join(filenames.HbsFilepath, name+".hbs"))
+	if err != nil {
+		return err
 	}
+	t.partials[name] = helper
 	return nil
 }
 
-func compileTheme(themePath string) error {
+// Function to compile a theme into a new set of templates. The compiled templates aren't changed.
+func compileTheme(themePath string) (*Templates, error) {
 	// Check if the theme folder exists
 	if _, err := os.Stat(themePath); os.IsNotExist(err) {
-		return errors.New("Couldn't find theme files in " + themePath + ": " + err.Error())
+		return nil, errors.New("Couldn't find theme files in " + themePath + ": " + err.Error())
 	}
-	err := filepath.Walk(themePath, inspectTemplateFile)
+	t := newTemplates()
+	err := filepath.Walk(themePath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(filePath) == ".hbs" {
+			return t.compileFile(themePath, filePath)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Check if index and post templates are compiled
-	if _, ok := compiledTemplates.m["index"]; !ok {
-		return errors.New("couldn't compile template 'index', may be index.hbs is missing")
+	if _, ok := t.m["index"]; !ok {
+		return nil, errors.New("couldn't compile template 'index', may be index.hbs is missing")
 	}
-	if _, ok := compiledTemplates.m["post"]; !ok {
-		return errors.New("couldn't compile template 'post', may be post.hbs is missing")
+	if _, ok := t.m["post"]; !ok {
+		return nil, errors.New("couldn't compile template 'post', may be post.hbs is missing")
 	}
 	// Check if pagination and navigation templates have been provided by the theme.
 	// If not, use the build in ones.
-	if _, ok := compiledTemplates.m["pagination"]; !ok {
-		err = compileFile(filepath.Join(filenames.HbsFilepath, "pagination.hbs"))
+	if _, ok := t.lookupPartial("pagination"); !ok {
+		err = t.compileBuiltInPartial("pagination")
 		if err != nil {
 			log.Println("Warning: Couldn't compile pagination template.")
 		}
 	}
-	if _, ok := compiledTemplates.m["navigation"]; !ok {
-		err = compileFile(filepath.Join(filenames.HbsFilepath, "navigation.hbs"))
+	if _, ok := t.lookupPartial("navigation"); !ok {
+		err = t.compileBuiltInPartial("navigation")
 		if err != nil {
 			log.Println("Warning: Couldn't compile navigation template.")
 		}
 
 	}
-	return nil
+	// Load the config from the package.json of the theme. A broken package.json shouldn't take the blog offline.
+	t.theme = filepath.Base(themePath)
+	config, warnings, err := loadThemeConfig(themePath)
+	t.config = config
+	if err != nil {
+		log.Println("Warning: " + err.Error())
+	}
+	for _, warning := range warnings {
+		log.Println("Warning: " + warning)
+	}
+	// Load the translations of the theme
+	methods.Blog.RLock()
+	language := blogLanguage(methods.Blog)
+	methods.Blog.RUnlock()
+	t.translations, err = loadTranslations(themePath, language)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
-func checkThemes() error {
-	// Get currently set theme from database
+// Function to compile the active theme
+func compileActiveTheme() (*Templates, error) {
 	activeTheme, err := database.RetrieveActiveTheme()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	currentThemePath := filepath.Join(filenames.ThemesFilepath, *activeTheme)
-	err = compileTheme(currentThemePath)
+	return compileTheme(filepath.Join(filenames.ThemesFilepath, *activeTheme))
+}
+
+// Function to compile the active theme. If it can't be compiled, the other themes are tried and the first
+// one that compiles is made the active theme. Only used on startup when there are no templates to keep using.
+func checkThemes() (*Templates, error) {
+	t, err := compileActiveTheme()
 	if err == nil {
-		return nil
+		return t, nil
 	}
+	log.Println("Error: Couldn't compile the active theme:", err)
 	// If the currently set theme couldnt be compiled, try the default theme (promenade)
-	err = compileTheme(filepath.Join(filenames.ThemesFilepath, "promenade"))
+	t, err = compileTheme(filepath.Join(filenames.ThemesFilepath, "promenade"))
 	if err == nil {
 		// Update the theme name in the database
 		err = methods.UpdateActiveTheme("promenade", 1)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return nil
+		return t, nil
 	}
 	// If all of that didn't work, try the available themes in order
 	allThemes := GetAllThemes()
 	for _, theme := range allThemes {
-		err = compileTheme(filepath.Join(filenames.ThemesFilepath, theme))
+		t, err = compileTheme(filepath.Join(filenames.ThemesFilepath, theme))
 		if err == nil {
 			// Update the theme name in the database
 			err = methods.UpdateActiveTheme(theme, 1)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			return nil
+			return t, nil
 		}
 	}
-	return errors.New("Couldn't find a theme to use in " + filenames.ThemesFilepath)
+	return nil, errors.New("Couldn't find a theme to use in " + filenames.ThemesFilepath)
 }
 
+// ReloadStatus: result of the last compilation of the templates, used by the admin api
+type ReloadStatus struct {
+	Theme    string     // Theme of the templates that are used
+	LoadedAt *time.Time // Time the templates that are used have been compiled
+	Error    string     // Error of the last compilation, empty if it succeeded
+	FailedAt *time.Time
+}
+
+var reloadStatus = struct {
+	sync.RWMutex
+	ReloadStatus
+}{}
+
+// Only one theme is compiled at a time (the watcher, the admin api and SIGHUP can reload the templates)
+var generateMutex sync.Mutex
+
+// Function to get the result of the last compilation of the templates
+func GetReloadStatus() ReloadStatus {
+	reloadStatus.RLock()
+	defer reloadStatus.RUnlock()
+	return reloadStatus.ReloadStatus
+}
+
+// Function to compile the active theme and use it for all following requests. The theme is compiled into a
+// new set of templates first, so if it has errors, the blog keeps using the previous templates.
 func Generate() error {
-	compiledTemplates.Lock()
-	defer compiledTemplates.Unlock()
-	// First clear compiledTemplates map (theme could have been changed)
-	compiledTemplates.m = make(map[string]*structure.Helper)
-	// Compile all template files
-	err := checkThemes()
+	generateMutex.Lock()
+	defer generateMutex.Unlock()
+	compiledTemplates.RLock()
+	isFirstGeneration := len(compiledTemplates.m) == 0
+	compiledTemplates.RUnlock()
+	var t *Templates
+	var err error
+	if isFirstGeneration {
+		t, err = checkThemes()
+	} else {
+		t, err = compileActiveTheme()
+	}
+	if err == nil {
+		err = t.loadCustomValues()
+	}
+	currentTime := date.GetCurrentTime()
+	reloadStatus.Lock()
 	if err != nil {
+		reloadStatus.Error = err.Error()
+		reloadStatus.FailedAt = &currentTime
+	} else {
+		reloadStatus.ReloadStatus = ReloadStatus{Theme: t.theme, LoadedAt: &currentTime}
+	}
+	reloadStatus.Unlock()
+	if err != nil {
+		if !isFirstGeneration {
+			log.Println("Error: Couldn't compile the templates, keeping the previous ones:", err)
+		}
 		return err
 	}
+	// Swap in the new templates. Requests that are being rendered finish with the previous ones.
+	compiledTemplates.Lock()
+	compiledTemplates.m = t.m
+	compiledTemplates.partials = t.partials
+	compiledTemplates.translations = t.translations
+	compiledTemplates.theme = t.theme
+	compiledTemplates.config = t.config
+	compiledTemplates.custom = t.custom
+	compiledTemplates.Unlock()
+	cache.Pages.Purge()
 	// If the dev flag is set, watch the theme directory and the plugin directoy for changes
 	// TODO: It seems unclean to do the watching of the plugins in the templates package. Move this somewhere else.
 	if flags.IsInDevMode {
-		// Get the currently used theme path
-		activeTheme, err := database.RetrieveActiveTheme()
-		if err != nil {
-			return err
-		}
-		currentThemePath := filepath.Join(filenames.ThemesFilepath, *activeTheme)
 		// Create watcher
-		err = watcher.Watch([]string{currentThemePath, filenames.PluginsFilepath}, map[string]func() error{".hbs": Generate, ".lua": plugins.Load})
+		err = watcher.Watch([]string{filepath.Join(filenames.ThemesFilepath, t.theme), filenames.PluginsFilepath}, map[string]func() error{".hbs": Generate, ".lua": reloadPlugins})
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// Function to reload the plugins. Cached pages could contain the output of the old plugins.
+func reloadPlugins() error {
+	defer cache.Pages.Purge()
+	return plugins.Load()
+}
@@ -0,0 +1,259 @@
+package templates
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"journey/database"
+	"journey/structure"
+)
+
+// Number of results of {{#get}} if no limit is given
+const defaultGetLimit = 15
+
+func getFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	return bufferOutput(func(writer io.Writer) error {
+		return writeGet(writer, helper, values)
+	})
+}
+
+func writeGet(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	if len(helper.Arguments) == 0 {
+		return nil
+	}
+	resource := helper.Arguments[0].Name
+	results, err := retrieveGetResults(resource, evaluateArguments(helper.Arguments[1:], values), values)
+	if err != nil {
+		log.Println("Couldn't get "+resource+":", err)
+	}
+	if len(results) == 0 {
+		// Else execute the else helper which is always at the last index of the get helper Arguments
+		if elseHelper := &helper.Arguments[len(helper.Arguments)-1]; elseHelper.Name == "else" {
+			return executeTemplate(writer, elseHelper, values, values.CurrentHelperContext)
+		}
+		return nil
+	}
+	// Replace the posts of the request with the results while the block is executed
+	posts := values.Posts
+	postIndex := values.CurrentPostIndex
+	defer func() {
+		values.Posts = posts
+		values.CurrentPostIndex = postIndex
+	}()
+	values.Posts = results
+	values.CurrentPostIndex = 0
+	return executeTemplate(writer, helper, values, 0) // context = index
+}
+
+// Function to run the query of a get helper. Tags and authors are attached to empty posts
+// since the tag and author helpers read them from the current post.
+func retrieveGetResults(resource string, arguments map[string]string, values *structure.RequestData) ([]structure.Post, error) {
+	query, err := makeContentQuery(arguments, values)
+	if err != nil {
+		return nil, err
+	}
+	switch resource {
+	case "posts":
+		return database.RetrievePostsByQuery(query)
+	case "tags":
+		tags, err := database.RetrieveTagsByQuery(query)
+		if err != nil || len(tags) == 0 {
+			return nil, err
+		}
+		return []structure.Post{{Tags: tags}}, nil
+	case "authors":
+		users, err := database.RetrieveUsersByQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		posts := make([]structure.Post, len(users))
+		for index := range users {
+			posts[index].Author = &users[index]
+		}
+		return posts, nil
+	default:
+		return nil, errors.New("unknown resource, only posts, tags and authors are supported")
+	}
+}
+
+// Function to create a content query from the limit, order, filter and include arguments of a get helper
+func makeContentQuery(arguments map[string]string, values *structure.RequestData) (*structure.ContentQuery, error) {
+	query := structure.ContentQuery{Limit: defaultGetLimit}
+	if limit, ok := arguments["limit"]; ok {
+		if limit == "all" {
+			query.Limit = -1
+		} else {
+			number, err := strconv.ParseInt(limit, 10, 64)
+			if err != nil || number < 1 {
+				return nil, errors.New("invalid limit: " + limit)
+			}
+			query.Limit = number
+		}
+	}
+	if order := arguments["order"]; order != "" {
+		for _, part := range strings.Split(order, ",") {
+			fields := strings.Fields(part)
+			if len(fields) == 0 || len(fields) > 2 {
+				return nil, errors.New("invalid order: " + order)
+			}
+			contentOrder := structure.ContentOrder{Field: fields[0]}
+			if len(fields) == 2 {
+				switch strings.ToLower(fields[1]) {
+				case "asc":
+				case "desc":
+					contentOrder.Descending = true
+				default:
+					return nil, errors.New("invalid order: " + order)
+				}
+			}
+			if contentOrder.Field == "count.posts" {
+				query.IncludeCount = true
+			}
+			query.Order = append(query.Order, contentOrder)
+		}
+	}
+	// Tags and the author of posts are always included. Only the post count has to be requested.
+	for _, include := range strings.Split(arguments["include"], ",") {
+		if strings.TrimSpace(include) == "count.posts" {
+			query.IncludeCount = true
+		}
+	}
+	if filter := arguments["filter"]; filter != "" {
+		filter, err := replaceFilterPlaceholders(filter, values)
+		if err != nil {
+			return nil, err
+		}
+		query.Filters, err = parseContentFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &query, nil
+}
+
+// Function to parse a filter like 'featured:true+tag:[news,tech]+id:-5'. Only "and" (+) is supported.
+func parseContentFilter(filter string) ([]structure.ContentFilter, error) {
+	filters := make([]structure.ContentFilter, 0)
+	for _, clause := range splitFilter(filter, '+') {
+		parts := strings.SplitN(clause, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("invalid filter: " + clause)
+		}
+		contentFilter := structure.ContentFilter{Field: strings.TrimSpace(parts[0]), Operator: "="}
+		value := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(value, "-") {
+			contentFilter.Operator = "!="
+			value = value[1:]
+		} else {
+			for _, operator := range []string{">=", "<=", ">", "<"} {
+				if strings.HasPrefix(value, operator) {
+					contentFilter.Operator = operator
+					value = value[len(operator):]
+					break
+				}
+			}
+		}
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, listValue := range splitFilter(value[1:len(value)-1], ',') {
+				contentFilter.Values = append(contentFilter.Values, unquoteFilterValue(listValue))
+			}
+		} else if len(splitFilter(value, ',')) > 1 {
+			return nil, errors.New("\"or\" is not supported in filters: " + clause)
+		} else {
+			contentFilter.Values = []string{unquoteFilterValue(value)}
+		}
+		// Journey has no private tags or authors. Everything is public.
+		if contentFilter.Field == "visibility" && contentFilter.Operator == "=" && len(contentFilter.Values) == 1 && contentFilter.Values[0] == "public" {
+			continue
+		}
+		filters = append(filters, contentFilter)
+	}
+	return filters, nil
+}
+
+// Function to split a filter at a separator that is not inside of quotes or brackets
+func splitFilter(filter string, separator byte) []string {
+	parts := make([]string, 0)
+	depth := 0
+	var quote byte
+	start := 0
+	for index := 0; index < len(filter); index++ {
+		switch character := filter[index]; {
+		case quote != 0:
+			if character == quote {
+				quote = 0
+			}
+		case character == '\'' || character == '"':
+			quote = character
+		case character == '[':
+			depth++
+		case character == ']':
+			depth--
+		case character == separator && depth == 0:
+			parts = append(parts, strings.TrimSpace(filter[start:index]))
+			start = index + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(filter[start:]))
+}
+
+func unquoteFilterValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Function to insert values of the current post into a filter (e.g. 'id:-{{post.id}}' to exclude the current post)
+func replaceFilterPlaceholders(filter string, values *structure.RequestData) (string, error) {
+	for {
+		start := strings.Index(filter, "{{")
+		if start == -1 {
+			return filter, nil
+		}
+		end := strings.Index(filter[start:], "}}")
+		if end == -1 {
+			return "", errors.New("unclosed {{ in filter: " + filter)
+		}
+		end += start
+		value, err := filterPlaceholderValue(strings.TrimSpace(filter[start+2:end]), values)
+		if err != nil {
+			return "", err
+		}
+		filter = filter[:start] + value + filter[end+2:]
+	}
+}
+
+func filterPlaceholderValue(name string, values *structure.RequestData) (string, error) {
+	var post *structure.Post
+	if values.CurrentPostIndex < len(values.Posts) {
+		post = &values.Posts[values.CurrentPostIndex]
+	}
+	switch name {
+	case "id", "post.id":
+		if post != nil {
+			return strconv.FormatInt(post.Id, 10), nil
+		}
+	case "slug", "post.slug":
+		if post != nil {
+			return post.Slug, nil
+		}
+	case "tag.slug", "primary_tag.slug":
+		if values.CurrentTag != nil {
+			return values.CurrentTag.Slug, nil
+		} else if post != nil && len(post.Tags) != 0 {
+			return post.Tags[0].Slug, nil
+		}
+	case "author.slug", "primary_author.slug":
+		if post != nil && post.Author != nil {
+			return post.Author.Slug, nil
+		}
+	default:
+		return "", errors.New("{{" + name + "}} can't be used in filters")
+	}
+	return "", nil
+}
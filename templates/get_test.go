@@ -0,0 +1,146 @@
+package templates
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"journey/structure"
+)
+
+func TestParseContentFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected []structure.ContentFilter
+		valid    bool
+	}{
+		{"featured:true+tag:[news, tech]+id:-5", []structure.ContentFilter{{Field: "featured", Operator: "=", Values: []string{"true"}}, {Field: "tag", Operator: "=", Values: []string{"news", "tech"}}, {Field: "id", Operator: "!=", Values: []string{"5"}}}, true},
+		{"tag:-[news,'a+b']", []structure.ContentFilter{{Field: "tag", Operator: "!=", Values: []string{"news", "a+b"}}}, true},
+		{"published_at:>='2020-01-01 13:00:00'", []structure.ContentFilter{{Field: "published_at", Operator: ">=", Values: []string{"2020-01-01 13:00:00"}}}, true},
+		// Everything is public in Journey
+		{"visibility:public+id:<3", []structure.ContentFilter{{Field: "id", Operator: "<", Values: []string{"3"}}}, true},
+		{"tag:news,tech", nil, false},
+		{"featured", nil, false},
+		{":true", nil, false},
+	}
+	for _, test := range tests {
+		filters, err := parseContentFilter(test.filter)
+		if (err == nil) != test.valid || !reflect.DeepEqual(filters, test.expected) {
+			t.Errorf("Expected %+v for %s, received %+v (%v)", test.expected, test.filter, filters, err)
+		}
+	}
+}
+
+func TestSplitFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected []string
+	}{
+		{"a:1+b:2", []string{"a:1", "b:2"}},
+		{"a:[1+2]+b:'3+4'+c:\"5+6\"", []string{"a:[1+2]", "b:'3+4'", "c:\"5+6\""}},
+		{" a:1 ", []string{"a:1"}},
+	}
+	for _, test := range tests {
+		if parts := splitFilter(test.filter, '+'); !reflect.DeepEqual(parts, test.expected) {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.filter, parts)
+		}
+	}
+}
+
+func TestReplaceFilterPlaceholders(t *testing.T) {
+	values := structure.RequestData{Posts: []structure.Post{{Id: 2, Slug: "two", Tags: []structure.Tag{{Slug: "news"}}, Author: &structure.User{Slug: "jane"}}}}
+	tests := []struct {
+		filter   string
+		expected string
+		valid    bool
+	}{
+		{"id:-{{post.id}}", "id:-2", true},
+		{"tag:{{ primary_tag.slug }}+author:{{author.slug}}+slug:-{{slug}}", "tag:news+author:jane+slug:-two", true},
+		{"featured:true", "featured:true", true},
+		{"title:{{title}}", "", false},
+		{"id:{{id", "", false},
+	}
+	for _, test := range tests {
+		filter, err := replaceFilterPlaceholders(test.filter, &values)
+		if (err == nil) != test.valid || filter != test.expected {
+			t.Errorf("Expected %q for %s, received %q (%v)", test.expected, test.filter, filter, err)
+		}
+	}
+}
+
+func TestMakeContentQuery(t *testing.T) {
+	tests := []struct {
+		arguments map[string]string
+		expected  *structure.ContentQuery
+	}{
+		{map[string]string{}, &structure.ContentQuery{Limit: defaultGetLimit}},
+		{map[string]string{"limit": "all", "include": "authors, count.posts"}, &structure.ContentQuery{Limit: -1, IncludeCount: true}},
+		{map[string]string{"limit": "3", "order": "published_at desc, title"}, &structure.ContentQuery{Limit: 3, Order: []structure.ContentOrder{{Field: "published_at", Descending: true}, {Field: "title"}}}},
+		{map[string]string{"order": "count.posts DESC"}, &structure.ContentQuery{Limit: defaultGetLimit, Order: []structure.ContentOrder{{Field: "count.posts", Descending: true}}, IncludeCount: true}},
+		{map[string]string{"filter": "featured:true"}, &structure.ContentQuery{Limit: defaultGetLimit, Filters: []structure.ContentFilter{{Field: "featured", Operator: "=", Values: []string{"true"}}}}},
+		{map[string]string{"limit": "0"}, nil},
+		{map[string]string{"limit": "some"}, nil},
+		{map[string]string{"order": "title sideways"}, nil},
+		{map[string]string{"order": "title,"}, nil},
+		{map[string]string{"filter": "title:{{title}}"}, nil},
+		{map[string]string{"filter": "tag:news,tech"}, nil},
+	}
+	for _, test := range tests {
+		query, err := makeContentQuery(test.arguments, &structure.RequestData{})
+		if (err == nil) != (test.expected != nil) || !reflect.DeepEqual(query, test.expected) {
+			t.Errorf("Expected %+v for %v, received %+v (%v)", test.expected, test.arguments, query, err)
+		}
+	}
+}
+
+func TestGetHelper(t *testing.T) {
+	defer useTestDatabase(t)()
+	published := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	userIds := insertTestUsers(t, "a", "b")
+	tagIds := insertTestTags(t, "news", "tech")
+	insertTestPost(t, "One", published, true, true, userIds[0], tagIds[0])
+	insertTestPost(t, "Two", published.Add(time.Hour), true, false, userIds[1], tagIds[1], tagIds[0])
+	insertTestPost(t, "Three", published.Add(2*time.Hour), true, false, userIds[0], tagIds[1])
+	// Drafts are never part of the results
+	insertTestPost(t, "Draft", published.Add(3*time.Hour), false, true, userIds[1], tagIds[0], tagIds[1])
+	// The current post is Two
+	current := structure.Post{Id: 2}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#get "posts"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three Two One "},
+		{`{{#get "posts" limit="2"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three Two "},
+		{`{{#get "posts" limit="all" order="published_at asc"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "One Two Three "},
+		{`{{#get "posts" order="title"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "One Three Two "},
+		{`{{#get "posts" filter="tag:news"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Two One "},
+		{`{{#get "posts" filter="author:a"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three One "},
+		{`{{#get "posts" filter="featured:true"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "One "},
+		{`{{#get "posts" filter="id:-{{post.id}}"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three One "},
+		{`{{#get "posts" filter="tag:-tech"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "One "},
+		{`{{#get "posts" filter="id:[1,3]"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three One "},
+		{`{{#get "posts" filter="tag:[news,tech]+author:-b"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three One "},
+		{`{{#get "posts" filter="published_at:>='2020-01-01 13:00:00'"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "Three Two "},
+		{`{{#get "posts" filter="published_at:<'2020-01-01T13:00:00Z'"}}{{#foreach posts}}{{title}} {{/foreach}}{{/get}}`, "One "},
+		{`{{#get "posts" filter="tag:sports"}}{{title}}{{else}}none{{/get}}`, "none"},
+		{`{{#get "tags" include="count.posts"}}{{#foreach tags}}{{name}}:{{count.posts}} {{/foreach}}{{/get}}`, "news:2 tech:2 "},
+		{`{{#get "tags" filter="slug:-news"}}{{#foreach tags}}{{name}}:{{count.posts}} {{/foreach}}{{/get}}`, "tech:0 "},
+		{`{{#get "authors" order="count.posts desc, name desc"}}{{#foreach authors}}{{name}}:{{count.posts}} {{/foreach}}{{/get}}`, "a:2 b:1 "},
+		{`{{#get "authors" filter="slug:b"}}{{#foreach authors}}{{name}}{{/foreach}}{{/get}}`, "b"},
+		// Invalid queries execute the else block
+		{`{{#get "comments"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" limit="0"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" order="slug sideways"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" order="markdown"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" filter="markdown:hello"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" filter="featured:>true"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" filter="id:one"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "posts" filter="published_at:>yesterday"}}{{title}}{{else}}error{{/get}}`, "error"},
+		{`{{#get "tags" order="title"}}{{name}}{{else}}error{{/get}}`, "error"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: []structure.Post{current}}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
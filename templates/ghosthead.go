@@ -0,0 +1,332 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"journey/conversion"
+	"journey/filenames"
+	"journey/structure"
+)
+
+// Number of words of the post used as description if the post has no meta description
+const descriptionWords = 50
+
+// jsonLd: structured data (https://schema.org) of a page. Which fields are set depends on the type.
+type jsonLd struct {
+	Context          string       `json:"@context,omitempty"`
+	Type             string       `json:"@type"`
+	Id               string       `json:"@id,omitempty"`
+	Publisher        *jsonLd      `json:"publisher,omitempty"`
+	Author           *jsonLd      `json:"author,omitempty"`
+	Name             string       `json:"name,omitempty"`
+	Headline         string       `json:"headline,omitempty"`
+	Url              string       `json:"url,omitempty"`
+	DatePublished    string       `json:"datePublished,omitempty"`
+	Logo             *jsonLdImage `json:"logo,omitempty"`
+	Image            *jsonLdImage `json:"image,omitempty"`
+	Keywords         string       `json:"keywords,omitempty"`
+	SameAs           []string     `json:"sameAs,omitempty"`
+	Description      string       `json:"description,omitempty"`
+	MainEntityOfPage *jsonLd      `json:"mainEntityOfPage,omitempty"`
+}
+
+type jsonLdImage struct {
+	Type   string `json:"@type"`
+	Url    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// headData: what ghost_head outputs for the current page
+type headData struct {
+	url         string
+	title       string
+	description string
+	image       string
+	ogType      string
+	rss         string
+	meta        [][2]string // Additional meta tags as property and content
+	structured  *jsonLd
+}
+
+// Sizes of local images. Images don't change once they are uploaded, so the sizes are only read once.
+var imageSizes = struct {
+	sync.RWMutex
+	m map[string][2]int
+}{m: make(map[string][2]int)}
+
+func ghostHeadFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	data := makeHeadData(values)
+	var buffer bytes.Buffer
+	if data.description != "" {
+		writeMetaTag(&buffer, "name", "description", data.description)
+	}
+	buffer.WriteString("<link rel=\"canonical\" href=\"")
+	buffer.WriteString(html.EscapeString(data.url))
+	buffer.WriteString("\">\n")
+	buffer.WriteString("<meta name=\"referrer\" content=\"no-referrer-when-downgrade\">\n")
+	// Paged pages only get the canonical link
+	if data.structured != nil {
+		writeMetaTag(&buffer, "property", "og:site_name", string(values.Blog.Title))
+		writeMetaTag(&buffer, "property", "og:type", data.ogType)
+		writeMetaTag(&buffer, "property", "og:title", data.title)
+		if data.description != "" {
+			writeMetaTag(&buffer, "property", "og:description", data.description)
+		}
+		writeMetaTag(&buffer, "property", "og:url", data.url)
+		if data.image != "" {
+			writeMetaTag(&buffer, "property", "og:image", data.image)
+			if width, height := imageSize(values, data.image); width != 0 {
+				writeMetaTag(&buffer, "property", "og:image:width", strconv.Itoa(width))
+				writeMetaTag(&buffer, "property", "og:image:height", strconv.Itoa(height))
+			}
+		}
+		for _, meta := range data.meta {
+			name := "property"
+			if strings.HasPrefix(meta[0], "twitter:") {
+				name = "name"
+			}
+			writeMetaTag(&buffer, name, meta[0], meta[1])
+		}
+		if data.image != "" {
+			writeMetaTag(&buffer, "name", "twitter:card", "summary_large_image")
+		} else {
+			writeMetaTag(&buffer, "name", "twitter:card", "summary")
+		}
+		writeMetaTag(&buffer, "name", "twitter:title", data.title)
+		if data.description != "" {
+			writeMetaTag(&buffer, "name", "twitter:description", data.description)
+		}
+		writeMetaTag(&buffer, "name", "twitter:url", data.url)
+		if data.image != "" {
+			writeMetaTag(&buffer, "name", "twitter:image", data.image)
+		}
+		jsonBytes, err := json.MarshalIndent(data.structured, "", "    ")
+		if err == nil {
+			buffer.WriteString("<script type=\"application/ld+json\"")
+			if values.CspNonce != "" {
+				buffer.WriteString(" nonce=\"")
+				buffer.WriteString(values.CspNonce)
+				buffer.WriteString("\"")
+			}
+			buffer.WriteString(">\n")
+			buffer.Write(jsonBytes)
+			buffer.WriteString("\n</script>\n")
+		}
+	}
+	writeMetaTag(&buffer, "name", "generator", "Journey")
+	buffer.WriteString("<link rel=\"alternate\" type=\"application/rss+xml\" title=\"")
+	buffer.WriteString(html.EscapeString(string(values.Blog.Title)))
+	buffer.WriteString("\" href=\"")
+	buffer.WriteString(html.EscapeString(data.rss))
+	buffer.WriteString("\">")
+	// Code injection of the blog and the post
+	if len(values.Blog.CodeInjectionHead) != 0 {
+		buffer.WriteString("\n")
+		buffer.Write(values.Blog.CodeInjectionHead)
+	}
+	if post := currentPagePost(values); post != nil && len(post.CodeInjectionHead) != 0 {
+		buffer.WriteString("\n")
+		buffer.Write(post.CodeInjectionHead)
+	}
+	return buffer.Bytes()
+}
+
+func ghostFootFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	var buffer bytes.Buffer
+	buffer.Write(values.Blog.CodeInjectionFoot)
+	if post := currentPagePost(values); post != nil && len(post.CodeInjectionFoot) != 0 {
+		if buffer.Len() != 0 {
+			buffer.WriteString("\n")
+		}
+		buffer.Write(post.CodeInjectionFoot)
+	}
+	return buffer.Bytes()
+}
+
+// Function to get the post of a post or page template. Returns nil for all other templates.
+func currentPagePost(values *structure.RequestData) *structure.Post {
+	if values.CurrentTemplate == 1 && len(values.Posts) != 0 { // post
+		return &values.Posts[0]
+	}
+	return nil
+}
+
+func writeMetaTag(buffer *bytes.Buffer, attribute string, name string, content string) {
+	buffer.WriteString("<meta ")
+	buffer.WriteString(attribute)
+	buffer.WriteString("=\"")
+	buffer.WriteString(name)
+	buffer.WriteString("\" content=\"")
+	buffer.WriteString(html.EscapeString(content))
+	buffer.WriteString("\">\n")
+}
+
+// Function to collect the data of ghost_head for the template type of the request
+func makeHeadData(values *structure.RequestData) *headData {
+	blogUrl := string(values.Blog.Url)
+	data := headData{url: blogUrl + values.CurrentPath, title: string(values.Blog.Title), description: string(values.Blog.Description), image: absoluteUrl(values, values.Blog.Cover), ogType: "website", rss: blogUrl + "/rss/"}
+	publisher := &jsonLd{Type: "Organization", Name: string(values.Blog.Title), Url: blogUrl + "/", Logo: makeJsonLdImage(values, values.Blog.Logo)}
+	mainEntityOfPage := &jsonLd{Type: "WebPage", Id: blogUrl + "/"}
+	var post *structure.Post
+	if values.CurrentPostIndex < len(values.Posts) {
+		post = &values.Posts[values.CurrentPostIndex]
+	}
+	switch values.CurrentTemplate {
+	case 1: // post or page
+		if post == nil {
+			return &data
+		}
+		data.url = blogUrl + "/" + post.Slug + "/"
+		data.title = string(post.Title)
+		data.description = postDescription(post)
+		data.image = absoluteUrl(values, post.Image)
+		data.ogType = "article"
+		structured := &jsonLd{Context: "https://schema.org", Type: "Article", Publisher: publisher, Headline: data.title, Url: data.url, Image: makeJsonLdImage(values, post.Image), Description: data.description, MainEntityOfPage: &jsonLd{Type: "WebPage", Id: data.url}}
+		if post.IsPage {
+			data.ogType = "website"
+		}
+		if post.Date != nil {
+			structured.DatePublished = post.Date.Format(time.RFC3339)
+			data.meta = append(data.meta, [2]string{"article:published_time", structured.DatePublished})
+		}
+		tagNames := make([]string, 0, len(post.Tags))
+		for _, tag := range post.Tags {
+			tagNames = append(tagNames, string(tag.Name))
+			data.meta = append(data.meta, [2]string{"article:tag", string(tag.Name)})
+		}
+		structured.Keywords = strings.Join(tagNames, ", ")
+		if post.Author != nil {
+			structured.Author = makeJsonLdPerson(values, post.Author)
+			data.meta = append(data.meta, [2]string{"twitter:label1", "Written by"}, [2]string{"twitter:data1", string(post.Author.Name)})
+		}
+		if len(tagNames) != 0 {
+			data.meta = append(data.meta, [2]string{"twitter:label2", "Filed under"}, [2]string{"twitter:data2", structured.Keywords})
+		}
+		data.structured = structured
+	case 2: // tag
+		if values.CurrentTag == nil {
+			return &data
+		}
+		tagUrl := blogUrl + "/tag/" + values.CurrentTag.Slug + "/"
+		data.rss = tagUrl + "rss/"
+		if values.CurrentIndexPage > 1 {
+			return &data
+		}
+		data.url = tagUrl
+		data.title = string(values.CurrentTag.Name) + " - " + data.title
+		data.structured = &jsonLd{Context: "https://schema.org", Type: "Series", Publisher: publisher, Name: string(values.CurrentTag.Name), Url: tagUrl, Image: makeJsonLdImage(values, values.Blog.Cover), MainEntityOfPage: mainEntityOfPage}
+	case 3: // author
+		if post == nil || post.Author == nil {
+			return &data
+		}
+		author := post.Author
+		authorUrl := blogUrl + "/author/" + author.Slug + "/"
+		data.rss = authorUrl + "rss/"
+		if values.CurrentIndexPage > 1 {
+			return &data
+		}
+		data.url = authorUrl
+		data.title = string(author.Name) + " - " + data.title
+		data.description = string(author.Bio)
+		data.image = absoluteUrl(values, author.Cover)
+		data.ogType = "profile"
+		structured := makeJsonLdPerson(values, author)
+		structured.Context = "https://schema.org"
+		structured.Image = makeJsonLdImage(values, author.Cover)
+		structured.MainEntityOfPage = mainEntityOfPage
+		data.structured = structured
+	default: // index
+		if values.CurrentIndexPage > 1 {
+			return &data
+		}
+		data.url = blogUrl + "/"
+		data.structured = &jsonLd{Context: "https://schema.org", Type: "WebSite", Publisher: publisher, Url: data.url, Image: makeJsonLdImage(values, values.Blog.Cover), Description: data.description, MainEntityOfPage: mainEntityOfPage}
+	}
+	return &data
+}
+
+func makeJsonLdPerson(values *structure.RequestData, user *structure.User) *jsonLd {
+	person := &jsonLd{Type: "Person", Name: string(user.Name), Url: string(values.Blog.Url) + "/author/" + user.Slug + "/", Image: makeJsonLdImage(values, user.Image), Description: string(user.Bio)}
+	if len(user.Website) != 0 {
+		person.SameAs = []string{string(user.Website)}
+	}
+	return person
+}
+
+func makeJsonLdImage(values *structure.RequestData, imageUrl []byte) *jsonLdImage {
+	if len(imageUrl) == 0 {
+		return nil
+	}
+	image := &jsonLdImage{Type: "ImageObject", Url: absoluteUrl(values, imageUrl)}
+	image.Width, image.Height = imageSize(values, image.Url)
+	return image
+}
+
+// Function to get the description of a post. Uses the beginning of the post if it has no meta description.
+func postDescription(post *structure.Post) string {
+	if len(post.MetaDescription) != 0 {
+		return string(post.MetaDescription)
+	}
+	words := strings.Fields(string(conversion.StripTagsFromHtml(post.Html)))
+	if len(words) > descriptionWords {
+		return strings.Join(words[:descriptionWords], " ") + "..."
+	}
+	return strings.Join(words, " ")
+}
+
+// Function to make urls of images on the blog absolute (e.g. /images/cover.jpg)
+func absoluteUrl(values *structure.RequestData, url []byte) string {
+	if len(url) != 0 && url[0] == '/' && !bytes.HasPrefix(url, []byte("//")) {
+		return string(values.Blog.Url) + string(url)
+	}
+	return string(url)
+}
+
+// Function to get the width and height of an image that is stored on the blog. Returns 0, 0 for other images.
+func imageSize(values *structure.RequestData, url string) (int, int) {
+	url = strings.TrimPrefix(url, string(values.Blog.Url))
+	var folder string
+	if strings.HasPrefix(url, "/images/") {
+		folder = filenames.ImagesFilepath
+	} else if strings.HasPrefix(url, "/public/") {
+		folder = filenames.PublicFilepath
+	} else {
+		return 0, 0
+	}
+	filePath := filepath.Join(folder, filepath.FromSlash(url[strings.Index(url[1:], "/")+2:]))
+	if !strings.HasPrefix(filePath, folder+string(filepath.Separator)) {
+		return 0, 0
+	}
+	imageSizes.RLock()
+	size, ok := imageSizes.m[filePath]
+	imageSizes.RUnlock()
+	if ok {
+		return size[0], size[1]
+	}
+	file, err := os.Open(filePath)
+	if err == nil {
+		config, _, err := image.DecodeConfig(file)
+		file.Close()
+		if err == nil {
+			size = [2]int{config.Width, config.Height}
+		}
+	}
+	// Images that can't be read are cached as well to not open them on every request
+	imageSizes.Lock()
+	imageSizes.m[filePath] = size
+	imageSizes.Unlock()
+	return size[0], size[1]
+}
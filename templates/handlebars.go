@@ -2,7 +2,10 @@ package templates
 
 import (
 	"bytes"
+	"database/sql"
+	"errors"
 	"html"
+	"io"
 	"journey/conversion"
 	"journey/database"
 	"journey/date"
@@ -40,38 +43,97 @@ func nullFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 }
 
 func slugFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	if len(values.Blog.NavigationItems) != 0 {
-		return evaluateEscape([]byte(values.Blog.NavigationItems[values.CurrentNavigationIndex].Slug), helper.Unescaped)
+	if item := currentNavigationItem(values); item != nil {
+		return evaluateEscape([]byte(item.Slug), helper.Unescaped)
 	}
 	return []byte{}
 }
 
 func currentFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if len(values.Blog.NavigationItems) != 0 {
-		currUrl := values.Blog.NavigationItems[values.CurrentNavigationIndex].Url
-		// Since the router rewrites all urls with a trailing slash, add / to url if not already there
-		if !strings.HasSuffix(currUrl, "/") {
-			currUrl = currUrl + "/"
-		}
-		if values.CurrentPath == currUrl {
-			return []byte{1}
-		}
+	if item := currentNavigationItem(values); item != nil && isCurrentUrl(item.Url, values.CurrentPath) {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
-func navigationFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if len(values.Blog.NavigationItems) == 0 {
-		return []byte{}
-	} else if templateHelper, ok := compiledTemplates.m["navigation"]; ok {
-		return executeHelper(templateHelper, values, values.CurrentHelperContext)
+// Function to check if one of the children of a navigation item links to the current page (the item is the parent of the page)
+func currentParentFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if item := currentNavigationItem(values); item != nil && hasCurrentChild(item.Children, values.CurrentPath) {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
+func hasCurrentChild(children []structure.Navigation, path string) bool {
+	for index := range children {
+		if isCurrentUrl(children[index].Url, path) || hasCurrentChild(children[index].Children, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCurrentUrl(url string, path string) bool {
+	// Since the router rewrites all urls with a trailing slash, add / to url if not already there
+	if !strings.HasSuffix(url, "/") {
+		url = url + "/"
+	}
+	return path == url
+}
+
+// Function to get the items of the navigation that is rendered. Outside of {{navigation}} it is the primary navigation.
+func navigationItems(values *structure.RequestData) []structure.Navigation {
+	if values.NavigationItems != nil {
+		return values.NavigationItems
+	}
+	if values.Blog == nil {
+		return nil
+	}
+	return values.Blog.NavigationItems
+}
+
+func currentNavigationItem(values *structure.RequestData) *structure.Navigation {
+	items := navigationItems(values)
+	if values.CurrentNavigationIndex < len(items) {
+		return &items[values.CurrentNavigationIndex]
+	}
+	return nil
+}
+
+// Function to render the navigation partial with the primary navigation or, with type="secondary", the secondary navigation.
+// The partial can check for the secondary navigation with {{#if isSecondary}}.
+func navigationFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	isSecondary := evaluateArguments(helper.Arguments, values)["type"] == "secondary"
+	items := values.Blog.NavigationItems
+	if isSecondary {
+		items = values.Blog.SecondaryNavigationItems
+	}
+	if len(items) == 0 {
+		return []byte{}
+	}
+	templateHelper, ok := lookupPartial("navigation")
+	if !ok {
+		return []byte{}
+	}
+	navigation := values.NavigationItems
+	navigationIndex := values.CurrentNavigationIndex
+	values.NavigationItems = items
+	values.CurrentNavigationIndex = 0
+	defer func() {
+		values.NavigationItems = navigation
+		values.CurrentNavigationIndex = navigationIndex
+	}()
+	dataContext := makeDataContext(values, values.CurrentHelperContext)
+	dataContext.Parameters = map[string]interface{}{"isSecondary": isSecondary}
+	length := len(values.DataContexts)
+	values.DataContexts = append(values.DataContexts, dataContext)
+	defer popDataContext(values, length)
+	return executeHelper(templateHelper, values, values.CurrentHelperContext)
+}
+
 func labelFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	if len(values.Blog.NavigationItems) != 0 {
-		return evaluateEscape([]byte(values.Blog.NavigationItems[values.CurrentNavigationIndex].Label), helper.Unescaped)
+	if item := currentNavigationItem(values); item != nil {
+		return evaluateEscape([]byte(item.Label), helper.Unescaped)
 	}
 	return []byte{}
 }
@@ -101,7 +163,7 @@ func blockFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 }
 
 func paginationFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if templateHelper, ok := compiledTemplates.m["pagination"]; ok {
+	if templateHelper, ok := lookupPartial("pagination"); ok {
 		return executeHelper(templateHelper, values, values.CurrentHelperContext)
 	}
 	return []byte{}
@@ -131,12 +193,12 @@ func paginationDotTotalFunc(_ *structure.Helper, values *structure.RequestData)
 func pluralFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	if len(helper.Arguments) != 0 {
 		// Get the number calculated by executing the first argument
-		countString := string(helper.Arguments[0].Function(helper, values))
+		countString := string(helper.Arguments[0].Function(&helper.Arguments[0], values))
 		if countString == "" {
 			log.Println("Couldn't get count in plural helper")
 			return []byte{}
 		}
-		arguments := methods.ProcessHelperArguments(helper.Arguments)
+		arguments := evaluateArguments(helper.Arguments, values)
 		for key, value := range arguments {
 			if countString == "0" && key == "empty" {
 				output := value
@@ -181,7 +243,7 @@ func nextFunc(_ *structure.Helper, values *structure.RequestData) []byte {
 			return []byte{}
 		}
 	}
-	maxPages := positiveCeilingInt64(float64(count) / float64(values.Blog.PostsPerPage))
+	maxPages := positiveCeilingInt64(float64(count) / float64(postsPerPage(values.Blog)))
 	if int64(values.CurrentIndexPage) < maxPages {
 		return []byte{1}
 	}
@@ -210,7 +272,7 @@ func pagesFunc(_ *structure.Helper, values *structure.RequestData) []byte {
 			return []byte{}
 		}
 	}
-	maxPages := positiveCeilingInt64(float64(count) / float64(values.Blog.PostsPerPage))
+	maxPages := positiveCeilingInt64(float64(count) / float64(postsPerPage(values.Blog)))
 	// Output at least 1 (even if there are no posts in the database)
 	if maxPages == 0 {
 		maxPages = 1
@@ -271,7 +333,7 @@ func pageUrlFunc(helper *structure.Helper, values *structure.RequestData) []byte
 					return []byte{}
 				}
 			}
-			maxPages := positiveCeilingInt64(float64(count) / float64(values.Blog.PostsPerPage))
+			maxPages := positiveCeilingInt64(float64(count) / float64(postsPerPage(values.Blog)))
 			if int64(values.CurrentIndexPage) < maxPages {
 				var buffer bytes.Buffer
 				if values.CurrentTemplate == 3 { // author
@@ -352,21 +414,9 @@ func bodyClassFunc(_ *structure.Helper, values *structure.RequestData) []byte {
 	return []byte("post-template")
 }
 
-func ghostHeadFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	// SEO stuff:
-	// Output canonical url
-	var buffer bytes.Buffer
-	buffer.WriteString("<link rel=\"canonical\" href=\"")
-	buffer.Write(evaluateEscape(values.Blog.Url, helper.Unescaped))
-	buffer.WriteString(values.CurrentPath)
-	buffer.WriteString("\">")
-	// TODO: structured data
-	return buffer.Bytes()
-}
-
-func ghostFootFunc(_ *structure.Helper, _ *structure.RequestData) []byte {
-	// TODO: customized code injection
-	return []byte{}
+// Outputs the Content-Security-Policy nonce of the request, e.g. <script nonce="{{csp_nonce}}">
+func cspNonceFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	return []byte(values.CspNonce)
 }
 
 func metaTitleFunc(helper *structure.Helper, values *structure.RequestData) []byte {
@@ -402,17 +452,24 @@ func metaDescriptionFunc(helper *structure.Helper, values *structure.RequestData
 	return []byte{}
 }
 
-func bodyFunc(helper *structure.Helper, _ *structure.RequestData) []byte {
-	return helper.Block
+func bodyFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	return values.Body
 }
 
 func insertFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	if len(helper.Arguments) != 0 {
-		if templateHelper, ok := compiledTemplates.m[helper.Arguments[0].Name]; ok {
-			return executeHelper(templateHelper, values, values.CurrentHelperContext)
-		}
-	}
-	return []byte{}
+	return executePartial(helper, values, nil)
+}
+
+func writeInsert(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	return writePartial(writer, helper, values, nil)
+}
+
+func insertBlockFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	return executePartial(helper, values, helper)
+}
+
+func writeInsertBlock(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	return writePartial(writer, helper, values, helper)
 }
 
 func encodeFunc(helper *structure.Helper, values *structure.RequestData) []byte {
@@ -428,7 +485,7 @@ func authorFunc(helper *structure.Helper, values *structure.RequestData) []byte
 		return executeHelper(helper, values, 3) // context = author
 	}
 	// Else return author name (as link)
-	arguments := methods.ProcessHelperArguments(helper.Arguments)
+	arguments := evaluateArguments(helper.Arguments, values)
 	for key, value := range arguments {
 		// If link is set to false, just return the name
 		if key == "autolink" && value == "false" {
@@ -496,6 +553,70 @@ func postFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	return executeHelper(helper, values, 1) // context = post
 }
 
+func writePost(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	return executeTemplate(writer, helper, values, 1) // context = post
+}
+
+func nextPostFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	return adjacentPost(helper, values, true)
+}
+
+func prevPostFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	return adjacentPost(helper, values, false)
+}
+
+// Function to execute the block of next_post or prev_post with the post that was published after or before the
+// current post. With in="primary_tag" or in="author", only posts with the same primary tag or author are used.
+func adjacentPost(helper *structure.Helper, values *structure.RequestData, next bool) []byte {
+	var adjacent *structure.Post
+	if values.CurrentPostIndex < len(values.Posts) && values.Posts[values.CurrentPostIndex].Date != nil {
+		post := &values.Posts[values.CurrentPostIndex]
+		var userId, primaryTagId int64
+		var err error
+		switch in := evaluateArguments(helper.Arguments, values)["in"]; in {
+		case "":
+		case "primary_tag":
+			if len(post.Tags) != 0 {
+				primaryTagId = post.Tags[0].Id
+			} else {
+				err = sql.ErrNoRows
+			}
+		case "author":
+			if post.Author != nil {
+				userId = post.Author.Id
+			} else {
+				err = sql.ErrNoRows
+			}
+		default:
+			err = errors.New("unknown value for in: " + in + ", only primary_tag and author are supported")
+		}
+		if err == nil {
+			adjacent, err = database.RetrieveAdjacentPost(post, next, userId, primaryTagId)
+		}
+		if err != nil && err != sql.ErrNoRows {
+			log.Println("Couldn't get "+helper.Name+":", err)
+		}
+	}
+	if adjacent == nil {
+		// Else execute the else helper which is always at the last index of the helper Arguments
+		if len(helper.Arguments) != 0 && helper.Arguments[len(helper.Arguments)-1].Name == "else" {
+			elseHelper := helper.Arguments[len(helper.Arguments)-1]
+			return executeHelper(&elseHelper, values, values.CurrentHelperContext)
+		}
+		return []byte{}
+	}
+	// Replace the posts of the request with the adjacent post while the block is executed
+	posts := values.Posts
+	postIndex := values.CurrentPostIndex
+	defer func() {
+		values.Posts = posts
+		values.CurrentPostIndex = postIndex
+	}()
+	values.Posts = []structure.Post{*adjacent}
+	values.CurrentPostIndex = 0
+	return executeHelper(helper, values, 1) // context = post
+}
+
 func postsFunc(_ *structure.Helper, values *structure.RequestData) []byte {
 	if len(values.Posts) > 0 {
 		return []byte{1}
@@ -510,7 +631,7 @@ func tagsFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 		prefix := ""
 		makeLink := true
 		if len(helper.Arguments) != 0 {
-			arguments := methods.ProcessHelperArguments(helper.Arguments)
+			arguments := evaluateArguments(helper.Arguments, values)
 			for key, value := range arguments {
 				if key == "separator" {
 					separator = value
@@ -573,12 +694,12 @@ func postClassFunc(helper *structure.Helper, values *structure.RequestData) []by
 func urlFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	var buffer bytes.Buffer
 	if len(helper.Arguments) != 0 {
-		arguments := methods.ProcessHelperArguments(helper.Arguments)
+		arguments := evaluateArguments(helper.Arguments, values)
 		for key, value := range arguments {
 			if key == "absolute" {
 				if value == "true" {
-					// Only write the blog url if navigation url does not begin with http/https
-					if values.CurrentHelperContext == 4 && (!strings.HasPrefix(values.Blog.NavigationItems[values.CurrentNavigationIndex].Url, "http://") && !strings.HasPrefix(values.Blog.NavigationItems[values.CurrentNavigationIndex].Url, "https://")) { // navigation
+					// Only write the blog url if the navigation url is relative to the blog (not http/https, mailto:, tel: or #anchor)
+					if item := currentNavigationItem(values); values.CurrentHelperContext == 4 && item != nil && strings.HasPrefix(item.Url, "/") { // navigation
 						buffer.Write(values.Blog.Url)
 					} else if values.CurrentHelperContext != 4 {
 						buffer.Write(values.Blog.Url)
@@ -592,14 +713,19 @@ func urlFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 		buffer.WriteString(values.Posts[values.CurrentPostIndex].Slug)
 		buffer.WriteString("/")
 		return evaluateEscape(buffer.Bytes(), helper.Unescaped)
+	} else if values.CurrentHelperContext == 2 { // tag
+		buffer.WriteString("/tag/")
+		buffer.WriteString(values.Posts[values.CurrentPostIndex].Tags[values.CurrentTagIndex].Slug)
+		buffer.WriteString("/")
+		return evaluateEscape(buffer.Bytes(), helper.Unescaped)
 	} else if values.CurrentHelperContext == 3 { // author
 		buffer.WriteString("/author/")
 		// TODO: Error handling if there is no Posts[values.CurrentPostIndex]
 		buffer.WriteString(values.Posts[values.CurrentPostIndex].Author.Slug)
 		buffer.WriteString("/")
 		return evaluateEscape(buffer.Bytes(), helper.Unescaped)
-	} else if values.CurrentHelperContext == 4 { // navigation
-		buffer.WriteString(values.Blog.NavigationItems[values.CurrentNavigationIndex].Url)
+	} else if item := currentNavigationItem(values); values.CurrentHelperContext == 4 && item != nil { // navigation
+		buffer.WriteString(item.Url)
 		return evaluateEscape(buffer.Bytes(), helper.Unescaped)
 	}
 	return []byte{}
@@ -617,7 +743,7 @@ func contentFunc(_ *structure.Helper, values *structure.RequestData) []byte {
 func excerptFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	if values.CurrentHelperContext == 1 { // post
 		if len(helper.Arguments) != 0 {
-			arguments := methods.ProcessHelperArguments(helper.Arguments)
+			arguments := evaluateArguments(helper.Arguments, values)
 			for key, value := range arguments {
 				if key == "words" {
 					number, err := strconv.Atoi(value)
@@ -662,95 +788,94 @@ func dateFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	}
 	// Get the date
 	if len(helper.Arguments) != 0 {
-		arguments := methods.ProcessHelperArguments(helper.Arguments)
+		arguments := evaluateArguments(helper.Arguments, values)
 		for key, value := range arguments {
 			if key == "published_at" {
 				showPublicationDate = true
 			} else if key == "timeago" {
 				if value == "true" {
 					// Compute time ago
-					return evaluateEscape(date.GenerateTimeAgo(values.Posts[values.CurrentPostIndex].Date), helper.Unescaped)
+					return evaluateEscape(date.GenerateTimeAgoInLocale(values.Posts[values.CurrentPostIndex].Date, blogLocale(values.Blog)), helper.Unescaped)
 				}
 			} else if key == "format" {
 				timeFormat = value
 			}
 		}
 	}
+	currentDate := date.GetCurrentTime()
 	if showPublicationDate {
-		return evaluateEscape(date.FormatDate(timeFormat, values.Posts[values.CurrentPostIndex].Date), helper.Unescaped)
+		currentDate = *values.Posts[values.CurrentPostIndex].Date
 	}
-	currentDate := date.GetCurrentTime()
-	return evaluateEscape(date.FormatDate(timeFormat, &currentDate), helper.Unescaped)
+	// Show the date in the timezone and language of the blog
+	currentDate = blogTime(values.Blog, currentDate)
+	return evaluateEscape(date.FormatDateInLocale(timeFormat, &currentDate, blogLocale(values.Blog)), helper.Unescaped)
 }
 
 func atFirstFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if values.CurrentHelperContext == 1 { // post
-		if values.CurrentPostIndex == 0 {
-			return []byte{1}
-		}
-		return []byte{}
-	}
-	if values.CurrentHelperContext == 2 { // tag
-		if values.CurrentTagIndex == 0 {
-			return []byte{1}
-		}
-		return []byte{}
+	if loop := currentLoop(values); loop != nil && loop.Index == loop.First {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
 func atLastFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if values.CurrentHelperContext == 1 { // post
-		if values.CurrentPostIndex == (len(values.Posts) - 1) {
-			return []byte{1}
-		}
-		return []byte{}
-	}
-	if values.CurrentHelperContext == 2 { // tag
-		if values.CurrentTagIndex == (len(values.Posts[values.CurrentPostIndex].Tags) - 1) {
-			return []byte{1}
-		}
-		return []byte{}
+	if loop := currentLoop(values); loop != nil && loop.Index == loop.Last {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
 func atEvenFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if values.CurrentHelperContext == 1 { // post
-		// First post (index 0) needs to be odd
-		if values.CurrentPostIndex%2 == 1 {
-			return []byte{1}
-		}
-		return []byte{}
-	}
-	if values.CurrentHelperContext == 2 { // tag
-		// First tag (index 0) needs to be odd
-		if values.CurrentTagIndex%2 == 1 {
-			return []byte{1}
-		}
-		return []byte{}
+	// First item (index 0) needs to be odd
+	if loop := currentLoop(values); loop != nil && loop.Index%2 == 1 {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
 func atOddFunc(_ *structure.Helper, values *structure.RequestData) []byte {
-	if values.CurrentHelperContext == 1 { // post
-		// First post (index 0) needs to be odd
-		if values.CurrentPostIndex%2 == 0 {
-			return []byte{1}
-		}
-		return []byte{}
+	if loop := currentLoop(values); loop != nil && loop.Index%2 == 0 {
+		return []byte{1}
 	}
-	if values.CurrentHelperContext == 2 { // tag
-		// First tag (index 0) needs to be odd
-		if values.CurrentTagIndex%2 == 0 {
-			return []byte{1}
-		}
-		return []byte{}
+	return []byte{}
+}
+
+func atIndexFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if loop := currentLoop(values); loop != nil {
+		return []byte(strconv.Itoa(loop.Index))
+	}
+	return []byte{}
+}
+
+func atNumberFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if loop := currentLoop(values); loop != nil {
+		return []byte(strconv.Itoa(loop.Index + 1))
+	}
+	return []byte{}
+}
+
+func atRowStartFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if loop := currentLoop(values); loop != nil && loop.Columns != 0 && (loop.Index-loop.First)%loop.Columns == 0 {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+func atRowEndFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if loop := currentLoop(values); loop != nil && loop.Columns != 0 && (loop.Index-loop.First)%loop.Columns == loop.Columns-1 {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
+// Function to get the innermost foreach helper that is executing. Returns nil outside of loops.
+func currentLoop(values *structure.RequestData) *structure.LoopState {
+	if len(values.Loops) == 0 {
+		return nil
+	}
+	return &values.Loops[len(values.Loops)-1]
+}
+
 func nameFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	// If tag (commented out the code for generating a link. Ghost doesn't seem to do that either).
 	if values.CurrentHelperContext == 2 { // tag
@@ -777,6 +902,15 @@ func nameFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	return evaluateEscape(values.Posts[values.CurrentPostIndex].Author.Name, helper.Unescaped)
 }
 
+func countDotPostsFunc(_ *structure.Helper, values *structure.RequestData) []byte {
+	if values.CurrentHelperContext == 2 { // tag
+		return []byte(strconv.FormatInt(values.Posts[values.CurrentPostIndex].Tags[values.CurrentTagIndex].PostCount, 10))
+	} else if values.CurrentHelperContext == 3 { // author
+		return []byte(strconv.FormatInt(values.Posts[values.CurrentPostIndex].Author.PostCount, 10))
+	}
+	return []byte{}
+}
+
 func tagDotNameFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	if len(values.CurrentTag.Name) != 0 {
 		return evaluateEscape(values.CurrentTag.Name, helper.Unescaped)
@@ -808,67 +942,379 @@ func assetFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 }
 
 func foreachFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	if len(helper.Arguments) != 0 {
+	return bufferOutput(func(writer io.Writer) error {
+		return writeForeach(writer, helper, values)
+	})
+}
+
+func writeForeach(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	if len(helper.Arguments) == 0 {
+		return nil
+	}
+	// Set the indexes back once the loop is done. Nested loops (e.g. tags inside of posts) must not change the item of the outer loop.
+	postIndex := values.CurrentPostIndex
+	tagIndex := values.CurrentTagIndex
+	navigationIndex := values.CurrentNavigationIndex
+	navigation := values.NavigationItems
+	defer func() {
+		values.CurrentPostIndex = postIndex
+		values.CurrentTagIndex = tagIndex
+		values.CurrentNavigationIndex = navigationIndex
+		values.NavigationItems = navigation
+	}()
+	var length int
+	var context int
+	var setIndex func(int)
+	switch helper.Arguments[0].Name {
+	case "posts":
+		length = len(values.Posts)
+		context = 1 // post
+		setIndex = func(index int) { values.CurrentPostIndex = index }
+	case "tags":
+		if values.CurrentPostIndex < len(values.Posts) {
+			length = len(values.Posts[values.CurrentPostIndex].Tags)
+		}
+		context = 2 // tag
+		setIndex = func(index int) { values.CurrentTagIndex = index }
+	case "authors":
+		context = 3 // author
+		// Posts only have one author. Outside of a post (e.g. in {{#get "authors"}}) the authors of all posts are iterated.
+		if values.CurrentHelperContext == 1 { // post
+			length = 1
+			setIndex = func(int) {}
+		} else {
+			length = len(values.Posts)
+			setIndex = func(index int) { values.CurrentPostIndex = index }
+		}
+	case "navigation", "secondary_navigation", "children":
+		var items []structure.Navigation
 		switch helper.Arguments[0].Name {
-		case "posts":
-			var buffer bytes.Buffer
-			for index := range values.Posts {
-				//if values.Posts[index].Id != 0 { // If post is not empty (Commented out for now. This was only neccessary in previous versions, when the array length was always the postsPerPage length)
-				values.CurrentPostIndex = index
-				buffer.Write(executeHelper(helper, values, 1)) // context = post
-				//}
+		case "navigation":
+			items = navigationItems(values)
+		case "secondary_navigation":
+			items = values.Blog.SecondaryNavigationItems
+		case "children":
+			// Children of the current navigation item (drop-down menus)
+			if item := currentNavigationItem(values); values.CurrentHelperContext == 4 && item != nil {
+				items = item.Children
 			}
-			return buffer.Bytes()
-		case "tags":
-			var buffer bytes.Buffer
-			for index := range values.Posts[values.CurrentPostIndex].Tags {
-				//if values.Posts[values.CurrentPostIndex].Tags[index].Id != 0 { // If tag is not empty (Commented out for now. Not neccessary.)
-				values.CurrentTagIndex = index
-				buffer.Write(executeHelper(helper, values, 2)) // context = tag
-				//}
+		}
+		values.NavigationItems = items
+		length = len(items)
+		context = 4 // navigation
+		setIndex = func(index int) { values.CurrentNavigationIndex = index }
+	default:
+		return nil
+	}
+	loop, err := makeLoopState(evaluateArguments(helper.Arguments[1:], values), length)
+	if err != nil {
+		log.Println("Couldn't execute foreach:", err)
+		return nil
+	}
+	if loop.First > loop.Last {
+		// Else execute the else helper which is always at the last index of the foreach helper Arguments
+		if elseHelper := &helper.Arguments[len(helper.Arguments)-1]; elseHelper.Name == "else" {
+			return executeTemplate(writer, elseHelper, values, values.CurrentHelperContext)
+		}
+		return nil
+	}
+	values.Loops = append(values.Loops, loop)
+	defer func() {
+		values.Loops = values.Loops[:len(values.Loops)-1]
+	}()
+	for index := loop.First; index <= loop.Last; index++ {
+		values.Loops[len(values.Loops)-1].Index = index
+		setIndex(index)
+		err = executeTemplate(writer, helper, values, context)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to get the range of items a foreach helper renders from its from, to and limit options (from and to start at 1)
+func makeLoopState(arguments map[string]string, length int) (structure.LoopState, error) {
+	loop := structure.LoopState{First: 0, Last: length - 1}
+	for _, option := range []string{"from", "to", "limit", "columns"} {
+		value, ok := arguments[option]
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			return loop, errors.New("invalid " + option + ": " + value)
+		}
+		switch option {
+		case "from":
+			loop.First = number - 1
+		case "to":
+			if number-1 < loop.Last {
+				loop.Last = number - 1
 			}
-			return buffer.Bytes()
-		case "navigation":
-			var buffer bytes.Buffer
-			for index := range values.Blog.NavigationItems {
-				values.CurrentNavigationIndex = index
-				buffer.Write(executeHelper(helper, values, 4)) // context = navigation
+		case "limit":
+			if loop.First+number-1 < loop.Last {
+				loop.Last = loop.First + number - 1
 			}
-			return buffer.Bytes()
-		default:
-			return []byte{}
+		case "columns":
+			loop.Columns = number
 		}
 	}
-	return []byte{}
+	return loop, nil
 }
 
 func ifFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	if len(helper.Arguments) != 0 {
-		if len(helper.Arguments[0].Function(&helper.Arguments[0], values)) != 0 {
-			// If the evaluation is true, execute the if helper
-			return executeHelper(helper, values, values.CurrentHelperContext)
-		} else {
-			// Else execute the else helper which is always at the last index of the if helper Arguments
-			if helper.Arguments[len(helper.Arguments)-1].Name == "else" {
-				if len(helper.Arguments[len(helper.Arguments)-1].Children) != 0 {
+		return executeConditional(helper, values, len(helper.Arguments[0].Function(&helper.Arguments[0], values)) != 0)
+	}
+	return []byte{}
+}
+
+func writeIf(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	if len(helper.Arguments) != 0 {
+		return writeConditional(writer, helper, values, len(helper.Arguments[0].Function(&helper.Arguments[0], values)) != 0)
+	}
+	return nil
+}
+
+func unlessFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	if len(helper.Arguments) != 0 {
+		return executeConditional(helper, values, len(helper.Arguments[0].Function(&helper.Arguments[0], values)) == 0)
+	}
+	return []byte{}
+}
+
+func writeUnless(writer io.Writer, helper *structure.Helper, values *structure.RequestData) error {
+	if len(helper.Arguments) != 0 {
+		return writeConditional(writer, helper, values, len(helper.Arguments[0].Function(&helper.Arguments[0], values)) == 0)
+	}
+	return nil
+}
+
+func hasFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	if values.CurrentPostIndex >= len(values.Posts) {
+		return executeConditional(helper, values, false)
+	}
+	post := &values.Posts[values.CurrentPostIndex]
+	arguments := evaluateArguments(helper.Arguments, values)
+	// The block is executed if any of the attributes matches
+	for key, value := range arguments {
+		switch key {
+		case "tag":
+			for _, tag := range post.Tags {
+				if listContains(value, string(tag.Name)) || listContains(value, tag.Slug) {
+					return executeConditional(helper, values, true)
 				}
-				return executeHelper(&helper.Arguments[len(helper.Arguments)-1], values, values.CurrentHelperContext)
 			}
+		case "author":
+			if post.Author != nil && (listContains(value, string(post.Author.Name)) || listContains(value, post.Author.Slug)) {
+				return executeConditional(helper, values, true)
+			}
+		case "slug":
+			if listContains(value, post.Slug) {
+				return executeConditional(helper, values, true)
+			}
+		case "id":
+			if listContains(value, strconv.FormatInt(post.Id, 10)) {
+				return executeConditional(helper, values, true)
+			}
+		case "number":
+			if matchesPosition(value, currentLoopIndex(values)+1) {
+				return executeConditional(helper, values, true)
+			}
+		case "index":
+			if matchesPosition(value, currentLoopIndex(values)) {
+				return executeConditional(helper, values, true)
+			}
+		}
+	}
+	return executeConditional(helper, values, false)
+}
+
+func isFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	if len(helper.Arguments) == 0 {
+		return []byte{}
+	}
+	for _, context := range strings.Split(helper.Arguments[0].Name, ",") {
+		isContext := false
+		switch strings.TrimSpace(context) {
+		case "home":
+			isContext = values.CurrentTemplate == 0 && values.CurrentIndexPage <= 1
+		case "index":
+			isContext = values.CurrentTemplate == 0
+		case "paged":
+			isContext = values.CurrentTemplate != 1 && values.CurrentIndexPage > 1
+		case "post":
+			isContext = values.CurrentTemplate == 1 && len(values.Posts) != 0 && !values.Posts[0].IsPage
+		case "page":
+			isContext = values.CurrentTemplate == 1 && len(values.Posts) != 0 && values.Posts[0].IsPage
+		case "tag":
+			isContext = values.CurrentTemplate == 2
+		case "author":
+			isContext = values.CurrentTemplate == 3
+		}
+		if isContext {
+			return executeConditional(helper, values, true)
 		}
 	}
+	return executeConditional(helper, values, false)
+}
+
+func matchFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	arguments := helper.Arguments
+	if len(arguments) != 0 && arguments[len(arguments)-1].Name == "else" {
+		arguments = arguments[:len(arguments)-1]
+	}
+	switch len(arguments) {
+	case 1:
+		// {{#match value}} checks if the value is not empty
+		return executeConditional(helper, values, len(arguments[0].Function(&arguments[0], values)) != 0)
+	case 2:
+		// {{#match value other}} checks if both values are equal
+		left := string(arguments[0].Function(&arguments[0], values))
+		right := string(arguments[1].Function(&arguments[1], values))
+		return executeConditional(helper, values, left == right)
+	case 3:
+		left := string(arguments[0].Function(&arguments[0], values))
+		right := string(arguments[2].Function(&arguments[2], values))
+		return executeConditional(helper, values, compareValues(left, arguments[1].Name, right))
+	}
 	return []byte{}
 }
 
-func unlessFunc(helper *structure.Helper, values *structure.RequestData) []byte {
-	if len(helper.Arguments) != 0 {
-		if len(helper.Arguments[0].Function(&helper.Arguments[0], values)) == 0 {
-			// If the evaluation is false, execute the unless helper
-			return executeHelper(helper, values, values.CurrentHelperContext)
+// Function to execute a conditional block helper or its else branch, which is always the last argument of the helper
+func executeConditional(helper *structure.Helper, values *structure.RequestData, condition bool) []byte {
+	// Used as subexpression (e.g. {{#if (match title "Hello")}}). Only the condition is returned.
+	if helper.Subexpression {
+		if condition {
+			return []byte{1}
 		}
+		return []byte{}
+	}
+	return bufferOutput(func(writer io.Writer) error {
+		return writeConditional(writer, helper, values, condition)
+	})
+}
+
+// Function to write the block of a helper if the condition is true, or its else block if there is one
+func writeConditional(writer io.Writer, helper *structure.Helper, values *structure.RequestData, condition bool) error {
+	if condition {
+		return executeTemplate(writer, helper, values, values.CurrentHelperContext)
+	} else if len(helper.Arguments) != 0 && helper.Arguments[len(helper.Arguments)-1].Name == "else" {
+		return executeTemplate(writer, &helper.Arguments[len(helper.Arguments)-1], values, values.CurrentHelperContext)
+	}
+	return nil
+}
+
+func compareValues(left string, operator string, right string) bool {
+	switch operator {
+	case "=":
+		return left == right
+	case "!=":
+		return left != right
+	case "~":
+		return strings.Contains(left, right)
+	case "~^":
+		return strings.HasPrefix(left, right)
+	case "~$":
+		return strings.HasSuffix(left, right)
+	case "<", "<=", ">", ">=":
+		comparison := strings.Compare(left, right)
+		// Compare numbers by value
+		leftNumber, leftErr := strconv.ParseFloat(left, 64)
+		rightNumber, rightErr := strconv.ParseFloat(right, 64)
+		if leftErr == nil && rightErr == nil {
+			comparison = 0
+			if leftNumber < rightNumber {
+				comparison = -1
+			} else if leftNumber > rightNumber {
+				comparison = 1
+			}
+		}
+		switch operator {
+		case "<":
+			return comparison < 0
+		case "<=":
+			return comparison <= 0
+		case ">":
+			return comparison > 0
+		default:
+			return comparison >= 0
+		}
+	}
+	return false
+}
+
+// Function to check if a comma separated list (e.g. "news, Tech") contains a value. Not case sensitive.
+func listContains(list string, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check a position against a list like "1, 3" or "nth:2" (every second)
+func matchesPosition(list string, position int) bool {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(item, "nth:") {
+			if step, err := strconv.Atoi(item[len("nth:"):]); err == nil && step > 0 && position%step == 0 {
+				return true
+			}
+		} else if number, err := strconv.Atoi(item); err == nil && number == position {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to get the index of the current item of a foreach helper
+func currentLoopIndex(values *structure.RequestData) int {
+	if loop := currentLoop(values); loop != nil {
+		return loop.Index
+	}
+	switch values.CurrentHelperContext {
+	case 2: // tag
+		return values.CurrentTagIndex
+	case 4: // navigation
+		return values.CurrentNavigationIndex
+	default:
+		return values.CurrentPostIndex
+	}
+}
+
+// Function to put the arguments of a helper into a map like methods.ProcessHelperArguments. Hash arguments with
+// a path or subexpression as value (e.g. format=dateFormat or limit=(plural count)) are evaluated.
+func evaluateArguments(arguments []structure.Helper, values *structure.RequestData) map[string]string {
+	argumentsMap := methods.ProcessHelperArguments(arguments)
+	for index := range arguments {
+		if len(arguments[index].Arguments) != 1 || !strings.Contains(arguments[index].Name, "=") {
+			continue
+		}
+		value := &arguments[index].Arguments[0]
+		result := value.Function(value, values)
+		// Conditions evaluate to []byte{1}
+		if isCondition(result) {
+			result = []byte("true")
+		}
+		argumentsMap[strings.SplitN(arguments[index].Name, "=", 2)[0]] = string(result)
+	}
+	return argumentsMap
+}
+
+func booleanLiteralFunc(helper *structure.Helper, _ *structure.RequestData) []byte {
+	if helper.Name == "true" {
+		return []byte{1}
 	}
 	return []byte{}
 }
 
+func literalFunc(helper *structure.Helper, _ *structure.RequestData) []byte {
+	return []byte(helper.Name)
+}
+
 func atBlogDotTitleFunc(helper *structure.Helper, values *structure.RequestData) []byte {
 	return evaluateEscape(values.Blog.Title, helper.Unescaped)
 }
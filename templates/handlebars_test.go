@@ -0,0 +1,563 @@
+package templates
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"journey/configuration"
+	"journey/database"
+	"journey/filenames"
+	"journey/security"
+	"journey/structure"
+)
+
+// Function to compile a template and execute it in the index context
+func renderTemplate(t *testing.T, template string, values structure.RequestData) string {
+	t.Helper()
+	helper, err := compileTemplate([]byte(template), "test", "test.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(executeHelper(helper, &values, 0))
+}
+
+// Function to use a new database in a temporary folder. The returned function restores the previous paths.
+func useTestDatabase(t *testing.T) func() {
+	t.Helper()
+	databasePath, err := ioutil.TempDir("", "journey-database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseFilepath, databaseFilename := filenames.DatabaseFilepath, filenames.DatabaseFilename
+	filenames.DatabaseFilepath, filenames.DatabaseFilename = databasePath, filepath.Join(databasePath, "journey.db")
+	restore := func() {
+		filenames.DatabaseFilepath, filenames.DatabaseFilename = databaseFilepath, databaseFilename
+		os.RemoveAll(databasePath)
+	}
+	if err := database.Initialize(); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return restore
+}
+
+// Function to insert users with the given names (which are also used as slug) into the test database
+func insertTestUsers(t *testing.T, names ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, len(names))
+	for index, name := range names {
+		var err error
+		ids[index], err = database.InsertUser([]byte(name), name, "", []byte(name+"@example.com"), []byte{}, []byte{}, time.Now(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// Function to insert tags with the given names (which are also used as slug) into the test database
+func insertTestTags(t *testing.T, names ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, len(names))
+	for index, name := range names {
+		var err error
+		ids[index], err = database.InsertTag([]byte(name), name, time.Now(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// Function to insert a post into the test database. The first tag is the primary tag.
+func insertTestPost(t *testing.T, title string, published time.Time, isPublished bool, featured bool, userId int64, tagIds ...int64) int64 {
+	t.Helper()
+	postId, err := database.InsertPost([]byte(title), strings.ToLower(title), []byte{}, []byte{}, featured, false, isPublished, []byte{}, []byte{}, []byte{}, []byte{}, "", published, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tagId := range tagIds {
+		if err := database.InsertPostTag(postId, tagId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return postId
+}
+
+// Function to replace the compiled templates with an empty set. The returned function restores them.
+func replaceCompiledTemplates() func() {
+	templates := compiledTemplates
+	compiledTemplates = newTemplates()
+	return func() {
+		compiledTemplates = templates
+	}
+}
+
+func TestConditionalHelpers(t *testing.T) {
+	posts := []structure.Post{
+		{Id: 1, Title: []byte("One"), Slug: "one", Tags: []structure.Tag{{Name: []byte("News"), Slug: "news"}}, Author: &structure.User{Name: []byte("Jane Doe"), Slug: "jane"}},
+		{Id: 2, Title: []byte("Two"), Slug: "two", Author: &structure.User{Name: []byte("John"), Slug: "john"}},
+		{Id: 3, Title: []byte("Three"), Slug: "three", Author: &structure.User{Name: []byte("John"), Slug: "john"}},
+	}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#foreach posts}}{{#has tag="tech, news"}}{{title}}{{else}}-{{/has}}{{/foreach}}`, "One--"},
+		{`{{#foreach posts}}{{#has author="jane"}}{{title}}{{/has}}{{/foreach}}`, "One"},
+		{`{{#foreach posts}}{{#has number="nth:2"}}{{title}}{{/has}}{{#has index="0, 2"}}[{{id}}]{{/has}}{{/foreach}}`, "[1]Two[3]"},
+		{`{{#is "post, page"}}post{{else}}not{{/is}} {{#is "home"}}home{{/is}}{{#is "index"}}index{{/is}}`, "not homeindex"},
+		{`{{#foreach posts}}{{#match title "~^" "T"}}{{title}}{{else}}-{{/match}}{{/foreach}}`, "-TwoThree"},
+		{`{{#foreach posts}}{{#match id ">=" 2}}{{id}}{{/match}}{{#match id 1}}!{{/match}}{{/foreach}}`, "!23"},
+		{`{{#match "a" "!=" "a"}}yes{{else if title}}{{title}}{{/match}}`, "One"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts, CurrentIndexPage: 1, CurrentTemplate: 0}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestForeachHelper(t *testing.T) {
+	posts := []structure.Post{
+		{Id: 1, Tags: []structure.Tag{{Slug: "a"}, {Slug: "b"}}},
+		{Id: 2},
+		{Id: 3, Tags: []structure.Tag{{Slug: "c"}}},
+		{Id: 4},
+		{Id: 5},
+	}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#foreach posts limit="2"}}{{id}}{{/foreach}}`, "12"},
+		{`{{#foreach posts from="2" to="4"}}{{@index}}:{{@number}}{{#if @first}}F{{/if}}{{#if @last}}L{{/if}} {{/foreach}}`, "1:2F 2:3 3:4L "},
+		{`{{#foreach posts columns="2"}}{{#if @rowStart}}<{{/if}}{{id}}{{#if @rowEnd}}>{{/if}}{{/foreach}}`, "<12><34><5"},
+		{`{{#foreach posts}}{{#if @odd}}o{{/if}}{{#if @even}}e{{/if}}{{/foreach}}`, "oeoeo"},
+		{`{{#foreach posts}}{{#foreach tags}}{{@index}}{{/foreach}}{{id}}{{@index}} {{/foreach}}`, "0110 21 032 43 54 "},
+		{`{{#foreach posts from="6"}}{{id}}{{else}}none{{/foreach}}`, "none"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestArgumentEvaluation(t *testing.T) {
+	posts := []structure.Post{
+		{Id: 1, Title: []byte("Hello & welcome"), IsFeatured: true},
+		{Id: 2, Title: []byte("Second")},
+	}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#foreach posts}}{{#if (match title "~^" "Hello")}}{{id}}{{/if}}{{/foreach}}`, "1"},
+		{`{{#foreach posts}}{{#unless (match id 1)}}{{id}}{{else}}-{{/unless}}{{/foreach}}`, "-2"},
+		{`{{#foreach posts}}{{#match featured true}}f{{else if (match id "=" 2)}}two{{else}}x{{/match}}{{/foreach}}`, "ftwo"},
+		{`{{#if false}}a{{else unless true}}b{{else}}c{{/if}}`, "c"},
+		{`{{#foreach posts}}{{#foreach posts limit=id}}{{id}}{{/foreach}};{{/foreach}}`, "1;12;"},
+		{`{{encode "a b"}} {{plural 1 singular="% post" plural="% posts"}}`, "a+b 1 post"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestPathResolution(t *testing.T) {
+	author := structure.User{Name: []byte("Jane"), Slug: "jane", Website: []byte("https://example.com")}
+	posts := []structure.Post{
+		{Id: 1, Title: []byte("First"), Slug: "first", Author: &author, Tags: []structure.Tag{{Name: []byte("News"), Slug: "news"}}},
+		{Id: 2, Title: []byte("Second"), Slug: "second"},
+	}
+	blog := structure.Blog{Title: []byte("My <Blog>")}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#foreach posts}}{{this.title}}:{{post.url}},{{/foreach}}`, "First:/first/,Second:/second/,"},
+		{`{{#foreach posts}}{{#foreach tags}}{{name}}/{{../title}}{{/foreach}}{{/foreach}}`, "News/First"},
+		{`{{#foreach posts}}{{primary_author.website}}{{primary_tag.slug}};{{/foreach}}`, "https://example.comnews;;"},
+		{`{{#foreach posts}}{{#if post.primary_tag}}{{id}}{{/if}}{{/foreach}}`, "1"},
+		// Objects are only used as conditions and render as empty
+		{`{{#foreach posts}}[{{this}}]{{/foreach}}`, "[][]"},
+		{`{{#foreach posts}}[{{primary_author}}]{{/foreach}}`, "[][]"},
+		{`{{@site.title}} {{{@blog.title}}}`, "My &lt;Blog&gt; My <Blog>"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts, Blog: &blog}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestPartials(t *testing.T) {
+	partials := map[string]string{
+		"post/card": `<{{size}}:{{post.title}}:{{title}}>`,
+		"wrapper":   `[{{> @partial-block}}]`,
+	}
+	defer replaceCompiledTemplates()()
+	for name, partial := range partials {
+		helper, err := compileTemplate([]byte(partial), name, name+".hbs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compiledTemplates.partials[name] = helper
+	}
+	posts := []structure.Post{{Title: []byte("First")}, {Title: []byte("Second")}}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{#foreach posts}}{{> "post/card" post=this size="small"}}{{/foreach}}`, "<small:First:First><small:Second:Second>"},
+		{`{{#> wrapper}}{{#foreach posts limit=1}}{{title}}{{/foreach}}{{/wrapper}}`, "[First]"},
+		{`{{#> missing}}fallback{{/missing}}{{> missing}}`, "fallback"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	defer replaceCompiledTemplates()()
+	layout, err := compileTemplate([]byte(`<html>{{body}}{{block "scripts"}}</html>`), "default", "default.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiledTemplates.m["default"] = layout
+	helper, err := compileTemplate([]byte(`{{!< default}}<h1>{{@blog.title}}</h1>{{#contentFor "scripts"}}<script></script>{{/contentFor}}`), "index", "index.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := structure.RequestData{Blog: &structure.Blog{Title: []byte("Blog")}}
+	var buffer strings.Builder
+	if err = executeTemplate(&buffer, helper, &values, 0); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<html><h1>Blog</h1><script></script></html>"; buffer.String() != expected {
+		t.Errorf("Expected %q, received %q", expected, buffer.String())
+	}
+}
+
+func TestTemplateLookup(t *testing.T) {
+	defer replaceCompiledTemplates()()
+	for _, name := range []string{"index", "post", "page", "post-about", "custom-wide", "tag-news"} {
+		compiledTemplates.m[name] = &structure.Helper{Name: name}
+	}
+	tests := []struct {
+		post     structure.Post
+		expected string
+	}{
+		{structure.Post{Slug: "hello"}, "post"},
+		{structure.Post{Slug: "about"}, "post-about"},
+		{structure.Post{Slug: "about", CustomTemplate: "custom-wide"}, "custom-wide"},
+		{structure.Post{Slug: "about", CustomTemplate: "custom-missing"}, "post-about"},
+		{structure.Post{Slug: "contact", IsPage: true}, "page"},
+		{structure.Post{Slug: "contact", IsPage: true, CustomTemplate: "page"}, "page"},
+	}
+	for _, test := range tests {
+		if result := postTemplate(&test.post).Name; result != test.expected {
+			t.Errorf("Expected %s for %+v, received %s", test.expected, test.post, result)
+		}
+	}
+	if result := lookupTemplate("tag-news", "tag", "index").Name; result != "tag-news" {
+		t.Errorf("Expected tag-news, received %s", result)
+	}
+	if result := lookupTemplate("author-jane", "author", "index").Name; result != "index" {
+		t.Errorf("Expected index, received %s", result)
+	}
+	if result := GetCustomTemplates(); len(result) != 1 || result[0] != "custom-wide" {
+		t.Errorf("Expected [custom-wide], received %v", result)
+	}
+}
+
+func TestGhostHead(t *testing.T) {
+	blog := structure.Blog{Url: []byte("https://example.com"), Title: []byte("Blog & Co"), Description: []byte("About things"), Logo: []byte("https://cdn.example.com/logo.png")}
+	author := structure.User{Name: []byte("Jane"), Slug: "jane", Bio: []byte("Writer"), Website: []byte("https://jane.example.com")}
+	posts := []structure.Post{{Title: []byte("Hello"), Slug: "hello", Html: []byte("<p>First <b>post</b></p>"), Image: []byte("/images/cover.jpg"), Tags: []structure.Tag{{Name: []byte("News"), Slug: "news"}}, Author: &author}}
+	tests := []struct {
+		values   structure.RequestData
+		expected []string
+		missing  []string
+	}{
+		{
+			structure.RequestData{Posts: posts, CurrentTemplate: 1, CurrentPath: "/hello/"},
+			[]string{`<link rel="canonical" href="https://example.com/hello/">`, `<meta property="og:type" content="article">`, `<meta property="og:description" content="First post">`, `<meta property="og:image" content="https://example.com/images/cover.jpg">`, `<meta property="article:tag" content="News">`, `<meta name="twitter:card" content="summary_large_image">`, `<meta name="twitter:data1" content="Jane">`, `"@type": "Article"`, `"sameAs": [`, `"keywords": "News"`, `<meta name="generator" content="Journey">`, `href="https://example.com/rss/"`},
+			nil,
+		},
+		{
+			structure.RequestData{Posts: posts, CurrentTemplate: 3, CurrentPath: "/author/jane/"},
+			[]string{`<meta property="og:type" content="profile">`, `<meta property="og:title" content="Jane - Blog &amp; Co">`, `"@type": "Person"`, `href="https://example.com/author/jane/rss/"`},
+			nil,
+		},
+		{
+			structure.RequestData{Posts: posts, CurrentTemplate: 2, CurrentTag: &posts[0].Tags[0], CurrentIndexPage: 2, CurrentPath: "/tag/news/page/2/"},
+			[]string{`<link rel="canonical" href="https://example.com/tag/news/page/2/">`, `href="https://example.com/tag/news/rss/"`},
+			[]string{"og:type", "application/ld+json"},
+		},
+		{
+			structure.RequestData{CurrentTemplate: 0, CurrentIndexPage: 1, CurrentPath: "/", CspNonce: "abc"},
+			[]string{`<meta name="twitter:card" content="summary">`, `<script type="application/ld+json" nonce="abc">`, `"@type": "WebSite"`, `"logo": {`},
+			nil,
+		},
+	}
+	for index, test := range tests {
+		test.values.Blog = &blog
+		result := string(ghostHeadFunc(nil, &test.values))
+		for _, expected := range test.expected {
+			if !strings.Contains(result, expected) {
+				t.Errorf("Expected %s in test %d, received %s", expected, index, result)
+			}
+		}
+		for _, missing := range test.missing {
+			if strings.Contains(result, missing) {
+				t.Errorf("Didn't expect %s in test %d, received %s", missing, index, result)
+			}
+		}
+	}
+}
+
+func TestCspNonce(t *testing.T) {
+	config := configuration.Config
+	configuration.Config = &configuration.Configuration{SecurityHeaders: configuration.SecurityHeadersConfiguration{Blog: configuration.SecurityPolicy{ContentSecurityPolicy: "script-src 'nonce-{nonce}'"}}}
+	defer func() {
+		configuration.Config = config
+	}()
+	// The nonce of the request is passed to the templates like in ShowIndexTemplate
+	response := httptest.NewRecorder()
+	security.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, renderTemplate(t, `<script nonce="{{csp_nonce}}">`, structure.RequestData{CspNonce: security.Nonce(r)}))
+	})).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	policy := response.Header().Get("Content-Security-Policy")
+	nonce := strings.TrimSuffix(strings.TrimPrefix(policy, "script-src 'nonce-"), "'")
+	if nonce == "" || nonce == policy || response.Body.String() != `<script nonce="`+nonce+`">` {
+		t.Errorf("Expected the nonce of %q in the template, received %q", policy, response.Body.String())
+	}
+}
+
+func TestCodeInjection(t *testing.T) {
+	blog := structure.Blog{Url: []byte("https://example.com"), CodeInjectionHead: []byte("<style>a{}</style>"), CodeInjectionFoot: []byte("<script>blog()</script>")}
+	posts := []structure.Post{{Slug: "hello", CodeInjectionHead: []byte("<meta name=\"post\">"), CodeInjectionFoot: []byte("<script>post()</script>")}}
+	values := structure.RequestData{Blog: &blog, Posts: posts, CurrentTemplate: 1}
+	head := string(ghostHeadFunc(nil, &values))
+	if !strings.HasSuffix(head, "\n<style>a{}</style>\n<meta name=\"post\">") {
+		t.Errorf("Unexpected ghost_head: %s", head)
+	}
+	if foot := string(ghostFootFunc(nil, &values)); foot != "<script>blog()</script>\n<script>post()</script>" {
+		t.Errorf("Unexpected ghost_foot: %s", foot)
+	}
+	// The code of posts is only inserted on their own page
+	values.CurrentTemplate = 0
+	if foot := string(ghostFootFunc(nil, &values)); foot != "<script>blog()</script>" {
+		t.Errorf("Unexpected ghost_foot on index: %s", foot)
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	themePath, err := ioutil.TempDir("", "journey-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(themePath)
+	err = os.MkdirAll(filepath.Join(themePath, "locales"), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(themePath, "locales", "de.json"), []byte(`{"Read more": "Weiterlesen", "Page {page} of {pages}": "Seite {page} von {pages}", "By {name}": ""}`), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// de-CH falls back to de, unknown and invalid languages have no translations
+	translations, err := loadTranslations(themePath, "de-CH")
+	if err != nil || translations["Read more"] != "Weiterlesen" {
+		t.Fatalf("Unexpected translations for de-CH: %v %v", translations, err)
+	}
+	for _, language := range []string{"fr", "../de"} {
+		if translations, err := loadTranslations(themePath, language); err != nil || len(translations) != 0 {
+			t.Errorf("Expected no translations for %s, received %v %v", language, translations, err)
+		}
+	}
+	defer replaceCompiledTemplates()()
+	compiledTemplates.translations, _ = loadTranslations(themePath, "de")
+	blog := structure.Blog{Language: "de"}
+	posts := []structure.Post{{Title: []byte("<Hello>"), Author: &structure.User{Name: []byte("Jane")}}}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{t "Read more"}} {{t "Unknown"}}`, "Weiterlesen Unknown"},
+		{`{{t "Page {page} of {pages}" page=2 pages="3"}}`, "Seite 2 von 3"},
+		{`{{#foreach posts}}{{t "By {name}" name=author.name}} {{t "{title}" title=title}}{{/foreach}}`, "By Jane &lt;Hello&gt;"},
+		{`{{lang}} {{@site.locale}}`, "de de"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Posts: posts, Blog: &blog}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+}
+
+func TestThemeConfig(t *testing.T) {
+	themePath, err := ioutil.TempDir("", "journey-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(themePath)
+	packageJson := `{"name": "test", "config": {"posts_per_page": 12, "image_sizes": {"s": {"width": 300}}, "custom": {
+		"layout": {"type": "select", "options": ["Wide", "Narrow"], "default": "Wide"},
+		"show_tags": {"type": "boolean", "default": true},
+		"accent": {"type": "color", "default": "#ff0000"},
+		"footer": {"type": "text"},
+		"broken": {"type": "select", "options": ["A"], "default": "B"},
+		"Invalid-Name": {"type": "text"}
+	}}}`
+	err = ioutil.WriteFile(filepath.Join(themePath, "package.json"), []byte(packageJson), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, warnings, err := loadThemeConfig(themePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PostsPerPage != 12 || config.ImageSizes["s"].Width != 300 || len(config.Custom) != 4 || len(warnings) != 2 {
+		t.Errorf("Unexpected config: %+v %v", config, warnings)
+	}
+	defer replaceCompiledTemplates()()
+	compiledTemplates.config = config
+	// Saved values that aren't valid anymore fall back to the default
+	compiledTemplates.custom = customValues(config, []byte(`{"layout": "Narrow", "accent": "red", "show_tags": false, "removed": "x"}`))
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{@custom.layout}} {{@custom.accent}}`, "Narrow #ff0000"},
+		{`{{#if @custom.show_tags}}tags{{else}}no tags{{/if}}{{#match @custom.layout "Narrow"}}!{{/match}}`, "no tags!"},
+		{`[{{@custom.footer}}{{@custom.missing}}]`, "[]"},
+		{`{{@blog.posts_per_page}}`, "12"},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Blog: &structure.Blog{}}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, test.template, result)
+		}
+	}
+	// The setting of the blog takes precedence over the theme
+	if result := postsPerPage(&structure.Blog{PostsPerPage: 3}); result != 3 {
+		t.Errorf("Expected 3 posts per page, received %d", result)
+	}
+	if _, _, err := CheckThemeSettings(map[string]interface{}{"accent": "#00ff00", "layout": "Huge"}); err == nil {
+		t.Error("Expected an error for an invalid option")
+	}
+	if _, values, err := CheckThemeSettings(map[string]interface{}{"accent": "#00ff00"}); err != nil || values["accent"] != "#00ff00" || values["layout"] != "Narrow" {
+		t.Errorf("Unexpected values: %v %v", values, err)
+	}
+}
+
+func TestCompileThemeKeepsTemplates(t *testing.T) {
+	themePath, err := ioutil.TempDir("", "journey-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(themePath)
+	defer replaceCompiledTemplates()()
+	compiledTemplates.m["index"] = &structure.Helper{Name: "index"}
+	for name, content := range map[string]string{"index.hbs": "{{#if title}}", "post.hbs": "{{title}}"} {
+		if err := ioutil.WriteFile(filepath.Join(themePath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := compileTheme(themePath); err == nil {
+		t.Error("Expected an error for a theme that doesn't compile")
+	}
+	if len(compiledTemplates.m) != 1 || compiledTemplates.m["index"].Name != "index" {
+		t.Errorf("Expected the compiled templates to be unchanged, received %v", compiledTemplates.m)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	defer replaceCompiledTemplates()()
+	partial, err := compileTemplate([]byte(`{{#if isSecondary}}2:{{/if}}{{#foreach navigation}}[{{label}}{{#if current}}*{{/if}}{{#if current_parent}}^{{/if}}{{#if children}}({{#foreach children}}{{label}}{{#if current}}*{{/if}} {{/foreach}}){{/if}}]{{/foreach}}`), "navigation", "navigation.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiledTemplates.partials["navigation"] = partial
+	blog := structure.Blog{
+		NavigationItems: []structure.Navigation{
+			{Label: "Home", Url: "/"},
+			{Label: "Topics", Url: "/topics", Children: []structure.Navigation{{Label: "Go", Url: "/tag/go/"}, {Label: "Lua", Url: "/tag/lua"}}},
+		},
+		SecondaryNavigationItems: []structure.Navigation{
+			{Label: "Imprint", Url: "/imprint/"},
+			{Label: "Mail", Url: "mailto:mail@example.com"},
+			{Label: "Phone", Url: "tel:+123456"},
+			{Label: "Top", Url: "#top"},
+			{Label: "Source", Url: "https://github.com/kabukky/journey"},
+		},
+		Url: []byte("https://example.com"),
+	}
+	tests := []struct {
+		template string
+		path     string
+		expected string
+	}{
+		{`{{navigation}}`, "/", "[Home*][Topics(Go Lua )]"},
+		{`{{navigation}}`, "/tag/lua/", "[Home][Topics^(Go Lua* )]"},
+		{`{{navigation type="secondary"}}|{{#foreach navigation}}{{label}}{{/foreach}}`, "/imprint/", "2:[Imprint*][Mail][Phone][Top][Source]|HomeTopics"},
+		{`{{#if @site.secondary_navigation}}{{#foreach secondary_navigation}}{{url}} {{/foreach}}{{/if}}`, "/", "/imprint/ mailto:mail@example.com tel:+123456 #top https://github.com/kabukky/journey "},
+		// Only urls relative to the blog are made absolute
+		{`{{#foreach secondary_navigation}}{{url absolute="true"}} {{/foreach}}`, "/", "https://example.com/imprint/ mailto:mail@example.com tel:+123456 #top https://github.com/kabukky/journey "},
+	}
+	for _, test := range tests {
+		if result := renderTemplate(t, test.template, structure.RequestData{Blog: &blog, CurrentPath: test.path}); result != test.expected {
+			t.Errorf("Expected %q for %s at %s, received %q", test.expected, test.template, test.path, result)
+		}
+	}
+}
+
+func TestAdjacentPosts(t *testing.T) {
+	defer useTestDatabase(t)()
+	published := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	userIds := insertTestUsers(t, "a", "b")
+	tagIds := insertTestTags(t, "a", "b")
+	insertTestPost(t, "One", published, true, false, userIds[0], tagIds[0])
+	// Two and Three are published at the same time, so they are ordered by id. The primary tag of Two is b.
+	two := insertTestPost(t, "Two", published.Add(time.Hour), true, false, userIds[1], tagIds[1], tagIds[0])
+	three := insertTestPost(t, "Three", published.Add(time.Hour), true, false, userIds[0], tagIds[0])
+	insertTestPost(t, "Four", published.Add(2*time.Hour), true, false, userIds[1], tagIds[0])
+	// Drafts are never adjacent posts
+	insertTestPost(t, "Draft", published.Add(3*time.Hour), false, false, userIds[0], tagIds[0])
+	posts := make([]structure.Post, 0, 3)
+	for _, id := range []int64{two, three} {
+		post, err := database.RetrievePostById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, *post)
+	}
+	// Posts without a publication date have no adjacent posts
+	posts = append(posts, structure.Post{Title: []byte("None")})
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{``, "One<Two>Three Two<Three>Four -<None>- "},
+		{` in="primary_tag"`, "-<Two>- One<Three>Four -<None>- "},
+		{` in="author"`, "-<Two>Four One<Three>- -<None>- "},
+	}
+	for _, test := range tests {
+		template := `{{#foreach posts}}{{#prev_post` + test.in + `}}{{title}}{{else}}-{{/prev_post}}<{{title}}>{{#next_post` + test.in + `}}{{title}}{{else}}-{{/next_post}} {{/foreach}}`
+		if result := renderTemplate(t, template, structure.RequestData{Posts: posts}); result != test.expected {
+			t.Errorf("Expected %q for %s, received %q", test.expected, template, result)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package templates
 
 import (
+	"io"
+
 	"journey/structure"
 )
 
@@ -12,12 +14,16 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 	// General functions
 	"if":               ifFunc,
 	"unless":           unlessFunc,
+	"has":              hasFunc,
+	"is":               isFunc,
+	"match":            matchFunc,
 	"foreach":          foreachFunc,
 	"!<":               extendFunc,
 	"body":             bodyFunc,
 	"asset":            assetFunc,
 	"encode":           encodeFunc,
 	">":                insertFunc,
+	"#>":               insertBlockFunc,
 	"meta_title":       metaTitleFunc,
 	"meta_description": metaDescriptionFunc,
 	"ghost_head":       ghostHeadFunc,
@@ -28,6 +34,10 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 	"image":            imageFunc,
 	"contentFor":       contentForFunc,
 	"block":            blockFunc,
+	"csp_nonce":        cspNonceFunc,
+	"get":              getFunc,
+	"t":                tFunc,
+	"lang":             langFunc,
 
 	// @blog functions
 	"@blog.title":       atBlogDotTitleFunc,
@@ -39,6 +49,8 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 
 	// Post functions
 	"post":       postFunc,
+	"next_post":  nextPostFunc,
+	"prev_post":  prevPostFunc,
 	"excerpt":    excerptFunc,
 	"title":      titleFunc,
 	"content":    contentFunc,
@@ -58,6 +70,7 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 	"website":         websiteFunc,
 	"cover":           coverFunc,
 	"location":        locationFunc,
+	"count.posts":     countDotPostsFunc,
 	"author.name":     authorDotNameFunc,
 	"author.bio":      bioFunc,
 	"author.email":    emailFunc,
@@ -67,18 +80,24 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 	"author.location": locationFunc,
 
 	// Navigation functions
-	"navigation": navigationFunc,
-	"label":      labelFunc,
-	"current":    currentFunc,
-	"slug":       slugFunc,
+	"navigation":     navigationFunc,
+	"label":          labelFunc,
+	"current":        currentFunc,
+	"current_parent": currentParentFunc,
+	"slug":           slugFunc,
 
 	// Multiple block functions
-	"@first": atFirstFunc,
-	"@last":  atLastFunc,
-	"@even":  atEvenFunc,
-	"@odd":   atOddFunc,
-	"name":   nameFunc,
-	"url":    urlFunc,
+	"@first":    atFirstFunc,
+	"@last":     atLastFunc,
+	"@even":     atEvenFunc,
+	"@odd":      atOddFunc,
+	"@index":    atIndexFunc,
+	"@number":   atNumberFunc,
+	"@key":      atIndexFunc,
+	"@rowStart": atRowStartFunc,
+	"@rowEnd":   atRowEndFunc,
+	"name":      nameFunc,
+	"url":       urlFunc,
 
 	// Pagination functions
 	"pagination": paginationFunc,
@@ -99,3 +118,15 @@ var helperFuctions = map[string]func(*structure.Helper, *structure.RequestData)
 	"pagination.total":    paginationDotTotalFunc,
 	"../pagination.total": paginationDotTotalFunc,
 }
+
+// Block helpers that write their output straight to the page when they are executed as part of a template.
+// Their functions in helperFuctions return the same output for helpers that need it as []byte.
+var helperWriters = map[string]func(io.Writer, *structure.Helper, *structure.RequestData) error{
+	"if":      writeIf,
+	"unless":  writeUnless,
+	"foreach": writeForeach,
+	">":       writeInsert,
+	"#>":      writeInsertBlock,
+	"post":    writePost,
+	"get":     writeGet,
+}
@@ -0,0 +1,279 @@
+package templates
+
+import (
+	"bytes"
+	"strings"
+)
+
+type itemType int
+
+const (
+	itemText             itemType = iota // Text outside of mustaches
+	itemOpen                             // {{
+	itemOpenUnescaped                    // {{{ or {{&
+	itemOpenBlock                        // {{#
+	itemOpenInverse                      // {{^
+	itemOpenEndBlock                     // {{/
+	itemOpenPartial                      // {{>
+	itemOpenPartialBlock                 // {{#>
+	itemOpenExtend                       // {{!<
+	itemClose                            // }} or }}}
+	itemComment                          // {{! comment }} or {{!-- comment --}}
+	itemId                               // Name or path, e.g. title, @blog.url or ../pagination.total
+	itemString                           // "string" or 'string' (the value is stored without quotes)
+	itemNumber                           // 42 or -1.5
+	itemEquals                           // = of a hash argument
+	itemOpenParen                        // ( of a subexpression
+	itemCloseParen                       // ) of a subexpression
+	itemBlockParams                      // |name| after "as" in a block
+	itemEOF
+)
+
+// item: a token of a template file
+type item struct {
+	typ    itemType
+	value  string
+	offset int // Offset in the file. Used for error messages.
+	// Whitespace control: ~ on a delimiter removes the whitespace of the text next to it (e.g. {{~foo~}})
+	trimBefore bool
+	trimAfter  bool
+}
+
+// lexError: an error at an offset in the file. The parser turns it into a CompileError with line and column.
+type lexError struct {
+	offset  int
+	message string
+}
+
+func (e *lexError) Error() string {
+	return e.message
+}
+
+type lexer struct {
+	data  []byte
+	pos   int
+	items []item
+}
+
+// Characters that end an id
+const idDelimiters = " \t\r\n=~}()|\"'"
+
+// Function to split a template file into text and the tokens inside of mustaches
+func lex(data []byte) ([]item, error) {
+	l := &lexer{data: data, items: make([]item, 0)}
+	for l.pos < len(l.data) {
+		start := bytes.Index(l.data[l.pos:], openTag)
+		if start == -1 {
+			l.emitText(l.data[l.pos:])
+			l.pos = len(l.data)
+			break
+		}
+		start += l.pos
+		// An escaped mustache (\{{) is output as it is
+		if start > 0 && l.data[start-1] == '\\' {
+			l.emitText(l.data[l.pos : start-1])
+			l.emitText(openTag)
+			l.pos = start + len(openTag)
+			continue
+		}
+		l.emitText(l.data[l.pos:start])
+		l.pos = start
+		err := l.lexMustache()
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.items = append(l.items, item{typ: itemEOF, offset: len(l.data)})
+	applyWhitespaceControl(l.items)
+	return l.items, nil
+}
+
+func (l *lexer) emitText(text []byte) {
+	if len(text) != 0 {
+		l.items = append(l.items, item{typ: itemText, value: string(text), offset: l.pos})
+	}
+}
+
+func (l *lexer) hasPrefix(prefix string) bool {
+	return bytes.HasPrefix(l.data[l.pos:], []byte(prefix))
+}
+
+func (l *lexer) lexMustache() error {
+	start := l.pos
+	l.pos += len(openTag)
+	open := item{typ: itemOpen, offset: start}
+	closeTag := "}}"
+	if l.hasPrefix("{") {
+		open.typ = itemOpenUnescaped
+		closeTag = "}}}"
+		l.pos++
+	}
+	if l.hasPrefix("~") {
+		open.trimBefore = true
+		l.pos++
+	}
+	if open.typ == itemOpen {
+		switch {
+		case l.hasPrefix("!<"):
+			open.typ = itemOpenExtend
+			l.pos += 2
+		case l.hasPrefix("!"):
+			return l.lexComment(open)
+		case l.hasPrefix("#>"):
+			open.typ = itemOpenPartialBlock
+			l.pos += 2
+		case l.hasPrefix("#"):
+			open.typ = itemOpenBlock
+			l.pos++
+		case l.hasPrefix("^"):
+			open.typ = itemOpenInverse
+			l.pos++
+		case l.hasPrefix("/"):
+			open.typ = itemOpenEndBlock
+			l.pos++
+		case l.hasPrefix(">"):
+			open.typ = itemOpenPartial
+			l.pos++
+		case l.hasPrefix("&"):
+			open.typ = itemOpenUnescaped
+			l.pos++
+		}
+	}
+	l.items = append(l.items, open)
+	for {
+		l.skipWhitespace()
+		if l.pos >= len(l.data) {
+			return &lexError{offset: start, message: "unclosed mustache, expected " + closeTag}
+		}
+		if l.hasPrefix("~" + closeTag) {
+			l.items = append(l.items, item{typ: itemClose, offset: l.pos, trimAfter: true})
+			l.pos += 1 + len(closeTag)
+			return nil
+		} else if l.hasPrefix(closeTag) {
+			l.items = append(l.items, item{typ: itemClose, offset: l.pos})
+			l.pos += len(closeTag)
+			return nil
+		}
+		err := l.lexToken()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (l *lexer) lexComment(open item) error {
+	start := open.offset
+	end := "}}"
+	if l.hasPrefix("!--") {
+		end = "--}}"
+	}
+	endPos := bytes.Index(l.data[l.pos:], []byte(end))
+	// The closing delimiter could use whitespace control (--~}} or ~}})
+	trimEndPos := bytes.Index(l.data[l.pos:], []byte(strings.Replace(end, "}}", "~}}", 1)))
+	trim := false
+	if trimEndPos != -1 && (endPos == -1 || trimEndPos < endPos) {
+		endPos = trimEndPos
+		end = strings.Replace(end, "}}", "~}}", 1)
+		trim = true
+	}
+	if endPos == -1 {
+		return &lexError{offset: start, message: "unclosed comment, expected " + end}
+	}
+	l.pos += endPos + len(end)
+	l.items = append(l.items, item{typ: itemComment, value: string(l.data[start:l.pos]), offset: start, trimBefore: open.trimBefore, trimAfter: trim})
+	return nil
+}
+
+func (l *lexer) skipWhitespace() {
+	for l.pos < len(l.data) && strings.IndexByte(" \t\r\n", l.data[l.pos]) != -1 {
+		l.pos++
+	}
+}
+
+func (l *lexer) lexToken() error {
+	start := l.pos
+	switch character := l.data[l.pos]; character {
+	case '=':
+		l.items = append(l.items, item{typ: itemEquals, value: "=", offset: start})
+		l.pos++
+	case '(':
+		l.items = append(l.items, item{typ: itemOpenParen, value: "(", offset: start})
+		l.pos++
+	case ')':
+		l.items = append(l.items, item{typ: itemCloseParen, value: ")", offset: start})
+		l.pos++
+	case '"', '\'':
+		var value bytes.Buffer
+		l.pos++
+		for {
+			if l.pos >= len(l.data) {
+				return &lexError{offset: start, message: "unterminated string"}
+			}
+			if l.data[l.pos] == '\\' && l.pos+1 < len(l.data) && l.data[l.pos+1] == character {
+				value.WriteByte(character)
+				l.pos += 2
+				continue
+			}
+			if l.data[l.pos] == character {
+				l.pos++
+				break
+			}
+			value.WriteByte(l.data[l.pos])
+			l.pos++
+		}
+		l.items = append(l.items, item{typ: itemString, value: value.String(), offset: start})
+	case '|':
+		end := bytes.IndexByte(l.data[l.pos+1:], '|')
+		if end == -1 {
+			return &lexError{offset: start, message: "unclosed block parameters, expected |"}
+		}
+		l.items = append(l.items, item{typ: itemBlockParams, value: strings.TrimSpace(string(l.data[l.pos+1 : l.pos+1+end])), offset: start})
+		l.pos += end + 2
+	case '~', '}':
+		return &lexError{offset: start, message: "unexpected " + string(character)}
+	default:
+		for l.pos < len(l.data) && strings.IndexByte(idDelimiters, l.data[l.pos]) == -1 {
+			// Segment literals like foo.[bar baz] can contain any character except ]
+			if l.data[l.pos] == '[' {
+				end := bytes.IndexByte(l.data[l.pos:], ']')
+				if end == -1 {
+					return &lexError{offset: l.pos, message: "unclosed segment literal, expected ]"}
+				}
+				l.pos += end
+			}
+			l.pos++
+		}
+		value := string(l.data[start:l.pos])
+		typ := itemId
+		if isNumber(value) {
+			typ = itemNumber
+		}
+		l.items = append(l.items, item{typ: typ, value: value, offset: start})
+	}
+	return nil
+}
+
+func isNumber(value string) bool {
+	value = strings.TrimPrefix(value, "-")
+	if value == "" || strings.Count(value, ".") > 1 || value[0] == '.' || value[len(value)-1] == '.' {
+		return false
+	}
+	for _, character := range value {
+		if (character < '0' || character > '9') && character != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to remove the whitespace next to delimiters with ~
+func applyWhitespaceControl(items []item) {
+	for index := range items {
+		if items[index].trimBefore && index > 0 && items[index-1].typ == itemText {
+			items[index-1].value = strings.TrimRight(items[index-1].value, " \t\r\n")
+		}
+		if items[index].trimAfter && index+1 < len(items) && items[index+1].typ == itemText {
+			items[index+1].value = strings.TrimLeft(items[index+1].value, " \t\r\n")
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"journey/structure"
+)
+
+// CompileError: an error in a template file with the position it was found at
+type CompileError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// section: the text of a block (or of its else branch) and the helpers that are inserted into it
+type section struct {
+	block    bytes.Buffer
+	children []structure.Helper
+}
+
+func (s *section) add(helper *structure.Helper) {
+	helper.Position = s.block.Len()
+	s.children = append(s.children, *helper)
+}
+
+// frame: a block that has been opened but not closed yet
+type frame struct {
+	helper     *structure.Helper
+	name       string // Name the closing tag has to match
+	offset     int
+	main       section
+	inverse    *section // Set once {{else}} was found
+	elseHelper *structure.Helper
+	chained    bool // Opened by {{else if ...}}. Closed together with the block it belongs to.
+}
+
+func (f *frame) current() *section {
+	if f.inverse != nil {
+		return f.inverse
+	}
+	return &f.main
+}
+
+type parser struct {
+	fileName string
+	data     []byte
+	items    []item
+	pos      int
+	stack    []*frame
+}
+
+// Function to parse a template file into the text of the template and the helpers that are inserted into it
+func parseTemplate(data []byte, fileName string) ([]byte, []structure.Helper, error) {
+	items, err := lex(data)
+	if err != nil {
+		if lexErr, ok := err.(*lexError); ok {
+			return nil, nil, newCompileError(data, fileName, lexErr.offset, lexErr.message)
+		}
+		return nil, nil, err
+	}
+	p := &parser{fileName: fileName, data: data, items: items, stack: []*frame{{}}}
+	err = p.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	root := p.stack[0].main
+	return root.block.Bytes(), root.children, nil
+}
+
+func newCompileError(data []byte, fileName string, offset int, message string) *CompileError {
+	line, column := filePosition(data, offset)
+	return &CompileError{File: fileName, Line: line, Column: column, Message: message}
+}
+
+// Function to get the line and column of an offset in a file
+func filePosition(data []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return line, 1 + utf8.RuneCount(data[lineStart:offset])
+}
+
+func (p *parser) errorf(offset int, format string, arguments ...interface{}) error {
+	return newCompileError(p.data, p.fileName, offset, fmt.Sprintf(format, arguments...))
+}
+
+func (p *parser) next() item {
+	current := p.items[p.pos]
+	if current.typ != itemEOF {
+		p.pos++
+	}
+	return current
+}
+
+func (p *parser) peek() item {
+	return p.items[p.pos]
+}
+
+func (p *parser) top() *frame {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *parser) parse() error {
+	for {
+		current := p.next()
+		switch current.typ {
+		case itemEOF:
+			if len(p.stack) > 1 {
+				unclosed := p.top()
+				return p.errorf(unclosed.offset, "{{#%s}} is never closed, expected {{/%s}}", unclosed.name, unclosed.name)
+			}
+			return nil
+		case itemText:
+			p.top().current().block.WriteString(current.value)
+		case itemComment:
+			// Comments are not part of the output
+		case itemOpen, itemOpenUnescaped:
+			if next := p.peek(); current.typ == itemOpen && next.typ == itemId && next.value == "else" {
+				p.next()
+				err := p.parseElse(current)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			helper, err := p.parseExpression(current.typ == itemOpenUnescaped)
+			if err != nil {
+				return err
+			}
+			p.top().current().add(helper)
+		case itemOpenPartial, itemOpenExtend:
+			name := ">"
+			if current.typ == itemOpenExtend {
+				name = "!<"
+			}
+			helper, err := p.parseArgumentsOf(makeHelper(name, false, 0, []byte{}, nil), current.offset)
+			if err != nil {
+				return err
+			}
+			if len(helper.Arguments) == 0 {
+				return p.errorf(current.offset, "{{%s}} needs the name of a template", name)
+			}
+			p.top().current().add(helper)
+		case itemOpenBlock:
+			nameItem := p.peek()
+			helper, err := p.parseExpression(false)
+			if err != nil {
+				return err
+			}
+			p.stack = append(p.stack, &frame{helper: helper, name: nameItem.value, offset: current.offset})
+		case itemOpenInverse:
+			// {{^}} is the same as {{else}}
+			if p.peek().typ == itemClose {
+				err := p.parseElse(current)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			// {{^name}}...{{/name}} is rendered if name is empty
+			nameItem := p.peek()
+			condition, err := p.parseExpression(false)
+			if err != nil {
+				return err
+			}
+			helper := makeHelper("unless", false, 0, []byte{}, nil)
+			helper.Arguments = []structure.Helper{*condition}
+			p.stack = append(p.stack, &frame{helper: helper, name: nameItem.value, offset: current.offset})
+		case itemOpenEndBlock:
+			err := p.parseEndBlock(current)
+			if err != nil {
+				return err
+			}
+		case itemOpenPartialBlock:
+			// {{#> name}}...{{/name}} inserts the partial with the block as {{> @partial-block}}
+			nameItem := p.peek()
+			helper, err := p.parseArgumentsOf(makeHelper("#>", false, 0, []byte{}, nil), current.offset)
+			if err != nil {
+				return err
+			}
+			if len(helper.Arguments) == 0 {
+				return p.errorf(current.offset, "{{#>}} needs the name of a partial")
+			}
+			p.stack = append(p.stack, &frame{helper: helper, name: nameItem.value, offset: current.offset})
+		default:
+			return p.errorf(current.offset, "unexpected %q", current.value)
+		}
+	}
+}
+
+func (p *parser) parseElse(open item) error {
+	if len(p.stack) == 1 {
+		return p.errorf(open.offset, "{{else}} outside of a block")
+	}
+	block := p.top()
+	if block.inverse != nil {
+		return p.errorf(open.offset, "{{#%s}} already has an {{else}}", block.name)
+	}
+	block.inverse = &section{}
+	block.elseHelper = makeHelper("else", false, 0, []byte{}, nil)
+	if p.peek().typ == itemClose {
+		p.next()
+		return nil
+	}
+	// {{else if ...}} opens a block inside of the else branch that ends with the outer block
+	helper, err := p.parseExpression(false)
+	if err != nil {
+		return err
+	}
+	p.stack = append(p.stack, &frame{helper: helper, name: block.name, offset: open.offset, chained: true})
+	return nil
+}
+
+func (p *parser) parseEndBlock(open item) error {
+	nameItem := p.next()
+	if nameItem.typ != itemId {
+		return p.errorf(open.offset, "expected the name of the block to close")
+	}
+	if closeItem := p.next(); closeItem.typ != itemClose {
+		return p.errorf(closeItem.offset, "expected }} after {{/%s", nameItem.value)
+	}
+	if len(p.stack) == 1 {
+		return p.errorf(open.offset, "{{/%s}} closes a block that was never opened", nameItem.value)
+	}
+	block := p.top()
+	if block.name != nameItem.value {
+		line, column := filePosition(p.data, block.offset)
+		return p.errorf(open.offset, "{{/%s}} doesn't match {{#%s}} opened at line %d, column %d", nameItem.value, block.name, line, column)
+	}
+	for {
+		p.stack = p.stack[:len(p.stack)-1]
+		helper := block.helper
+		helper.Block = block.main.block.Bytes()
+		helper.Children = block.main.children
+		// The else branch is always the last argument of the block helper
+		if block.inverse != nil {
+			block.elseHelper.Block = block.inverse.block.Bytes()
+			block.elseHelper.Children = block.inverse.children
+			helper.Arguments = append(helper.Arguments, *block.elseHelper)
+		}
+		p.top().current().add(helper)
+		if !block.chained {
+			return nil
+		}
+		block = p.top()
+	}
+}
+
+// Function to parse a helper call: the name followed by its arguments (e.g. 'foreach posts' or 'date format="YYYY"')
+func (p *parser) parseExpression(unescaped bool) (*structure.Helper, error) {
+	nameItem := p.next()
+	if nameItem.typ != itemId {
+		return nil, p.errorf(nameItem.offset, "expected the name of a helper")
+	}
+	return p.parseArgumentsOf(makeHelper(nameItem.value, unescaped, 0, []byte{}, nil), nameItem.offset)
+}
+
+// Function to parse the arguments of a helper up to the closing delimiter
+func (p *parser) parseArgumentsOf(helper *structure.Helper, offset int) (*structure.Helper, error) {
+	for {
+		current := p.next()
+		switch current.typ {
+		case itemClose:
+			return helper, nil
+		case itemEOF:
+			return nil, p.errorf(offset, "unclosed mustache")
+		case itemBlockParams:
+			// Block parameters (as |name|) are not used by Journey
+		default:
+			// Skip the 'as' in front of block parameters
+			if current.typ == itemId && current.value == "as" && p.peek().typ == itemBlockParams {
+				continue
+			}
+			argument, err := p.parseArgument(current, helper.Unescaped)
+			if err != nil {
+				return nil, err
+			}
+			helper.Arguments = append(helper.Arguments, *argument)
+		}
+	}
+}
+
+// Function to parse a single argument. Hash arguments are saved as "key=value". If the value is a path or a subexpression,
+// it is added as argument of the hash argument and evaluated when the helper is executed.
+func (p *parser) parseArgument(current item, unescaped bool) (*structure.Helper, error) {
+	switch current.typ {
+	case itemId:
+		if p.peek().typ == itemEquals {
+			p.next()
+			value := p.next()
+			hash := makeHelper(current.value+"="+value.value, unescaped, 0, []byte{}, nil)
+			switch value.typ {
+			case itemString, itemNumber:
+			case itemId:
+				if !isBooleanLiteral(value.value) {
+					hash.Arguments = []structure.Helper{*makeHelper(value.value, true, 0, []byte{}, nil)}
+				}
+			case itemOpenParen:
+				subexpression, err := p.parseSubexpression(value, true)
+				if err != nil {
+					return nil, err
+				}
+				hash.Name = current.value + "="
+				hash.Arguments = []structure.Helper{*subexpression}
+			default:
+				return nil, p.errorf(value.offset, "expected a value for %s", current.value)
+			}
+			return hash, nil
+		}
+		helper := makeHelper(current.value, unescaped, 0, []byte{}, nil)
+		if isBooleanLiteral(current.value) {
+			helper.Function = booleanLiteralFunc
+		}
+		return helper, nil
+	case itemString, itemNumber:
+		// Literals evaluate to themselves (e.g. "=" in {{#match title "=" "Hello"}})
+		helper := makeHelper(current.value, unescaped, 0, []byte{}, nil)
+		helper.Function = literalFunc
+		return helper, nil
+	case itemOpenParen:
+		// Subexpression, e.g. (match title "Hello"). It is executed by calling its function like any other argument.
+		return p.parseSubexpression(current, unescaped)
+	default:
+		return nil, p.errorf(current.offset, "unexpected %q", current.value)
+	}
+}
+
+func isBooleanLiteral(value string) bool {
+	return value == "true" || value == "false" || value == "null" || value == "undefined"
+}
+
+func (p *parser) parseSubexpression(open item, unescaped bool) (*structure.Helper, error) {
+	nameItem := p.next()
+	if nameItem.typ != itemId {
+		return nil, p.errorf(nameItem.offset, "expected the name of a helper after (")
+	}
+	helper := makeHelper(nameItem.value, unescaped, 0, []byte{}, nil)
+	helper.Subexpression = true
+	for {
+		current := p.next()
+		switch current.typ {
+		case itemCloseParen:
+			return helper, nil
+		case itemClose, itemEOF:
+			return nil, p.errorf(open.offset, "unclosed subexpression, expected )")
+		default:
+			argument, err := p.parseArgument(current, unescaped)
+			if err != nil {
+				return nil, err
+			}
+			helper.Arguments = append(helper.Arguments, *argument)
+		}
+	}
+}
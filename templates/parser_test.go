@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTemplateBlocks(t *testing.T) {
+	block, helpers, err := parseTemplate([]byte("<p>{{#if featured}}{{title}}{{else if page}}page{{else}}post{{/if}}</p>"), "test.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(block) != "<p></p>" || len(helpers) != 1 || helpers[0].Position != 3 {
+		t.Fatalf("Unexpected template structure: %q %+v", block, helpers)
+	}
+	ifHelper := helpers[0]
+	if ifHelper.Name != "if" || len(ifHelper.Arguments) != 2 || ifHelper.Arguments[0].Name != "featured" || len(ifHelper.Children) != 1 || ifHelper.Children[0].Name != "title" {
+		t.Fatalf("Unexpected if helper: %+v", ifHelper)
+	}
+	// {{else if page}} is an if block inside of the else branch
+	elseHelper := ifHelper.Arguments[1]
+	if elseHelper.Name != "else" || len(elseHelper.Children) != 1 || elseHelper.Children[0].Name != "if" {
+		t.Fatalf("Unexpected else helper: %+v", elseHelper)
+	}
+	chained := elseHelper.Children[0]
+	if string(chained.Block) != "page" || len(chained.Arguments) != 2 || string(chained.Arguments[1].Block) != "post" {
+		t.Fatalf("Unexpected chained if helper: %+v", chained)
+	}
+}
+
+func TestParseTemplateSyntax(t *testing.T) {
+	block, helpers, err := parseTemplate([]byte("a  {{~date format=\"D }} M\"~}}  b \\{{literal}} {{!-- {{comment}} --}}{{{body}}}"), "test.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(block) != "ab {{literal}} " {
+		t.Errorf("Unexpected text: %q", block)
+	}
+	if len(helpers) != 2 || helpers[0].Arguments[0].Name != "format=D }} M" || !helpers[1].Unescaped {
+		t.Errorf("Unexpected helpers: %+v", helpers)
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"<div>\n  {{#foreach posts}}\n  {{/if}}", "test.hbs:3:3: {{/if}} doesn't match {{#foreach}} opened at line 2, column 3"},
+		{"{{#if title}}\n{{title}}", "test.hbs:1:1: {{#if}} is never closed, expected {{/if}}"},
+		{"{{else}}", "test.hbs:1:1: {{else}} outside of a block"},
+		{"{{title", "test.hbs:1:1: unclosed mustache, expected }}"},
+	}
+	for _, test := range tests {
+		_, _, err := parseTemplate([]byte(test.template), "test.hbs")
+		if _, ok := err.(*CompileError); !ok || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected %q for %q, received %v", test.expected, test.template, err)
+		}
+	}
+}
+
+func TestParseBuiltInTemplates(t *testing.T) {
+	for _, name := range []string{"navigation.hbs", "pagination.hbs"} {
+		_, err := createTemplateFromFile("../built-in/hbs/" + name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package templates
+
+import (
+	"io"
+	"strings"
+
+	"journey/structure"
+)
+
+// Name of the partial that inserts the content of a partial block
+const partialBlockName = "@partial-block"
+
+// Function to find a partial by its path relative to the partials folder. Falls back to the templates
+// so that themes can still insert templates that are not in the partials folder.
+func lookupPartial(name string) (*structure.Helper, bool) {
+	return compiledTemplates.lookupPartial(name)
+}
+
+func (t *Templates) lookupPartial(name string) (*structure.Helper, bool) {
+	if partial, ok := t.partials[name]; ok {
+		return partial, true
+	}
+	template, ok := t.m[name]
+	return template, ok
+}
+
+// Function to insert a partial. If block is set, it is the partial block that the partial can insert with {{> @partial-block}}
+// and that is executed instead if the partial doesn't exist.
+func executePartial(helper *structure.Helper, values *structure.RequestData, block *structure.Helper) []byte {
+	return bufferOutput(func(writer io.Writer) error {
+		return writePartial(writer, helper, values, block)
+	})
+}
+
+func writePartial(writer io.Writer, helper *structure.Helper, values *structure.RequestData, block *structure.Helper) error {
+	if len(helper.Arguments) == 0 {
+		return nil
+	}
+	name := helper.Arguments[0].Name
+	if name == partialBlockName {
+		return writePartialBlock(writer, values)
+	}
+	partial, ok := lookupPartial(name)
+	if !ok {
+		if block != nil {
+			return executeTemplate(writer, block, values, values.CurrentHelperContext)
+		}
+		return nil
+	}
+	dataContext := makeDataContext(values, values.CurrentHelperContext)
+	dataContext.Parameters = partialParameters(helper.Arguments[1:], values)
+	dataContext.PartialBlock = block
+	length := len(values.DataContexts)
+	values.DataContexts = append(values.DataContexts, dataContext)
+	defer popDataContext(values, length)
+	return executeTemplate(writer, partial, values, values.CurrentHelperContext)
+}
+
+func writePartialBlock(writer io.Writer, values *structure.RequestData) error {
+	for index := len(values.DataContexts) - 1; index >= 0; index-- {
+		if block := values.DataContexts[index].PartialBlock; block != nil {
+			// Remove the partial block while it is executed so that it can't insert itself
+			values.DataContexts[index].PartialBlock = nil
+			defer func() {
+				values.DataContexts[index].PartialBlock = block
+			}()
+			return executeTemplate(writer, block, values, values.CurrentHelperContext)
+		}
+	}
+	return nil
+}
+
+// Function to evaluate the hash parameters of a partial (e.g. post=this size="small"). Paths keep the object
+// they point to so that the partial can access its properties (e.g. {{post.title}}).
+func partialParameters(arguments []structure.Helper, values *structure.RequestData) map[string]interface{} {
+	if len(arguments) == 0 {
+		return nil
+	}
+	parameters := make(map[string]interface{})
+	for index := range arguments {
+		parts := strings.SplitN(arguments[index].Name, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if len(arguments[index].Arguments) == 0 {
+			// Literal value
+			if isBooleanLiteral(parts[1]) {
+				parameters[parts[0]] = booleanLiteralFunc(&structure.Helper{Name: parts[1]}, values)
+			} else {
+				parameters[parts[0]] = []byte(parts[1])
+			}
+			continue
+		}
+		value := &arguments[index].Arguments[0]
+		if object, ok := resolveObject(value.Name, values); ok && !value.Subexpression {
+			parameters[parts[0]] = object
+		} else {
+			parameters[parts[0]] = value.Function(value, values)
+		}
+	}
+	return parameters
+}
+
+// Function to get a hash parameter of the partial that is currently executed
+func partialParameter(values *structure.RequestData, name string) (interface{}, bool) {
+	if length := len(values.DataContexts); length != 0 && values.DataContexts[length-1].Parameters != nil {
+		value, ok := values.DataContexts[length-1].Parameters[name]
+		return value, ok
+	}
+	return nil, false
+}
@@ -0,0 +1,397 @@
+package templates
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"journey/structure"
+)
+
+// Properties that paths can access on each type of object. Used to check themes for unknown paths.
+var postProperties = []string{"id", "uuid", "title", "slug", "html", "featured", "page", "meta_description", "image", "feature_image", "published_at", "url", "author", "primary_author", "primary_tag"}
+var tagProperties = []string{"id", "name", "slug", "url"}
+var userProperties = []string{"id", "name", "slug", "email", "image", "profile_image", "cover", "cover_image", "bio", "website", "location", "url"}
+var navigationProperties = []string{"label", "url", "slug", "children"}
+
+// Function to create the data context of a block helper from the current indexes of the request
+func makeDataContext(values *structure.RequestData, context int) structure.DataContext {
+	dataContext := structure.DataContext{Type: context}
+	var post *structure.Post
+	if values.CurrentPostIndex < len(values.Posts) {
+		post = &values.Posts[values.CurrentPostIndex]
+	}
+	switch context {
+	case 0: // index
+		if values.CurrentTemplate == 2 { // tag
+			dataContext.Tag = values.CurrentTag
+		} else if values.CurrentTemplate == 3 && post != nil { // author
+			dataContext.Author = post.Author
+		}
+	case 1: // post
+		dataContext.Post = post
+	case 2: // tag
+		if post != nil && values.CurrentTagIndex < len(post.Tags) {
+			dataContext.Tag = &post.Tags[values.CurrentTagIndex]
+		}
+	case 3: // author
+		if post != nil {
+			dataContext.Author = post.Author
+		}
+	case 4: // navigation
+		dataContext.Navigation = currentNavigationItem(values)
+	}
+	return dataContext
+}
+
+// Function for helpers that have no function of their own. They are resolved as path (e.g. post.author.website
+// or ../title). If that fails, the helper might be provided by a plugin.
+func pathFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	if value, ok := resolvePath(helper.Name, values); ok {
+		return evaluateEscape(value, helper.Unescaped)
+	}
+	return nullFunc(helper, values)
+}
+
+// Function to get the value of a path. Objects evaluate to []byte{1} so they can be used as condition.
+func resolvePath(path string, values *structure.RequestData) ([]byte, bool) {
+	object, ok := resolveObject(path, values)
+	if !ok {
+		return nil, false
+	}
+	return pathValue(object), true
+}
+
+// Function to get the object or value a path points to
+func resolveObject(path string, values *structure.RequestData) (interface{}, bool) {
+	dataContexts := values.DataContexts
+	if len(dataContexts) == 0 {
+		dataContexts = []structure.DataContext{makeDataContext(values, values.CurrentHelperContext)}
+	}
+	depth := len(dataContexts) - 1
+	for {
+		if strings.HasPrefix(path, "../") {
+			path = path[len("../"):]
+			if depth > 0 {
+				depth--
+			}
+		} else if strings.HasPrefix(path, "./") {
+			path = path[len("./"):]
+		} else {
+			break
+		}
+	}
+	segments := splitPath(path)
+	if len(segments) == 0 {
+		return nil, false
+	}
+	var object interface{}
+	switch segments[0] {
+	case "this":
+		object = dataContextObject(dataContexts[depth])
+		segments = segments[1:]
+	case "@root":
+		object = dataContextObject(dataContexts[0])
+		segments = segments[1:]
+	case "@blog", "@site":
+		object = objectOrNil(values.Blog)
+		segments = segments[1:]
+	case "@custom":
+		object = compiledTemplates.custom
+		segments = segments[1:]
+	default:
+		current := dataContextObject(dataContexts[depth])
+		if value, ok := dataContexts[depth].Parameters[segments[0]]; ok {
+			object = objectOrNil(value)
+			segments = segments[1:]
+		} else if value, ok := property(current, segments[0]); ok && current != nil {
+			object = objectOrNil(value)
+			segments = segments[1:]
+		} else if value, ok := rootObject(segments[0], values, dataContexts[:depth+1]); ok {
+			object = objectOrNil(value)
+			segments = segments[1:]
+		} else {
+			return nil, false
+		}
+	}
+	for _, segment := range segments {
+		value, ok := property(object, segment)
+		if !ok {
+			return nil, false
+		}
+		object = objectOrNil(value)
+	}
+	return object, true
+}
+
+// Function to split a path at . and / (segment literals like [my value] are kept as one segment)
+func splitPath(path string) []string {
+	segments := make([]string, 0)
+	start := 0
+	inLiteral := false
+	for index := 0; index < len(path); index++ {
+		switch path[index] {
+		case '[':
+			inLiteral = true
+		case ']':
+			inLiteral = false
+		case '.', '/':
+			if !inLiteral {
+				segments = append(segments, strings.Trim(path[start:index], "[]"))
+				start = index + 1
+			}
+		}
+	}
+	if start < len(path) {
+		segments = append(segments, strings.Trim(path[start:], "[]"))
+	}
+	return segments
+}
+
+// Function to check if two data contexts are executed with the same object. Parameters and partial blocks are not compared.
+func sameDataContext(a structure.DataContext, b structure.DataContext) bool {
+	return a.Type == b.Type && a.Post == b.Post && a.Tag == b.Tag && a.Author == b.Author && a.Navigation == b.Navigation
+}
+
+func dataContextObject(dataContext structure.DataContext) interface{} {
+	switch {
+	case dataContext.Post != nil:
+		return dataContext.Post
+	case dataContext.Tag != nil:
+		return dataContext.Tag
+	case dataContext.Author != nil:
+		return dataContext.Author
+	case dataContext.Navigation != nil:
+		return dataContext.Navigation
+	}
+	return nil
+}
+
+// Function to find the post, tag or author a path like post.title or tag.name refers to
+func rootObject(name string, values *structure.RequestData, dataContexts []structure.DataContext) (interface{}, bool) {
+	switch name {
+	case "post":
+		for index := len(dataContexts) - 1; index >= 0; index-- {
+			if dataContexts[index].Post != nil {
+				return dataContexts[index].Post, true
+			}
+		}
+		if values.CurrentTemplate == 1 && len(values.Posts) != 0 { // post
+			return &values.Posts[0], true
+		}
+	case "tag":
+		// On a tag page, tag is the tag of the page
+		if values.CurrentTag != nil {
+			return values.CurrentTag, true
+		}
+		for index := len(dataContexts) - 1; index >= 0; index-- {
+			if dataContexts[index].Tag != nil {
+				return dataContexts[index].Tag, true
+			}
+		}
+	case "author":
+		for index := len(dataContexts) - 1; index >= 0; index-- {
+			if dataContexts[index].Author != nil {
+				return dataContexts[index].Author, true
+			} else if dataContexts[index].Post != nil {
+				return dataContexts[index].Post.Author, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Function to get a property of an object. Returns false if the object doesn't have the property.
+func property(object interface{}, name string) (interface{}, bool) {
+	switch object := object.(type) {
+	case *structure.Post:
+		switch name {
+		case "id":
+			return object.Id, true
+		case "uuid":
+			return object.Uuid, true
+		case "title":
+			return object.Title, true
+		case "slug":
+			return object.Slug, true
+		case "html":
+			return object.Html, true
+		case "featured":
+			return object.IsFeatured, true
+		case "page":
+			return object.IsPage, true
+		case "meta_description":
+			return object.MetaDescription, true
+		case "image", "feature_image":
+			return object.Image, true
+		case "published_at":
+			return object.Date, true
+		case "url":
+			return "/" + object.Slug + "/", true
+		case "author", "primary_author":
+			return object.Author, true
+		case "primary_tag":
+			if len(object.Tags) != 0 {
+				return &object.Tags[0], true
+			}
+			return (*structure.Tag)(nil), true
+		}
+	case *structure.Tag:
+		switch name {
+		case "id":
+			return object.Id, true
+		case "name":
+			return object.Name, true
+		case "slug":
+			return object.Slug, true
+		case "url":
+			return "/tag/" + object.Slug + "/", true
+		}
+	case *structure.User:
+		switch name {
+		case "id":
+			return object.Id, true
+		case "name":
+			return object.Name, true
+		case "slug":
+			return object.Slug, true
+		case "email":
+			return object.Email, true
+		case "image", "profile_image":
+			return object.Image, true
+		case "cover", "cover_image":
+			return object.Cover, true
+		case "bio":
+			return object.Bio, true
+		case "website":
+			return object.Website, true
+		case "location":
+			return object.Location, true
+		case "url":
+			return "/author/" + object.Slug + "/", true
+		}
+	case *structure.Navigation:
+		switch name {
+		case "label":
+			return object.Label, true
+		case "url":
+			return object.Url, true
+		case "slug":
+			return object.Slug, true
+		case "children":
+			return object.Children, true
+		}
+	case *structure.Blog:
+		switch name {
+		case "title":
+			return object.Title, true
+		case "url":
+			return string(object.Url) + "/", true
+		case "description":
+			return object.Description, true
+		case "logo":
+			return object.Logo, true
+		case "cover", "cover_image":
+			return object.Cover, true
+		case "locale", "lang":
+			return blogLanguage(object), true
+		case "posts_per_page":
+			return postsPerPage(object), true
+		case "post_count":
+			return object.PostCount, true
+		case "navigation":
+			return object.NavigationItems, true
+		case "secondary_navigation":
+			return object.SecondaryNavigationItems, true
+		}
+	case map[string]interface{}:
+		// Custom settings of the theme. Settings that the theme doesn't declare are empty.
+		return object[name], true
+	case nil:
+		// Properties of missing objects (e.g. primary_tag.name of a post without tags) are empty
+		return nil, true
+	}
+	return nil, false
+}
+
+// Function to turn nil pointers into nil so that properties of missing objects resolve to nothing
+func objectOrNil(object interface{}) interface{} {
+	switch value := object.(type) {
+	case *structure.Post:
+		if value == nil {
+			return nil
+		}
+	case *structure.Tag:
+		if value == nil {
+			return nil
+		}
+	case *structure.User:
+		if value == nil {
+			return nil
+		}
+	case *structure.Navigation:
+		if value == nil {
+			return nil
+		}
+	case *structure.Blog:
+		if value == nil {
+			return nil
+		}
+	}
+	return object
+}
+
+// Function to convert the value of a path to the output of a helper
+func pathValue(object interface{}) []byte {
+	switch value := object.(type) {
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	case int64:
+		return []byte(strconv.FormatInt(value, 10))
+	case bool:
+		if value {
+			return []byte{1}
+		}
+		return []byte{}
+	case *time.Time:
+		if value == nil {
+			return []byte{}
+		}
+		return []byte(value.Format(time.RFC3339))
+	case *structure.Post, *structure.Tag, *structure.User, *structure.Navigation, *structure.Blog:
+		// Objects are only used as conditions
+		return []byte{1}
+	case []structure.Navigation:
+		// Lists of navigation items (e.g. children) are true if they aren't empty
+		if len(value) != 0 {
+			return []byte{1}
+		}
+		return []byte{}
+	}
+	return []byte{}
+}
+
+// Function to check if a name is a path that can be resolved in some context. Used to check themes.
+func isKnownPath(path string) bool {
+	for strings.HasPrefix(path, "../") || strings.HasPrefix(path, "./") {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "../"), "./")
+	}
+	segments := splitPath(path)
+	if len(segments) == 0 {
+		return false
+	}
+	switch segments[0] {
+	case "this", "@root", "@blog", "@site", "@custom", "post", "tag", "author", "primary_author", "primary_tag", "isSecondary":
+		return true
+	}
+	for _, properties := range [][]string{postProperties, tagProperties, userProperties, navigationProperties} {
+		for _, property := range properties {
+			if segments[0] == property {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -64,7 +64,7 @@ func ShowAuthorRss(writer http.ResponseWriter, slug string) error {
 }
 
 func createFeed(values *structure.RequestData) *feeds.Feed {
-	now := date.GetCurrentTime()
+	now := blogTime(values.Blog, date.GetCurrentTime())
 	feed := &feeds.Feed{
 		Title:       string(values.Blog.Title),
 		Description: string(values.Blog.Description),
@@ -90,7 +90,7 @@ func createFeed(values *structure.RequestData) *feeds.Feed {
 				Link:        &feeds.Link{Href: buffer.String()},
 				Id:          string(values.Posts[i].Uuid),
 				Author:      &feeds.Author{Name: string(values.Posts[i].Author.Name), Email: ""},
-				Created:     *values.Posts[i].Date,
+				Created:     blogTime(values.Blog, *values.Posts[i].Date),
 			}
 			// If the post has a cover image, add it to the item
 			image := string(values.Posts[i].Image)
@@ -0,0 +1,270 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"journey/cache"
+	"journey/database"
+	"journey/structure"
+)
+
+// Number of posts per page if neither the blog nor the theme sets it
+const defaultPostsPerPage = 5
+
+// Types of the custom settings a theme can declare
+const (
+	CustomSettingSelect  = "select"
+	CustomSettingBoolean = "boolean"
+	CustomSettingColor   = "color"
+	CustomSettingText    = "text"
+	CustomSettingImage   = "image"
+)
+
+// Longest value of a text or image setting
+const maxCustomSettingLength = 2000
+
+var colorChecker = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var customSettingNameChecker = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ThemeConfig: the config section of the package.json of a theme
+type ThemeConfig struct {
+	PostsPerPage int64                    `json:"posts_per_page"` // 0 if the theme doesn't set it
+	ImageSizes   map[string]ImageSize     `json:"image_sizes"`
+	Custom       map[string]CustomSetting `json:"custom"`
+}
+
+// ImageSize: a size that images are shown in by the theme (in pixels, 0 if not set)
+type ImageSize struct {
+	Width  int
+	Height int
+}
+
+// CustomSetting: a setting of a theme that can be changed in the admin area and is available as @custom.<name>
+type CustomSetting struct {
+	Type        string
+	Options     []string // Values of select settings
+	Default     interface{}
+	Group       string
+	Description string
+}
+
+func newThemeConfig() *ThemeConfig {
+	return &ThemeConfig{ImageSizes: make(map[string]ImageSize), Custom: make(map[string]CustomSetting)}
+}
+
+// Function to read the config of a theme from its package.json. Themes without a package.json have an empty config.
+// Custom settings that are invalid are left out and returned as warnings.
+func loadThemeConfig(themePath string) (*ThemeConfig, []string, error) {
+	config := newThemeConfig()
+	data, err := ioutil.ReadFile(filepath.Join(themePath, "package.json"))
+	if os.IsNotExist(err) {
+		return config, nil, nil
+	} else if err != nil {
+		return config, nil, err
+	}
+	var packageJson struct {
+		Config *ThemeConfig `json:"config"`
+	}
+	packageJson.Config = config
+	err = json.Unmarshal(data, &packageJson)
+	if err != nil {
+		return newThemeConfig(), nil, errors.New("Couldn't parse package.json: " + err.Error())
+	}
+	if config.PostsPerPage < 0 {
+		config.PostsPerPage = 0
+	}
+	warnings := make([]string, 0)
+	for name, setting := range config.Custom {
+		err = checkCustomSetting(name, &setting)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Ignoring custom setting %q: %s", name, err))
+			delete(config.Custom, name)
+			continue
+		}
+		config.Custom[name] = setting
+	}
+	sort.Strings(warnings)
+	return config, warnings, nil
+}
+
+// Function to check the declaration of a custom setting. Missing defaults are set to the empty value of the type.
+func checkCustomSetting(name string, setting *CustomSetting) error {
+	if !customSettingNameChecker.MatchString(name) {
+		return errors.New("names can only contain lowercase letters, numbers and underscores")
+	}
+	switch setting.Type {
+	case CustomSettingSelect:
+		if len(setting.Options) == 0 {
+			return errors.New("select settings need options")
+		}
+	case CustomSettingBoolean:
+		if setting.Default == nil {
+			setting.Default = false
+		}
+	case CustomSettingColor, CustomSettingText, CustomSettingImage:
+		if setting.Default == nil {
+			setting.Default = ""
+		}
+	default:
+		return fmt.Errorf("unknown type %q", setting.Type)
+	}
+	value, err := setting.value(setting.Default)
+	if err != nil {
+		return errors.New("invalid default: " + err.Error())
+	}
+	setting.Default = value
+	return nil
+}
+
+// Function to check a value of a custom setting. Returns the value in the type of the setting.
+func (setting *CustomSetting) value(value interface{}) (interface{}, error) {
+	switch setting.Type {
+	case CustomSettingSelect:
+		text, ok := value.(string)
+		if ok {
+			for _, option := range setting.Options {
+				if text == option {
+					return text, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%v is not one of the options", value)
+	case CustomSettingBoolean:
+		if boolean, ok := value.(bool); ok {
+			return boolean, nil
+		}
+		return nil, fmt.Errorf("%v is not a boolean", value)
+	case CustomSettingColor:
+		// Colors can be empty to use the color of the theme
+		if text, ok := value.(string); ok && (text == "" || colorChecker.MatchString(text)) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("%v is not a color like #15171a", value)
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a text", value)
+		}
+		if utf8.RuneCountInString(text) > maxCustomSettingLength {
+			return nil, errors.New("the text is too long")
+		}
+		return text, nil
+	}
+}
+
+// Function to get the values of all custom settings. Values that aren't saved or aren't valid anymore
+// (e.g. because an option was removed in a new version of the theme) are replaced with the default.
+func customValues(config *ThemeConfig, saved []byte) map[string]interface{} {
+	values := make(map[string]interface{}, len(config.Custom))
+	savedValues := make(map[string]interface{})
+	if len(saved) != 0 {
+		err := json.Unmarshal(saved, &savedValues)
+		if err != nil {
+			log.Println("Warning: Couldn't read the custom theme settings:", err)
+		}
+	}
+	for name, setting := range config.Custom {
+		values[name] = setting.Default
+		if savedValue, ok := savedValues[name]; ok {
+			if value, err := setting.value(savedValue); err == nil {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
+
+// Function to load the saved values of the custom settings of the theme
+func (t *Templates) loadCustomValues() error {
+	saved, err := database.RetrieveThemeSettings(t.theme)
+	if err != nil {
+		return err
+	}
+	t.custom = customValues(t.config, saved)
+	return nil
+}
+
+// Function to get the number of posts on index, tag and author pages. The setting of the blog
+// takes precedence over the default of the theme.
+func postsPerPage(blog *structure.Blog) int64 {
+	if blog != nil && blog.PostsPerPage > 0 {
+		return blog.PostsPerPage
+	}
+	if compiledTemplates.config.PostsPerPage > 0 {
+		return compiledTemplates.config.PostsPerPage
+	}
+	return defaultPostsPerPage
+}
+
+// ThemeSetting: a custom setting of the active theme with its current value, used by the admin api
+type ThemeSetting struct {
+	Name string
+	CustomSetting
+	Value interface{}
+}
+
+// ThemeSettings: the config of the active theme, used by the admin api
+type ThemeSettings struct {
+	Theme        string
+	PostsPerPage int64
+	ImageSizes   map[string]ImageSize
+	Settings     []ThemeSetting
+}
+
+// Function to get the custom settings of the active theme, sorted by group and name
+func GetThemeSettings() *ThemeSettings {
+	compiledTemplates.RLock()
+	defer compiledTemplates.RUnlock()
+	settings := &ThemeSettings{Theme: compiledTemplates.theme, PostsPerPage: compiledTemplates.config.PostsPerPage, ImageSizes: compiledTemplates.config.ImageSizes, Settings: make([]ThemeSetting, 0)}
+	for name, setting := range compiledTemplates.config.Custom {
+		settings.Settings = append(settings.Settings, ThemeSetting{Name: name, CustomSetting: setting, Value: compiledTemplates.custom[name]})
+	}
+	sort.Slice(settings.Settings, func(i, j int) bool {
+		if settings.Settings[i].Group != settings.Settings[j].Group {
+			return settings.Settings[i].Group < settings.Settings[j].Group
+		}
+		return settings.Settings[i].Name < settings.Settings[j].Name
+	})
+	return settings
+}
+
+// Function to check new values of the custom settings of the active theme. Settings that aren't
+// in values keep their current value. Returns the name of the theme and all values.
+func CheckThemeSettings(values map[string]interface{}) (string, map[string]interface{}, error) {
+	compiledTemplates.RLock()
+	defer compiledTemplates.RUnlock()
+	checked := make(map[string]interface{}, len(compiledTemplates.custom))
+	for name, value := range compiledTemplates.custom {
+		checked[name] = value
+	}
+	for name, value := range values {
+		setting, ok := compiledTemplates.config.Custom[name]
+		if !ok {
+			return "", nil, fmt.Errorf("The theme doesn't have a setting %q", name)
+		}
+		value, err := setting.value(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid value for %s: %s", name, err)
+		}
+		checked[name] = value
+	}
+	return compiledTemplates.theme, checked, nil
+}
+
+// Function to apply the custom settings of the active theme after they have been saved
+func ReloadThemeSettings() error {
+	compiledTemplates.Lock()
+	defer compiledTemplates.Unlock()
+	defer cache.Pages.Purge()
+	return compiledTemplates.loadCustomValues()
+}
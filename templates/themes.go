@@ -0,0 +1,235 @@
+package templates
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"journey/filenames"
+	"journey/structure/methods"
+	"journey/upload"
+)
+
+// Most files a theme archive can contain
+const maxThemeFiles = 5000
+
+var themeNameChecker = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Error codes that are sent to the admin area if a theme archive is rejected
+const (
+	ErrorInvalidTheme = "invalid_theme"
+	ErrorThemeExists  = "theme_exists"
+	ErrorThemeActive  = "theme_active"
+)
+
+func newThemeError(code string, message string, statusCode int) *upload.Error {
+	return &upload.Error{Code: code, Message: message, StatusCode: statusCode}
+}
+
+// Function to check if a theme name can be used as the name of a folder in the themes folder
+func ValidThemeName(name string) bool {
+	return themeNameChecker.MatchString(name) && !strings.Contains(name, "..")
+}
+
+func activeThemeName() string {
+	methods.Blog.RLock()
+	defer methods.Blog.RUnlock()
+	return methods.Blog.ActiveTheme
+}
+
+// Function to install a theme from a zip archive. The theme is unpacked into a temporary folder and compiled first, so an
+// invalid archive never replaces an installed theme. Existing themes are only replaced if overwrite is set.
+// Returns the report of the theme check as diagnostics. Its errors don't prevent the installation, since helpers that are
+// unknown to the check can be provided by plugins.
+func InstallTheme(name string, archive []byte, maxSize int64, overwrite bool) (*ThemeReport, error) {
+	if !ValidThemeName(name) {
+		return nil, newThemeError(ErrorInvalidTheme, "Theme names can only contain letters, numbers, dots, dashes and underscores.", http.StatusBadRequest)
+	}
+	themePath := filepath.Join(filenames.ThemesFilepath, name)
+	if _, err := os.Stat(themePath); err == nil && !overwrite {
+		if name == activeThemeName() {
+			return nil, newThemeError(ErrorThemeActive, "The theme "+name+" is active. Please confirm that it should be replaced.", http.StatusConflict)
+		}
+		return nil, newThemeError(ErrorThemeExists, "The theme "+name+" is already installed. Please confirm that it should be replaced.", http.StatusConflict)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, newThemeError(ErrorInvalidTheme, "The file is not a zip archive.", http.StatusBadRequest)
+	}
+	temporaryPath, err := ioutil.TempDir(filenames.ThemesFilepath, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(temporaryPath)
+	err = unpackTheme(reader, temporaryPath, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	report, err := CheckTheme(temporaryPath)
+	if err != nil {
+		return nil, err
+	}
+	report.Theme = name
+	if _, err = compileTheme(temporaryPath); err != nil {
+		return report, newThemeError(ErrorInvalidTheme, "The theme can't be compiled and was not installed: "+err.Error(), http.StatusBadRequest)
+	}
+	// Move the old theme out of the way so that it can be restored if the new one can't be moved into place
+	oldPath := temporaryPath + ".old"
+	if _, err := os.Stat(themePath); err == nil {
+		if err = os.Rename(themePath, oldPath); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(oldPath)
+	}
+	if err = os.Rename(temporaryPath, themePath); err != nil {
+		_ = os.Rename(oldPath, themePath)
+		return nil, err
+	}
+	return report, nil
+}
+
+// Function to unpack a theme archive. Archives that contain a single folder (e.g. casper/index.hbs) are unpacked without it.
+func unpackTheme(reader *zip.Reader, destination string, maxSize int64) error {
+	files := make([]*zip.File, 0, len(reader.File))
+	for _, file := range reader.File {
+		name := strings.Replace(file.Name, "\\", "/", -1)
+		// Skip folders and the metadata that macOS adds to archives
+		if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store" {
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			return newThemeError(ErrorInvalidTheme, "The archive contains a link or special file: "+file.Name, http.StatusBadRequest)
+		}
+		files = append(files, file)
+	}
+	if len(files) > maxThemeFiles {
+		return newThemeError(ErrorInvalidTheme, "The archive contains too many files.", http.StatusBadRequest)
+	}
+	prefix := commonFolder(files)
+	remaining := maxSize
+	for _, file := range files {
+		name := strings.TrimPrefix(strings.Replace(file.Name, "\\", "/", -1), prefix)
+		// Guard against paths that would be unpacked outside of the theme folder (zip slip)
+		cleanName := path.Clean("/" + name)[1:]
+		if cleanName == "" || cleanName != name || path.IsAbs(name) || strings.Contains(name, ":") {
+			return newThemeError(ErrorInvalidTheme, "The archive contains an invalid path: "+file.Name, http.StatusBadRequest)
+		}
+		filePath := filepath.Join(destination, filepath.FromSlash(cleanName))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		written, err := unpackFile(file, filePath, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= written
+	}
+	return nil
+}
+
+func unpackFile(file *zip.File, filePath string, maxSize int64) (int64, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return 0, newThemeError(ErrorInvalidTheme, "The archive is damaged: "+err.Error(), http.StatusBadRequest)
+	}
+	defer reader.Close()
+	writer, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer writer.Close()
+	// Don't trust the sizes in the archive, count the unpacked bytes instead
+	written, err := io.Copy(writer, io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return written, newThemeError(ErrorInvalidTheme, "The archive is damaged: "+err.Error(), http.StatusBadRequest)
+	}
+	if written > maxSize {
+		return written, newThemeError(upload.ErrorFileTooLarge, "The unpacked theme is larger than the limit.", http.StatusRequestEntityTooLarge)
+	}
+	return written, nil
+}
+
+// Function to get the folder that contains all files of an archive (e.g. "casper/"). Returns an empty string if there is none.
+func commonFolder(files []*zip.File) string {
+	prefix := ""
+	for index, file := range files {
+		name := strings.Replace(file.Name, "\\", "/", -1)
+		slash := strings.Index(name, "/")
+		if slash == -1 {
+			return ""
+		}
+		if index == 0 {
+			prefix = name[:slash+1]
+		} else if !strings.HasPrefix(name, prefix) {
+			return ""
+		}
+	}
+	return prefix
+}
+
+// Function to write an installed theme as a zip archive. The files are in a folder with the name of the theme.
+func WriteThemeArchive(writer io.Writer, name string) error {
+	if !ValidThemeName(name) {
+		return newThemeError(ErrorInvalidTheme, "Invalid theme name.", http.StatusBadRequest)
+	}
+	themePath := filepath.Join(filenames.ThemesFilepath, name)
+	if info, err := os.Stat(themePath); err != nil || !info.IsDir() {
+		return newThemeError(ErrorInvalidTheme, "The theme "+name+" is not installed.", http.StatusNotFound)
+	}
+	archive := zip.NewWriter(writer)
+	err := filepath.Walk(themePath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(themePath, filePath)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name + "/" + filepath.ToSlash(relativePath)
+		header.Method = zip.Deflate
+		fileWriter, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(fileWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// Function to delete an installed theme. The active theme can't be deleted.
+func DeleteTheme(name string) error {
+	if !ValidThemeName(name) {
+		return newThemeError(ErrorInvalidTheme, "Invalid theme name.", http.StatusBadRequest)
+	}
+	if name == activeThemeName() {
+		return newThemeError(ErrorThemeActive, "The active theme can't be deleted.", http.StatusConflict)
+	}
+	themePath := filepath.Join(filenames.ThemesFilepath, name)
+	if info, err := os.Stat(themePath); err != nil || !info.IsDir() {
+		return newThemeError(ErrorInvalidTheme, "The theme "+name+" is not installed.", http.StatusNotFound)
+	}
+	return os.RemoveAll(themePath)
+}
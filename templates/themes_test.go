@@ -0,0 +1,109 @@
+package templates
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"journey/filenames"
+	"journey/structure"
+	"journey/structure/methods"
+	"journey/upload"
+)
+
+func themeArchive(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestInstallTheme(t *testing.T) {
+	themesPath, err := ioutil.TempDir("", "journey-themes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(themesPath)
+	oldThemesPath := filenames.ThemesFilepath
+	blog := methods.Blog
+	filenames.ThemesFilepath = themesPath
+	methods.Blog = &structure.Blog{ActiveTheme: "promenade"}
+	defer func() {
+		filenames.ThemesFilepath = oldThemesPath
+		methods.Blog = blog
+	}()
+	valid := map[string]string{"simple/index.hbs": "{{#foreach posts}}{{title}}{{/foreach}}", "simple/post.hbs": "{{#post}}{{content}}{{/post}}"}
+	tests := []struct {
+		name      string
+		files     map[string]string
+		overwrite bool
+		code      string
+	}{
+		{"simple", valid, false, ""},
+		{"simple", valid, false, ErrorThemeExists},
+		{"simple", valid, true, ""},
+		{"slip", map[string]string{"index.hbs": "", "post.hbs": "", "../../outside.hbs": ""}, false, ErrorInvalidTheme},
+		{"broken", map[string]string{"index.hbs": "{{#if title}}"}, false, ErrorInvalidTheme},
+		{"incomplete", map[string]string{"index.hbs": "{{title}}"}, false, ErrorInvalidTheme},
+		// Helpers that are unknown to the theme check can be provided by plugins
+		{"plugin", map[string]string{"index.hbs": "{{share_buttons}}", "post.hbs": ""}, false, ""},
+		{"../simple", valid, false, ErrorInvalidTheme},
+		{"large", map[string]string{"index.hbs": string(make([]byte, 2048)), "post.hbs": ""}, false, upload.ErrorFileTooLarge},
+	}
+	for _, test := range tests {
+		_, err := InstallTheme(test.name, themeArchive(t, test.files), 1024, test.overwrite)
+		code := ""
+		if uploadError, ok := err.(*upload.Error); ok {
+			code = uploadError.Code
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("Expected '%s', received '%s' for theme '%s'", test.code, code, test.name)
+		}
+	}
+	// Only the valid themes are installed, with the folder of the archive removed
+	if themes := GetAllThemes(); len(themes) != 2 || themes[0] != "plugin" || themes[1] != "simple" {
+		t.Errorf("Expected only the themes 'plugin' and 'simple' to be installed, received %v", themes)
+	}
+	if _, err := os.Stat(filepath.Join(themesPath, "simple", "index.hbs")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(themesPath), "outside.hbs")); err == nil {
+		t.Error("Expected files outside of the theme folder to be rejected")
+	}
+	var archive bytes.Buffer
+	if err := WriteThemeArchive(&archive, "simple"); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != 2 || filepath.Dir(reader.File[0].Name) != "simple" {
+		t.Errorf("Expected the files of the theme in the folder 'simple', received %d files", len(reader.File))
+	}
+	if err, ok := DeleteTheme("promenade").(*upload.Error); !ok || err.Code != ErrorThemeActive {
+		t.Error("Expected the active theme to not be deleted")
+	}
+	for _, theme := range []string{"plugin", "simple"} {
+		if err := DeleteTheme(theme); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(GetAllThemes()) != 0 {
+		t.Error("Expected the themes to be deleted")
+	}
+}
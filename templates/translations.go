@@ -0,0 +1,100 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"journey/date"
+	"journey/structure"
+)
+
+// Language of blogs that have no language set
+const defaultLanguage = "en"
+
+var languageChecker = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{1,8})*$`)
+
+// Function to check if a language is a valid language tag (e.g. en, de or pt-BR)
+func ValidLanguage(language string) bool {
+	return languageChecker.MatchString(language)
+}
+
+// Function to load the translations of a theme from locales/<language>.json. Falls back to the
+// base language (e.g. de for de-CH) and then to English. Themes without translations get an empty map.
+func loadTranslations(themePath string, language string) (map[string]string, error) {
+	candidates := make([]string, 0, 3)
+	if ValidLanguage(language) {
+		candidates = append(candidates, language)
+		if index := strings.IndexAny(language, "-_"); index != -1 {
+			candidates = append(candidates, language[:index])
+		}
+	}
+	candidates = append(candidates, defaultLanguage)
+	for _, candidate := range candidates {
+		fileName := filepath.Join(themePath, "locales", candidate+".json")
+		data, err := ioutil.ReadFile(fileName)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		translations := make(map[string]string)
+		err = json.Unmarshal(data, &translations)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read translations in %s: %v", fileName, err)
+		}
+		return translations, nil
+	}
+	return make(map[string]string), nil
+}
+
+func blogLanguage(blog *structure.Blog) string {
+	if blog == nil || blog.Language == "" {
+		return defaultLanguage
+	}
+	return blog.Language
+}
+
+// Function to get the locale that dates are formatted with
+func blogLocale(blog *structure.Blog) *date.Locale {
+	return date.LookupLocale(blogLanguage(blog))
+}
+
+// Function to convert a date to the timezone of the blog
+func blogTime(blog *structure.Blog, t time.Time) time.Time {
+	if blog == nil {
+		return t
+	}
+	return t.In(date.Location(blog.Timezone))
+}
+
+// Function to translate a text of the theme, e.g. {{t "Page {page} of {pages}" page=pagination.page pages=pagination.pages}}.
+// Texts without translation are used as they are.
+func tFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	if len(helper.Arguments) == 0 {
+		return []byte{}
+	}
+	key := string(helper.Arguments[0].Function(&helper.Arguments[0], values))
+	translation := compiledTemplates.translations[key]
+	if translation == "" {
+		translation = key
+	}
+	if len(helper.Arguments) > 1 {
+		// Replace placeholders like {page} with the hash arguments
+		placeholders := make([]string, 0)
+		for name, value := range evaluateArguments(helper.Arguments[1:], values) {
+			placeholders = append(placeholders, "{"+name+"}", value)
+		}
+		translation = strings.NewReplacer(placeholders...).Replace(translation)
+	}
+	return evaluateEscape([]byte(translation), helper.Unescaped)
+}
+
+func langFunc(helper *structure.Helper, values *structure.RequestData) []byte {
+	return evaluateEscape([]byte(blogLanguage(values.Blog)), helper.Unescaped)
+}
@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// JPEG markers
+const (
+	markerStartOfImage = 0xd8
+	markerStartOfScan  = 0xda
+	markerApp1         = 0xe1 // Exif and XMP (includes GPS data)
+	markerApp13        = 0xed // Photoshop IRB and IPTC
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// Function to remove Exif, XMP and IPTC metadata (camera, location, etc.) from a JPEG file without re-encoding the image.
+// The orientation is the only Exif value that is kept since the image would be displayed rotated without it.
+func StripJpegMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerStartOfImage {
+		return nil, errors.New("not a jpeg file")
+	}
+	var buffer bytes.Buffer
+	buffer.Write(data[:2])
+	orientation := 0
+	wroteOrientation := false
+	position := 2
+	for {
+		if position+4 > len(data) || data[position] != 0xff {
+			return nil, errors.New("corrupt jpeg segment")
+		}
+		marker := data[position+1]
+		// Fill bytes in front of a marker
+		if marker == 0xff {
+			position++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[position+2:]))
+		end := position + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("corrupt jpeg segment")
+		}
+		if marker == markerApp1 || marker == markerApp13 {
+			if marker == markerApp1 && orientation == 0 {
+				orientation = exifOrientation(data[position+4 : end])
+			}
+			position = end
+			continue
+		}
+		// The orientation is written as the first segment after the JFIF header (Exif files don't usually have one)
+		if !wroteOrientation && marker != 0xe0 {
+			if orientation > 1 {
+				buffer.Write(orientationSegment(orientation))
+			}
+			wroteOrientation = true
+		}
+		if marker == markerStartOfScan {
+			// Everything after the start of the scan is image data
+			buffer.Write(data[position:])
+			return buffer.Bytes(), nil
+		}
+		buffer.Write(data[position:end])
+		position = end
+	}
+}
+
+// Function to read the orientation tag from the first image file directory of an Exif segment. Returns 0 if there is none.
+func exifOrientation(segment []byte) int {
+	if !bytes.HasPrefix(segment, exifHeader) {
+		return 0
+	}
+	tiff := segment[len(exifHeader):]
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 { // Orientation
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// Function to create an Exif segment that only contains the orientation
+func orientationSegment(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08, // Big endian header, first directory at offset 8
+		0x00, 0x01, // One entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, byte(orientation >> 8), byte(orientation), 0x00, 0x00, // Orientation, SHORT, count 1
+		0x00, 0x00, 0x00, 0x00, // No next directory
+	}
+	length := 2 + len(exifHeader) + len(tiff)
+	segment := []byte{0xff, markerApp1, byte(length >> 8), byte(length)}
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...)
+}
@@ -0,0 +1,112 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Elements that are removed from svg files together with everything inside of them
+var forbiddenSvgElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// Function to remove everything from an svg file that can execute code when the file is opened in a browser:
+// script elements, event handler attributes and links to anything but fragments and embedded images.
+// Doctypes are removed as well since they can declare entities.
+func SanitizeSvg(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var buffer bytes.Buffer
+	skipDepth := 0
+	hasRoot := false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 || forbiddenSvgElements[strings.ToLower(token.Name.Local)] {
+				skipDepth++
+				continue
+			}
+			hasRoot = true
+			buffer.WriteByte('<')
+			buffer.WriteString(rawName(token.Name))
+			for _, attribute := range token.Attr {
+				if !isSafeSvgAttribute(attribute) {
+					continue
+				}
+				buffer.WriteByte(' ')
+				buffer.WriteString(rawName(attribute.Name))
+				buffer.WriteString("=\"")
+				_ = xml.EscapeText(&buffer, []byte(attribute.Value))
+				buffer.WriteByte('"')
+			}
+			buffer.WriteByte('>')
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			buffer.WriteString("</")
+			buffer.WriteString(rawName(token.Name))
+			buffer.WriteByte('>')
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+			_ = xml.EscapeText(&buffer, token)
+		case xml.ProcInst:
+			// Keep the xml declaration only
+			if token.Target == "xml" && buffer.Len() == 0 {
+				buffer.WriteString("<?xml ")
+				buffer.Write(token.Inst)
+				buffer.WriteString("?>")
+			}
+		}
+		// Comments and directives (e.g. doctypes) are dropped
+	}
+	if !hasRoot {
+		return nil, errors.New("no svg element found")
+	}
+	return buffer.Bytes(), nil
+}
+
+func rawName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+func isSafeSvgAttribute(attribute xml.Attr) bool {
+	name := strings.ToLower(attribute.Name.Local)
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	// Browsers ignore whitespace and control characters in urls, so "java\tscript:" is a script url as well
+	value := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(attribute.Value))
+	if strings.Contains(value, "javascript:") || strings.Contains(value, "vbscript:") {
+		return false
+	}
+	if name == "href" {
+		return strings.HasPrefix(value, "#") || (strings.HasPrefix(value, "data:image/") && !strings.HasPrefix(value, "data:image/svg"))
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package upload
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Error codes that are sent to the admin area if an upload is rejected
+const (
+	ErrorFileTooLarge     = "file_too_large"
+	ErrorRequestTooLarge  = "request_too_large"
+	ErrorUnsupportedType  = "unsupported_type"
+	ErrorInvalidImage     = "invalid_image"
+	ErrorMalformedRequest = "malformed_request"
+)
+
+// Error: an upload that was rejected. Message can be shown to the user.
+type Error struct {
+	Code       string
+	Message    string
+	Filename   string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	if e.Filename != "" {
+		return e.Filename + ": " + e.Message
+	}
+	return e.Message
+}
+
+// Allowed content types and the extension that files of that type are saved with
+var allowedTypes = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// Function to check if a file in the images folder is an image that can be listed in the admin area
+func IsImageFilename(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	if extension == ".jpeg" {
+		return true
+	}
+	for _, allowedExtension := range allowedTypes {
+		if extension == allowedExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to read and validate an uploaded file. The type of the file is determined by its content, not by the name the client sent.
+// Returns the cleaned file data and the extension it should be saved with.
+func Process(fileName string, reader io.Reader, maxFileSize int64) ([]byte, string, error) {
+	// Read one byte more than allowed to find out if the file is too large
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxFileSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxFileSize {
+		return nil, "", &Error{Code: ErrorFileTooLarge, Message: "The file is larger than " + FormatSize(maxFileSize) + ".", Filename: fileName, StatusCode: http.StatusRequestEntityTooLarge}
+	}
+	contentType := detectContentType(data)
+	extension, ok := allowedTypes[contentType]
+	if !ok {
+		return nil, "", &Error{Code: ErrorUnsupportedType, Message: "Only JPEG, PNG, GIF, WebP and SVG images can be uploaded.", Filename: fileName, StatusCode: http.StatusUnsupportedMediaType}
+	}
+	switch contentType {
+	case "image/jpeg":
+		data, err = StripJpegMetadata(data)
+	case "image/svg+xml":
+		data, err = SanitizeSvg(data)
+	}
+	if err != nil {
+		return nil, "", &Error{Code: ErrorInvalidImage, Message: "The image couldn't be read: " + err.Error(), Filename: fileName, StatusCode: http.StatusBadRequest}
+	}
+	return data, extension, nil
+}
+
+func detectContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	// http.DetectContentType doesn't know svg. It reports it as xml or text.
+	if strings.HasPrefix(contentType, "text/") && isSvg(data) {
+		return "image/svg+xml"
+	}
+	if index := strings.Index(contentType, ";"); index != -1 {
+		contentType = contentType[:index]
+	}
+	return contentType
+}
+
+// Function to format a size in bytes for error messages
+func FormatSize(size int64) string {
+	if size >= 1024*1024 && size%(1024*1024) == 0 {
+		return strconv.FormatInt(size/(1024*1024), 10) + " MB"
+	} else if size >= 1024 && size%1024 == 0 {
+		return strconv.FormatInt(size/1024, 10) + " KB"
+	}
+	return strconv.FormatInt(size, 10) + " bytes"
+}
+
+// Function to check if the data starts with an svg root element (after the xml declaration, comments and doctype)
+func isSvg(data []byte) bool {
+	data = bytes.TrimLeft(data, "\xef\xbb\xbf \t\r\n")
+	for len(data) > 0 {
+		if bytes.HasPrefix(data, []byte("<?")) {
+			end := bytes.Index(data, []byte("?>"))
+			if end == -1 {
+				return false
+			}
+			data = data[end+2:]
+		} else if bytes.HasPrefix(data, []byte("<!--")) {
+			end := bytes.Index(data, []byte("-->"))
+			if end == -1 {
+				return false
+			}
+			data = data[end+3:]
+		} else if bytes.HasPrefix(data, []byte("<!")) {
+			end := bytes.IndexByte(data, '>')
+			if end == -1 {
+				return false
+			}
+			data = data[end+1:]
+		} else {
+			return bytes.HasPrefix(data, []byte("<svg")) && len(data) > 4 && strings.IndexByte(" \t\r\n>/", data[4]) != -1
+		}
+		data = bytes.TrimLeft(data, " \t\r\n")
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSvg(t *testing.T) {
+	svg := `<?xml version="1.0"?>
+<!DOCTYPE svg [<!ENTITY x "y">]>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" onload="alert(1)">
+<script>alert(2)</script>
+<foreignObject><body><script>alert(3)</script></body></foreignObject>
+<a xlink:href="java&#x09;script:alert(4)"><rect width="10" height="10" fill="red"/></a>
+<use href="#shape"/>
+</svg>`
+	result, err := SanitizeSvg([]byte(svg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, forbidden := range []string{"alert", "onload", "script", "DOCTYPE", "ENTITY"} {
+		if strings.Contains(string(result), forbidden) {
+			t.Errorf("Sanitized svg still contains %s: %s", forbidden, result)
+		}
+	}
+	for _, expected := range []string{`<rect width="10" height="10" fill="red">`, `<use href="#shape">`, `xmlns:xlink="http://www.w3.org/1999/xlink"`} {
+		if !strings.Contains(string(result), expected) {
+			t.Errorf("Sanitized svg is missing %s: %s", expected, result)
+		}
+	}
+}
+
+func TestStripJpegMetadata(t *testing.T) {
+	// Exif segment with orientation 6 and a second (GPS) entry in little endian byte order
+	exif := []byte("Exif\x00\x00II\x2a\x00\x08\x00\x00\x00\x02\x00\x12\x01\x03\x00\x01\x00\x00\x00\x06\x00\x00\x00\x25\x88\x04\x00\x01\x00\x00\x00\x26\x00\x00\x00\x00\x00\x00\x00GPSDATA")
+	var jpeg bytes.Buffer
+	jpeg.Write([]byte{0xff, 0xd8})
+	jpeg.Write([]byte{0xff, 0xe1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)})
+	jpeg.Write(exif)
+	jpeg.Write([]byte{0xff, 0xdb, 0x00, 0x04, 0x01, 0x02}) // Quantization table
+	jpeg.Write([]byte{0xff, 0xda, 0x00, 0x02, 0x11, 0x22, 0xff, 0xd9})
+	result, err := StripJpegMetadata(jpeg.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(result, []byte("GPSDATA")) {
+		t.Error("Metadata was not removed")
+	}
+	if exifOrientation(result[6:]) != 6 {
+		t.Error("Orientation was not kept")
+	}
+	if !bytes.HasSuffix(result, []byte{0xff, 0xdb, 0x00, 0x04, 0x01, 0x02, 0xff, 0xda, 0x00, 0x02, 0x11, 0x22, 0xff, 0xd9}) {
+		t.Errorf("Image data was changed: %x", result)
+	}
+}
+
+func TestProcessRejectsDisguisedFiles(t *testing.T) {
+	_, _, err := Process("image.jpg", strings.NewReader("<html><script>alert(1)</script></html>"), 1024)
+	if uploadError, ok := err.(*Error); !ok || uploadError.Code != ErrorUnsupportedType {
+		t.Errorf("Expected unsupported type error, received %v", err)
+	}
+	_, _, err = Process("image.png", bytes.NewReader(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 2048)...)), 1024)
+	if uploadError, ok := err.(*Error); !ok || uploadError.Code != ErrorFileTooLarge {
+		t.Errorf("Expected file too large error, received %v", err)
+	}
+}
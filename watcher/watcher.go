@@ -61,9 +61,10 @@ func createWatcher(extensionsFunctions map[string]func() error) (*fsnotify.Watch
 					for key, value := range extensionsFunctions {
 						if !helpers.IsDirectory(event.Name) && filepath.Ext(event.Name) == key {
 							// Call the function associated with this file extension
+							// Keep watching if the reload fails, the file is probably being edited
 							err := value()
 							if err != nil {
-								log.Panic("Error while reloading theme or plugins:", err)
+								log.Println("Error while reloading theme or plugins:", err)
 							}
 						}
 					}